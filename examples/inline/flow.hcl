@@ -35,7 +35,8 @@ flow "terraform-provider-nomad" {
     }
 
     step "setup" {
-      run = <<EOH
+      shell = "bash"
+      run   = <<EOH
 apt-get -q update
 apt-get install -y git make wget
 
@@ -43,21 +44,22 @@ cd /tmp
 wget -c https://go.dev/dl/go${var.go_version}.linux-amd64.tar.gz
 tar -C /usr/local -xf go${var.go_version}.linux-amd64.tar.gz
 
-echo "export PATH=$PATH:/usr/local/go/bin" >> ~/.profile
+echo "PATH=/usr/local/go/bin:$PATH" >> "$NOMAD_PIPELINE_ENV"
 EOH
     }
 
     step "run_build" {
-      run = <<EOH
-source ~/.profile
-cd terraform-provider-nomad
+      shell       = "bash"
+      working_dir = "terraform-provider-nomad"
+      run         = <<EOH
 go build
 EOH
     }
+  }
 
+  on_failure {
     step "failure_notification" {
-      condition = "inline.steps.run_build.status == \"failed\""
-      run       = <<EOH
+      run = <<EOH
 echo "Build failed for commit ${var.trigger.git_sha}"
 EOH
     }
@@ -66,6 +66,20 @@ func outputFlow(f *api.Flow) {
 		_ = pterm.DefaultTable.WithHasHeader().WithData(out).Render()
 	}
 
+	if f.Workspace != nil {
+		pterm.DefaultSection.Print("Workspace")
+
+		pterm.DefaultBasicText.Print(helper.FormatKV([]string{
+			fmt.Sprintf("Namespace|%s", f.Workspace.Namespace),
+			fmt.Sprintf("Plugin ID|%s", f.Workspace.PluginID),
+			fmt.Sprintf("Node Pool|%s", f.Workspace.NodePool),
+			fmt.Sprintf("Capacity Min MB|%v", f.Workspace.CapacityMin),
+			fmt.Sprintf("Capacity Max MB|%v", f.Workspace.CapacityMax),
+			fmt.Sprintf("Mount Path|%s", f.Workspace.MountPath),
+		}))
+		pterm.DefaultBasicText.Print("\n")
+	}
+
 	switch f.Type() {
 	case api.FlowTypeInline:
 
@@ -98,29 +112,64 @@ func outputFlow(f *api.Flow) {
 		}
 
 		for _, step := range f.Inline.Steps {
-			pterm.DefaultSection.Print(f.Inline.ID, "::", step.ID)
-			if step.Condition != "" {
-				pterm.DefaultBasicText.Print(helper.FormatKV([]string{
-					fmt.Sprintf("Conditional|%s", step.Condition),
-				}))
-				pterm.DefaultBasicText.Print("\n")
-			}
-			pterm.DefaultBox.Println(step.Run)
+			outputStep(f.Inline.ID+"::"+step.ID, step)
 		}
 	case api.FlowTypeSpecification:
 		for _, spec := range f.Specification {
-			pterm.DefaultSection.Print(spec.ID)
-			if spec.Condition != "" {
-				pterm.Println(fmt.Sprintf("Condition: %q", spec.Condition))
-			}
-			if spec.Job.NameFormat != "" {
-				pterm.Println(fmt.Sprintf("Job Name Format: %q", spec.Job.NameFormat))
-			}
-			pterm.DefaultBox.Println(spec.Job.Raw)
+			outputSpecification(spec.ID, spec)
+		}
+	}
+
+	for _, hook := range []struct {
+		name string
+		hook *api.FlowHook
+	}{
+		{name: "on_success", hook: f.OnSuccess},
+		{name: "on_failure", hook: f.OnFailure},
+		{name: "finally", hook: f.Finally},
+	} {
+		if hook.hook == nil {
+			continue
+		}
+		for _, step := range hook.hook.Steps {
+			outputStep(hook.name+"::"+step.ID, step)
+		}
+		for _, spec := range hook.hook.Specification {
+			outputSpecification(hook.name+"::"+spec.ID, spec)
 		}
 	}
 }
 
+func outputStep(title string, step *api.Step) {
+	pterm.DefaultSection.Print(title)
+	var kv []string
+	if step.Condition != "" {
+		kv = append(kv, fmt.Sprintf("Conditional|%s", step.Condition))
+	}
+	if step.Shell != "" {
+		kv = append(kv, fmt.Sprintf("Shell|%s", step.Shell))
+	}
+	if step.WorkingDir != "" {
+		kv = append(kv, fmt.Sprintf("Working Dir|%s", step.WorkingDir))
+	}
+	if len(kv) > 0 {
+		pterm.DefaultBasicText.Print(helper.FormatKV(kv))
+		pterm.DefaultBasicText.Print("\n")
+	}
+	pterm.DefaultBox.Println(step.Run)
+}
+
+func outputSpecification(title string, spec *api.SpecificationFlow) {
+	pterm.DefaultSection.Print(title)
+	if spec.Condition != "" {
+		pterm.Println(fmt.Sprintf("Condition: %q", spec.Condition))
+	}
+	if spec.Job.NameFormat != "" {
+		pterm.Println(fmt.Sprintf("Job Name Format: %q", spec.Job.NameFormat))
+	}
+	pterm.DefaultBox.Println(spec.Job.Raw)
+}
+
 func variableTypeString(t string) string {
 	if t == "" {
 		return "<any>"
@@ -3,7 +3,10 @@ package run
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/pterm/pterm"
@@ -58,6 +61,30 @@ func outputRun(run *api.Run) {
 
 	pterm.DefaultSection.Print("Steps")
 	pterm.DefaultBasicText.Print(runBody(run))
+
+	if tests := runTests(run); tests != "" {
+		pterm.DefaultSection.Print("Tests")
+		pterm.DefaultBasicText.Print(tests)
+	}
+
+	if annotations := runAnnotations(run); annotations != "" {
+		pterm.DefaultSection.Print("Annotations")
+		pterm.DefaultBasicText.Print(annotations)
+	}
+
+	if outputs := runOutputs(run); outputs != "" {
+		pterm.DefaultSection.Print("Outputs")
+		pterm.DefaultBasicText.Print(outputs)
+	}
+
+	if run.InlineRun != nil {
+		for _, step := range run.InlineRun.Steps {
+			if step.Summary != "" {
+				pterm.DefaultSection.Print("Summary: " + step.ID)
+				pterm.DefaultBox.Println(strings.TrimSpace(step.Summary))
+			}
+		}
+	}
 }
 
 func runHeader(run *api.Run) string {
@@ -67,6 +94,7 @@ func runHeader(run *api.Run) string {
 		fmt.Sprintf("Flow ID|%s", run.FlowID),
 		fmt.Sprintf("Status|%v", colouredRunStatus(run.Status)),
 		fmt.Sprintf("Trigger|%s", run.Trigger),
+		fmt.Sprintf("Trigger Time|%v", helper.FormatTime(run.TriggerTime)),
 		fmt.Sprintf("Create Time|%v", helper.FormatTime(run.CreateTime)),
 		fmt.Sprintf("Start Time|%s", helper.FormatTime(run.StartTime)),
 		fmt.Sprintf("End Time|%s", helper.FormatTime(run.EndTime)),
@@ -132,6 +160,136 @@ func runBody(run *api.Run) string {
 	return body
 }
 
+// runTests summarises the test reports of the inline run steps, followed by
+// the name and first message line of each failed test. It returns an empty
+// string if no step produced a test report.
+func runTests(run *api.Run) string {
+
+	if run.InlineRun == nil {
+		return ""
+	}
+
+	var failures []string
+
+	out := pterm.TableData{{"Step", "Total", "Passed", "Failed", "Skipped", "Duration"}}
+
+	for _, step := range run.InlineRun.Steps {
+		if step.Tests == nil {
+			continue
+		}
+
+		failed := strconv.Itoa(step.Tests.Failed)
+		if step.Tests.Failed > 0 {
+			failed = pterm.Red(failed)
+		}
+
+		out = append(out, []string{
+			step.ID,
+			strconv.Itoa(step.Tests.Total),
+			strconv.Itoa(step.Tests.Passed),
+			failed,
+			strconv.Itoa(step.Tests.Skipped),
+			fmt.Sprintf("%.2fs", step.Tests.Duration),
+		})
+
+		for _, failure := range step.Tests.Failures {
+			name := failure.Name
+			if failure.Suite != "" {
+				name = failure.Suite + "." + name
+			}
+			line := fmt.Sprintf("%s %s: %s", pterm.Red("FAIL"), step.ID, name)
+
+			if msg, _, _ := strings.Cut(failure.Message, "\n"); msg != "" {
+				line += "\n    " + pterm.Gray(msg)
+			}
+			failures = append(failures, line)
+		}
+	}
+
+	if len(out) == 1 {
+		return ""
+	}
+
+	body, _ := pterm.DefaultTable.WithHasHeader().WithData(out).Srender()
+
+	if len(failures) > 0 {
+		body += "\n\n" + strings.Join(failures, "\n")
+	}
+
+	return body + "\n"
+}
+
+// runAnnotations lists the annotations of the inline run steps, with the file
+// location when the annotation has one.
+func runAnnotations(run *api.Run) string {
+
+	if run.InlineRun == nil {
+		return ""
+	}
+
+	var lines []string
+
+	for _, step := range run.InlineRun.Steps {
+		for _, annotation := range step.Annotations {
+
+			location := step.ID
+			if annotation.File != "" {
+				location += " " + annotation.File
+				if annotation.Line > 0 {
+					location += ":" + strconv.Itoa(annotation.Line)
+				}
+			}
+
+			message := annotation.Message
+			if annotation.Title != "" {
+				message = annotation.Title + ": " + message
+			}
+
+			lines = append(lines, fmt.Sprintf("%s %s: %s",
+				colouredAnnotationLevel(annotation.Level), location, message))
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func runOutputs(run *api.Run) string {
+
+	if run.InlineRun == nil {
+		return ""
+	}
+
+	out := pterm.TableData{{"Step", "Name", "Value"}}
+
+	for _, step := range run.InlineRun.Steps {
+		names := slices.Sorted(maps.Keys(step.Outputs))
+		for _, name := range names {
+			out = append(out, []string{step.ID, name, step.Outputs[name]})
+		}
+	}
+
+	if len(out) == 1 {
+		return ""
+	}
+
+	body, _ := pterm.DefaultTable.WithHasHeader().WithData(out).Srender()
+	return body + "\n"
+}
+
+func colouredAnnotationLevel(level string) string {
+	switch level {
+	case api.AnnotationLevelError:
+		return pterm.Red(strings.ToUpper(level))
+	case api.AnnotationLevelWarning:
+		return pterm.Yellow(strings.ToUpper(level))
+	default:
+		return pterm.Cyan(strings.ToUpper(level))
+	}
+}
+
 func colouredRunStatus(status string) string {
 	switch status {
 	case api.RunStatusPending:
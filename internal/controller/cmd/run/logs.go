@@ -2,7 +2,9 @@ package run
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/urfave/cli/v3"
@@ -30,10 +32,19 @@ func logsCommand() *cli.Command {
 				return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
 			}
 
-			if cmd.Bool("tail") {
+			switch {
+			case cmd.String("grep") != "":
+				return logSearch(ctx, cmd, id)
+			case cmd.Bool("follow") && cmd.String("step-id") == "":
+				return logFollow(ctx, cmd, id)
+			case cmd.String("step-id") == "":
+				return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg,
+					errors.New("step-id is required unless following or searching the whole run")), 1)
+			case cmd.Bool("tail"), cmd.Bool("follow"):
 				return logStream(ctx, cmd, id)
+			default:
+				return logGet(ctx, cmd, id)
 			}
-			return logGet(ctx, cmd, id)
 		},
 	}
 }
@@ -41,30 +52,73 @@ func logsCommand() *cli.Command {
 func logsCommandFlags() []cli.Flag {
 	return []cli.Flag{
 		&cli.StringFlag{
-			Name:     "step-id",
-			Required: true,
-			Value:    "",
-			Usage:    "The flow step ID to get logs for",
+			Name:  "step-id",
+			Value: "",
+			Usage: "The flow step ID to get logs for, which can be omitted when following or searching the run",
 		},
 		&cli.StringFlag{
 			Name:  "type",
-			Value: "stdout",
-			Usage: "The log type to get (stdout or stderr)",
+			Value: api.LogStreamStdout,
+			Usage: "The log type to get (stdout, stderr, or combined)",
+		},
+		&cli.BoolFlag{
+			Name:  "timestamps",
+			Value: false,
+			Usage: "Whether to prefix each line with the time it was captured",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Value: "",
+			Usage: "Only show lines captured after this RFC3339 timestamp or relative duration (e.g. 10m)",
+		},
+		&cli.StringFlag{
+			Name:  "until",
+			Value: "",
+			Usage: "Only show lines captured before this RFC3339 timestamp or relative duration (e.g. 10m)",
 		},
 		&cli.BoolFlag{
 			Name:  "tail",
 			Value: false,
 			Usage: "Whether to tail the logs or not",
 		},
+		&cli.StringFlag{
+			Name:  "grep",
+			Value: "",
+			Usage: "Only show lines containing this text, searching all steps unless a step ID is set",
+		},
+		&cli.BoolFlag{
+			Name:  "regex",
+			Value: false,
+			Usage: "Treat the grep pattern as a regular expression",
+		},
+		&cli.IntFlag{
+			Name:    "context",
+			Aliases: []string{"C"},
+			Value:   0,
+			Usage:   "The number of lines to show either side of each grep match",
+		},
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Value:   false,
+			Usage:   "Follow the logs until the run completes; without a step ID, the output of every step is followed in order",
+		},
 	}
 }
 
 func logStream(ctx context.Context, cmd *cli.Command, runID ulid.ULID) error {
 
+	since, until, err := parseLogsTimeFlags(cmd)
+	if err != nil {
+		return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+	}
+
 	req := api.RunLogsTailReq{
 		ID:     runID,
 		StepID: cmd.String("step-id"),
 		Type:   cmd.String("type"),
+		Since:  since,
+		Until:  until,
 	}
 
 	client := api.NewClient(helper.ClientConfigFromFlags(cmd))
@@ -81,18 +135,68 @@ func logStream(ctx context.Context, cmd *cli.Command, runID ulid.ULID) error {
 		case err := <-resp.ErrCh:
 			return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
 		case line := <-resp.LogCh:
-			_, _ = fmt.Fprint(cmd.Writer, line+"\n")
+			_, _ = fmt.Fprint(cmd.Writer, formatLogLine(cmd, cmd.String("type"), line)+"\n")
+		}
+	}
+}
+
+func logFollow(ctx context.Context, cmd *cli.Command, runID ulid.ULID) error {
+
+	since, until, err := parseLogsTimeFlags(cmd)
+	if err != nil {
+		return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+	}
+
+	// The whole run defaults to both streams, unless a type was explicitly
+	// requested.
+	logType := api.LogStreamCombined
+	if cmd.IsSet("type") {
+		logType = cmd.String("type")
+	}
+
+	req := api.RunLogsFollowReq{
+		ID:    runID,
+		Type:  logType,
+		Since: since,
+		Until: until,
+	}
+
+	client := api.NewClient(helper.ClientConfigFromFlags(cmd))
+
+	resp, _, err := client.Runs().LogsFollow(ctx, &req)
+	if err != nil {
+		return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-resp.ErrCh:
+			return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+		case line, ok := <-resp.LogCh:
+			if !ok {
+				return nil
+			}
+			_, _ = fmt.Fprintf(cmd.Writer, "[%s] %s\n", line.StepID, formatLogLine(cmd, logType, line))
 		}
 	}
 }
 
 func logGet(ctx context.Context, cmd *cli.Command, runID ulid.ULID) error {
 
+	since, until, err := parseLogsTimeFlags(cmd)
+	if err != nil {
+		return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+	}
+
 	req := api.RunLogsGetReq{
 		ID:     runID,
 		JobID:  cmd.String("job-id"),
 		StepID: cmd.String("step-id"),
 		Type:   cmd.String("type"),
+		Since:  since,
+		Until:  until,
 	}
 
 	client := api.NewClient(helper.ClientConfigFromFlags(cmd))
@@ -103,7 +207,115 @@ func logGet(ctx context.Context, cmd *cli.Command, runID ulid.ULID) error {
 	}
 
 	for _, log := range resp.Logs {
-		_, _ = fmt.Fprint(cmd.Writer, log+"\n")
+		_, _ = fmt.Fprint(cmd.Writer, formatLogLine(cmd, cmd.String("type"), log)+"\n")
+	}
+	return nil
+}
+
+func logSearch(ctx context.Context, cmd *cli.Command, runID ulid.ULID) error {
+
+	since, until, err := parseLogsTimeFlags(cmd)
+	if err != nil {
+		return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+	}
+
+	// Searches default to both streams, unless a type was explicitly
+	// requested.
+	logType := api.LogStreamCombined
+	if cmd.IsSet("type") {
+		logType = cmd.String("type")
+	}
+
+	req := api.RunLogsSearchReq{
+		LogsSearchQuery: api.LogsSearchQuery{
+			Query:   cmd.String("grep"),
+			Regex:   cmd.Bool("regex"),
+			Context: int(cmd.Int("context")),
+			Type:    logType,
+		},
+		ID:     runID,
+		StepID: cmd.String("step-id"),
+		Since:  since,
+		Until:  until,
+	}
+
+	client := api.NewClient(helper.ClientConfigFromFlags(cmd))
+
+	resp, _, err := client.Runs().LogsSearch(ctx, &req)
+	if err != nil {
+		return cli.Exit(helper.FormatError(logsCommandCLIErrorMsg, err), 1)
+	}
+
+	// Output matches in the same form as grep, where match lines use a colon
+	// separator and context lines use a dash.
+	for i, match := range resp.Matches {
+
+		if req.Context > 0 && i > 0 {
+			_, _ = fmt.Fprintln(cmd.Writer, "--")
+		}
+
+		for j, line := range match.Before {
+			lineNum := match.LineNumber - len(match.Before) + j
+			_, _ = fmt.Fprintf(cmd.Writer, "%s-%d-%s\n", match.StepID, lineNum, formatLogLine(cmd, logType, line))
+		}
+
+		_, _ = fmt.Fprintf(cmd.Writer, "%s:%d:%s\n", match.StepID, match.LineNumber, formatLogLine(cmd, logType, match.LogLine))
+
+		for j, line := range match.After {
+			lineNum := match.LineNumber + j + 1
+			_, _ = fmt.Fprintf(cmd.Writer, "%s-%d-%s\n", match.StepID, lineNum, formatLogLine(cmd, logType, line))
+		}
+	}
+
+	if resp.Truncated {
+		_, _ = fmt.Fprintf(cmd.Root().ErrWriter, "Only the first %d matches are shown\n", len(resp.Matches))
 	}
 	return nil
 }
+
+// formatLogLine formats the log line for output. The stream is included when
+// timestamps are requested for combined logs, so stderr lines can still be
+// identified.
+func formatLogLine(cmd *cli.Command, logType string, line *api.LogLine) string {
+
+	if !cmd.Bool("timestamps") {
+		return line.Text
+	}
+
+	prefix := line.Timestamp.Format(time.RFC3339Nano)
+
+	if logType == api.LogStreamCombined {
+		prefix += " " + line.Stream
+	}
+	return prefix + " " + line.Text
+}
+
+func parseLogsTimeFlags(cmd *cli.Command) (time.Time, time.Time, error) {
+
+	since, err := parseLogsTime(cmd.String("since"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse since: %w", err)
+	}
+
+	until, err := parseLogsTime(cmd.String("until"))
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("failed to parse until: %w", err)
+	}
+
+	return since, until, nil
+}
+
+// parseLogsTime parses either an RFC3339 timestamp, or a duration which is
+// subtracted from the current time. An empty value returns the zero time.
+func parseLogsTime(val string) (time.Time, error) {
+
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(val); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339Nano, val)
+}
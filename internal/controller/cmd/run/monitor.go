@@ -73,14 +73,12 @@ func monitorRun(ctx context.Context, client *api.Client, run *api.Run) {
 	consoleUpdater.start()
 	defer consoleUpdater.stop()
 
-	// Start log streaming if we have inline steps
+	// Stream the logs of the whole run, which switches between steps as they
+	// start.
 	if run.InlineRun != nil && len(run.InlineRun.Steps) > 0 {
-		for _, step := range run.InlineRun.Steps {
-			if step.Status == api.RunStatusRunning || step.Status == api.RunStatusPending {
-				go consoleUpdater.streamLogs(ctx, client, run.ID, step.ID)
-				break
-			}
-		}
+		streamCtx, cancel := context.WithCancel(ctx)
+		consoleUpdater.cancelLogStream = cancel
+		go consoleUpdater.streamLogs(streamCtx, client, run.ID)
 	}
 
 	consoleUpdater.update(run)
@@ -95,16 +93,6 @@ func monitorRun(ctx context.Context, client *api.Client, run *api.Run) {
 
 			consoleUpdater.update(resp.Run)
 
-			// Check if we need to start streaming logs from a new step
-			if resp.Run.InlineRun != nil {
-				for _, step := range resp.Run.InlineRun.Steps {
-					if step.Status == api.RunStatusRunning && !consoleUpdater.isStreamingStep(step.ID) {
-						go consoleUpdater.streamLogs(ctx, client, resp.Run.ID, step.ID)
-						break
-					}
-				}
-			}
-
 			if resp.Run.Status == api.RunStatusFailed || resp.Run.Status == api.RunStatusSuccess || resp.Run.Status == api.RunStatusCancelled {
 				consoleUpdater.update(resp.Run)
 				return
@@ -143,8 +131,6 @@ func (lb *logBuffer) get() []string {
 type runUpdater struct {
 	area            *pterm.AreaPrinter
 	logBuffer       *logBuffer
-	currentStepID   string
-	currentStepMu   sync.RWMutex
 	cancelLogStream context.CancelFunc
 }
 
@@ -159,42 +145,29 @@ func (ru *runUpdater) stop() {
 	}
 }
 
-func (ru *runUpdater) isStreamingStep(stepID string) bool {
-	ru.currentStepMu.RLock()
-	defer ru.currentStepMu.RUnlock()
-	return ru.currentStepID == stepID
-}
+func (ru *runUpdater) streamLogs(ctx context.Context, client *api.Client, runID ulid.ULID) {
 
-func (ru *runUpdater) streamLogs(ctx context.Context, client *api.Client, runID ulid.ULID, stepID string) {
-	ru.currentStepMu.Lock()
-	if ru.cancelLogStream != nil {
-		ru.cancelLogStream()
+	req := api.RunLogsFollowReq{
+		ID:   runID,
+		Type: api.LogStreamCombined,
 	}
 
-	streamCtx, cancel := context.WithCancel(ctx)
-	ru.cancelLogStream = cancel
-	ru.currentStepID = stepID
-	ru.currentStepMu.Unlock()
-
-	req := api.RunLogsTailReq{
-		ID:     runID,
-		StepID: stepID,
-		Type:   "stdout",
-	}
-
-	resp, _, err := client.Runs().LogsTail(streamCtx, &req)
+	resp, _, err := client.Runs().LogsFollow(ctx, &req)
 	if err != nil {
 		return
 	}
 
 	for {
 		select {
-		case <-streamCtx.Done():
+		case <-ctx.Done():
 			return
 		case <-resp.ErrCh:
 			return
-		case line := <-resp.LogCh:
-			ru.logBuffer.add(line)
+		case line, ok := <-resp.LogCh:
+			if !ok {
+				return
+			}
+			ru.logBuffer.add(fmt.Sprintf("[%s] %s", line.StepID, line.Text))
 		}
 	}
 }
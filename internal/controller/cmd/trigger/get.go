@@ -34,20 +34,29 @@ func getCommand() *cli.Command {
 				return cli.Exit(helper.FormatError(getCommandCLIErrorMsg, err), 1)
 			}
 
-			outputTrigger(resp.Trigger)
+			nextRun := "N/A"
+			if resp.NextRun != nil {
+				nextRun = helper.FormatTime(*resp.NextRun)
+			}
+
+			outputTrigger(resp.Trigger, fmt.Sprintf("Next Run|%s", nextRun))
 			return nil
 		},
 	}
 }
 
-func outputTrigger(t *api.Trigger) {
-	pterm.DefaultBasicText.Print(helper.FormatKV([]string{
+// outputTrigger prints the trigger, along with any extra key/value rows which
+// are not part of the trigger object.
+func outputTrigger(t *api.Trigger, extra ...string) {
+	pterm.DefaultBasicText.Print(helper.FormatKV(append([]string{
 		fmt.Sprintf("ID|%s", t.ID),
 		fmt.Sprintf("Namespace|%s", t.Namespace),
 		fmt.Sprintf("Flow|%s", t.Flow),
 		fmt.Sprintf("Source ID|%s", t.Source.ID),
 		fmt.Sprintf("Source Provider|%s", t.Source.Provider),
-	}))
+		fmt.Sprintf("Paused|%t", t.Paused),
+		fmt.Sprintf("Last Fire Time|%s", helper.FormatTime(t.LastFireTime)),
+	}, extra...)))
 	pterm.DefaultBasicText.Print("\n")
 
 	pterm.DefaultSection.Print("Source Configuration")
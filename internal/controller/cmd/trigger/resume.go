@@ -0,0 +1,39 @@
+package trigger
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urfave/cli/v3"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/cmd/helper"
+	"github.com/hashicorp-forge/nomad-pipeline/pkg/api/v1"
+)
+
+func resumeCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "resume",
+		Category:  "trigger",
+		Usage:     "Resume a Nomad Pipeline trigger",
+		UsageText: "nomad-pipeline trigger resume [options] [trigger-id]",
+		Flags:     helper.ClientFlags(helper.ClientFlagsWithNamespace),
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+
+			if numArgs := cmd.Args().Len(); numArgs != 1 {
+				return cli.Exit(helper.FormatError(resumeCommandCLIErrorMsg, fmt.Errorf("expected 1 argument, got %v", numArgs)), 1)
+			}
+
+			client := api.NewClient(helper.ClientConfigFromFlags(cmd))
+
+			req := api.TriggerResumeReq{ID: cmd.Args().First()}
+
+			_, _, err := client.Triggers().Resume(ctx, &req)
+			if err != nil {
+				return cli.Exit(helper.FormatError(resumeCommandCLIErrorMsg, err), 1)
+			}
+
+			_, _ = fmt.Fprintf(cmd.Writer, "successfully resumed Nomad Pipeline trigger %q\n", cmd.Args().First())
+			return nil
+		},
+	}
+}
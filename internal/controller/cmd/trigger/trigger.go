@@ -9,12 +9,15 @@ const (
 	deleteCommandCLIErrorMsg = "failed to delete Nomad Pipeline trigger"
 	getCommandCLIErrorMsg    = "failed to get Nomad Pipeline trigger"
 	listCommandCLIErrorMsg   = "failed to list Nomad Pipeline triggers"
+	pauseCommandCLIErrorMsg  = "failed to pause Nomad Pipeline trigger"
+	resumeCommandCLIErrorMsg = "failed to resume Nomad Pipeline trigger"
+	updateCommandCLIErrorMsg = "failed to update Nomad Pipeline trigger"
 )
 
 func Command() *cli.Command {
 	return &cli.Command{
 		Name:            "trigger",
-		Usage:           "Create, read, and delete Nomad Pipeline triggers",
+		Usage:           "Create, read, update, and delete Nomad Pipeline triggers",
 		HideHelpCommand: true,
 		UsageText:       "nomad-pipeline trigger <command> [options] [args]",
 		Commands: []*cli.Command{
@@ -22,6 +25,9 @@ func Command() *cli.Command {
 			deleteCommand(),
 			getCommand(),
 			listCommand(),
+			pauseCommand(),
+			resumeCommand(),
+			updateCommand(),
 		},
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/hashicorp/nomad/api"
 	"github.com/oklog/ulid/v2"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/coordinator/inline"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/coordinator/spec"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/logstore"
 	serverstate "github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/logger"
@@ -35,6 +37,22 @@ type Coordinator struct {
 	specRunners     map[string]*spec.SpecRunner
 	specRunnersLock sync.RWMutex
 
+	// logLocks serialises writes of log batches to each step log, so the
+	// de-duplication check and write are atomic.
+	logLocks *keyedLocks[logTopicKey]
+
+	// runLocks serialises updates to each run, so they can be merged with
+	// the stored run.
+	runLocks *keyedLocks[state.RunNamespacedKey]
+
+	// latestRuns caches the latest run of flows used to build status badges.
+	latestRuns *latestRunCache
+
+	// logStore persists the step logs, and logBroker publishes written log
+	// lines to clients following the logs.
+	logStore  logstore.Store
+	logBroker *logBroker
+
 	//
 	trigger *trigger.Handler
 
@@ -48,6 +66,11 @@ type CoordinatorConfig struct {
 	State       serverstate.State
 	DataDir     string
 	RPCAddr     string
+	LogStore    logstore.Store
+
+	// PublicURL is the URL clients use to reach the HTTP API, which is used
+	// to link to runs from external systems.
+	PublicURL string
 }
 
 func New(cfg *CoordinatorConfig) *Coordinator {
@@ -58,6 +81,11 @@ func New(cfg *CoordinatorConfig) *Coordinator {
 		state:         cfg.State,
 		inlineRunners: make(map[state.RunNamespacedKey]*inline.InlineRunner),
 		specRunners:   make(map[string]*spec.SpecRunner),
+		logStore:      cfg.LogStore,
+		logBroker:     newLogBroker(cfg.LogStore),
+		logLocks:      newKeyedLocks[logTopicKey](),
+		runLocks:      newKeyedLocks[state.RunNamespacedKey](),
+		latestRuns:    newLatestRunCache(),
 		inlineStartCh: make(chan *state.RunNamespacedKey, 10),
 		rpcAddr:       cfg.RPCAddr,
 		shutdownCh:    make(chan struct{}),
@@ -67,6 +95,8 @@ func New(cfg *CoordinatorConfig) *Coordinator {
 		c.logger,
 		c.state,
 		c.runFlowFromTrigger,
+		c.CancelRun,
+		cfg.PublicURL,
 	)
 
 	return c
@@ -79,6 +109,7 @@ func (c *Coordinator) Start() error {
 	}
 
 	go c.monitorInlineStart()
+	go c.gcLogBroker()
 
 	return nil
 }
@@ -91,6 +122,7 @@ func (c *Coordinator) Stop() {
 
 func (c *Coordinator) RunFlow(
 	id, namespace, trigger string,
+	triggerTime time.Time,
 	vars map[string]any,
 ) (ulid.ULID, error) {
 
@@ -106,11 +138,15 @@ func (c *Coordinator) RunFlow(
 
 	runID := ulid.Make()
 
-	switch stateResp.Flow.Type() {
+	// Runners execute the flow hooks as regular steps or specifications, which
+	// are gated by the condition matching the hook.
+	flow := stateResp.Flow.ExpandHooks()
+
+	switch flow.Type() {
 	case state.FlowTypeInline:
-		err = c.triggerInlineFlow(runID, stateResp.Flow, trigger, runVars)
+		err = c.triggerInlineFlow(runID, flow, trigger, triggerTime, runVars)
 	case state.FlowTypeSpecification:
-		err = c.triggerSpecFlow(runID, stateResp.Flow, trigger, runVars)
+		err = c.triggerSpecFlow(runID, flow, trigger, triggerTime, runVars)
 	default:
 		err = errors.New("failed to determine flow type")
 	}
@@ -122,9 +158,33 @@ func (c *Coordinator) RunFlow(
 }
 
 // runFlowFromTrigger is called by the trigger coordinator to run a flow
-func (c *Coordinator) runFlowFromTrigger(flowID, namespace, trigger string, vars map[string]any) error {
-	_, err := c.RunFlow(flowID, namespace, trigger, vars)
-	return err
+func (c *Coordinator) runFlowFromTrigger(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error) {
+	return c.RunFlow(flowID, namespace, trigger, triggerTime, vars)
+}
+
+// UpdateRun merges the updated run into the stored run and writes it to state,
+// then notifies the trigger which started the run, so it can report the run
+// status. Statuses never move backwards, see mergeRunUpdate.
+func (c *Coordinator) UpdateRun(run *state.Run) error {
+
+	unlock := c.runLocks.lock(state.RunNamespacedKey{ID: run.ID, Namespace: run.Namespace})
+	defer unlock()
+
+	getResp, stateErr := c.state.Runs().Get(&serverstate.RunsGetReq{ID: run.ID, Namespace: run.Namespace})
+	if stateErr != nil {
+		return stateErr
+	}
+
+	run = mergeRunUpdate(getResp.Run, run)
+
+	if _, err := c.state.Runs().Update(&serverstate.RunsUpdateReq{Run: run}); err != nil {
+		return err
+	}
+
+	c.logBroker.runUpdated(run)
+
+	c.trigger.RunUpdated(run)
+	return nil
 }
 
 func (c *Coordinator) CancelRun(id ulid.ULID, namespace string) error {
@@ -151,9 +211,8 @@ func (c *Coordinator) CancelRun(id ulid.ULID, namespace string) error {
 
 	resp.Run.MarkCancelled()
 
-	_, stateErr = c.state.Runs().Update(&serverstate.RunsUpdateReq{Run: resp.Run})
-	if stateErr != nil {
-		return fmt.Errorf("failed to update run status: %w", stateErr)
+	if err := c.UpdateRun(resp.Run); err != nil {
+		return fmt.Errorf("failed to update run status: %w", err)
 	}
 
 	return nil
@@ -211,6 +270,34 @@ func (c *Coordinator) CreateTrigger(trigger *state.Trigger) error {
 	return nil
 }
 
+func (c *Coordinator) UpdateTrigger(existing, trigger *state.Trigger) error {
+
+	c.logger.Debug(
+		"updating trigger",
+		zap.String("provider", trigger.Source.Provider),
+		zap.String("trigger_id", trigger.ID),
+	)
+
+	if err := c.trigger.UpdateTrigger(existing, trigger); err != nil {
+		c.logger.Error(
+			"failed to update trigger",
+			zap.String("provider", trigger.Source.Provider),
+			zap.String("trigger_id", trigger.ID),
+			zap.Error(err),
+		)
+		return fmt.Errorf("failed to update trigger: %w", err)
+	}
+
+	c.logger.Info(
+		"successfully updated trigger",
+		zap.String("provider", trigger.Source.Provider),
+		zap.String("trigger_id", trigger.ID),
+		zap.Bool("paused", trigger.Paused),
+	)
+
+	return nil
+}
+
 func (c *Coordinator) TriggerDelete(trigger *state.Trigger) error {
 
 	c.logger.Debug(
@@ -238,6 +325,37 @@ func (c *Coordinator) TriggerDelete(trigger *state.Trigger) error {
 	return nil
 }
 
+// TriggerNextRun returns the next time the trigger is scheduled to run its
+// flow. It returns false if the trigger is not scheduled.
+func (c *Coordinator) TriggerNextRun(trigger *state.Trigger) (time.Time, bool) {
+	return c.trigger.NextRun(trigger)
+}
+
+// TriggerRedacted returns a copy of the trigger, where the secrets within its
+// config are redacted, so it can be returned by the API.
+func (c *Coordinator) TriggerRedacted(t *state.Trigger) *state.Trigger {
+	return trigger.Redact(t)
+}
+
+// TriggerUnredact restores the secrets of the updated trigger config which
+// were left redacted, from the existing trigger.
+func (c *Coordinator) TriggerUnredact(existing, updated *state.Trigger) {
+	trigger.Unredact(existing, updated)
+}
+
 func (c *Coordinator) HandleWebhook(w http.ResponseWriter, r *http.Request, trigger *state.Trigger) {
 	c.trigger.HandleTriggerWebhook(w, r, trigger)
 }
+
+// TriggerDeliveries returns the recorded webhook deliveries of the trigger,
+// ordered from newest to oldest.
+func (c *Coordinator) TriggerDeliveries(trigger *state.Trigger) []*state.TriggerDelivery {
+	return c.trigger.ListDeliveries(trigger)
+}
+
+// TriggerRedeliver processes the recorded webhook delivery of the trigger
+// again. It returns an error if the delivery is not found, or its payload is
+// not retained.
+func (c *Coordinator) TriggerRedeliver(trigger *state.Trigger, deliveryID string) (*state.TriggerDelivery, error) {
+	return c.trigger.Redeliver(trigger, deliveryID)
+}
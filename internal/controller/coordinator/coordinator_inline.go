@@ -2,6 +2,7 @@ package coordinator
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
@@ -13,7 +14,7 @@ import (
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
-func (c *Coordinator) triggerInlineFlow(runID ulid.ULID, flow *state.Flow, trigger string, vars map[string]any) error {
+func (c *Coordinator) triggerInlineFlow(runID ulid.ULID, flow *state.Flow, trigger string, triggerTime time.Time, vars map[string]any) error {
 
 	evalCtx, err := hcl.GenerateEvalContext(vars)
 	if err != nil {
@@ -29,6 +30,9 @@ func (c *Coordinator) triggerInlineFlow(runID ulid.ULID, flow *state.Flow, trigg
 		EvalCtx:  evalCtx,
 		Vars:     vars,
 		RPRCAddr: c.rpcAddr,
+
+		Trigger:     trigger,
+		TriggerTime: triggerTime,
 	}
 
 	inlineRunner, err := inline.NewRunner(&inlineReq)
@@ -36,12 +40,14 @@ func (c *Coordinator) triggerInlineFlow(runID ulid.ULID, flow *state.Flow, trigg
 		return fmt.Errorf("failed to create inline runner: %w", err)
 	}
 
-	ctx := context.New(runID, trigger, flow, vars)
+	ctx := context.New(runID, trigger, triggerTime, flow, vars)
 
 	if _, err := c.state.Runs().Create(&serverstate.RunsCreateReq{Run: ctx.Run()}); err != nil {
 		return fmt.Errorf("failed to create run state: %w", err)
 	}
 
+	c.trigger.RunUpdated(ctx.Run())
+
 	if err := inlineRunner.Start(c.inlineStartCh); err != nil {
 		return fmt.Errorf("failed to start inline runner: %w", err)
 	}
@@ -81,7 +87,7 @@ func (c *Coordinator) hanldeInlineStartFailure(id *state.RunNamespacedKey) {
 
 	stateResp.Run.MarkFailed()
 
-	if _, err := c.state.Runs().Update(&serverstate.RunsUpdateReq{Run: stateResp.Run}); err != nil {
+	if err := c.UpdateRun(stateResp.Run); err != nil {
 		c.logger.Error("failed to update state for inline start failure",
 			zap.String("run_id", id.ID.String()),
 			zap.String("namespace", id.Namespace),
@@ -96,3 +102,38 @@ func (c *Coordinator) hanldeInlineStartFailure(id *state.RunNamespacedKey) {
 		)
 	}
 }
+
+// WaitInlineCancel blocks until the inline run is cancelled, or the timeout
+// elapses. It returns whether the run has been cancelled.
+func (c *Coordinator) WaitInlineCancel(id ulid.ULID, namespace string, timeout time.Duration) bool {
+
+	c.inlineRunnersLock.RLock()
+	inlineRunner, ok := c.inlineRunners[state.RunNamespacedKey{ID: id, Namespace: namespace}]
+	c.inlineRunnersLock.RUnlock()
+
+	var cancelledCh <-chan struct{}
+
+	// The runner may not be tracked if the controller was restarted during the
+	// run, so fallback to the run state to determine whether it was cancelled.
+	// The nil channel means the select waits for the timeout.
+	if ok {
+		cancelledCh = inlineRunner.Cancelled()
+	} else {
+		stateResp, err := c.state.Runs().Get(&serverstate.RunsGetReq{ID: id, Namespace: namespace})
+		if err == nil && stateResp.Run.Status == state.RunStatusCancelled {
+			return true
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-cancelledCh:
+		return true
+	case <-timer.C:
+		return false
+	case <-c.shutdownCh:
+		return false
+	}
+}
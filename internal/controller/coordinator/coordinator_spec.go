@@ -2,6 +2,7 @@ package coordinator
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
@@ -12,14 +13,16 @@ import (
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
-func (c *Coordinator) triggerSpecFlow(runID ulid.ULID, flow *state.Flow, trigger string, vars map[string]any) error {
+func (c *Coordinator) triggerSpecFlow(runID ulid.ULID, flow *state.Flow, trigger string, triggerTime time.Time, vars map[string]any) error {
 
-	ctx := context.New(runID, trigger, flow, vars)
+	ctx := context.New(runID, trigger, triggerTime, flow, vars)
 
 	if _, err := c.state.Runs().Create(&serverstate.RunsCreateReq{Run: ctx.Run()}); err != nil {
 		return fmt.Errorf("failed to create run state: %w", err)
 	}
 
+	c.trigger.RunUpdated(ctx.Run())
+
 	specReq := spec.SpecRunnerReq{
 		Client:   c.nomadClient,
 		Logger:   c.logger.With(zap.String("flow_id", flow.ID)).With(zap.String("run_id", runID.String())),
@@ -28,6 +31,9 @@ func (c *Coordinator) triggerSpecFlow(runID ulid.ULID, flow *state.Flow, trigger
 		Trigger:  trigger,
 		UpdateCh: make(chan *state.Run, 1),
 		Vars:     vars,
+
+		TriggerTime: triggerTime,
+		ShutdownCh:  c.shutdownCh,
 	}
 
 	specRunner, err := spec.NewRunner(&specReq)
@@ -50,8 +56,7 @@ func (c *Coordinator) triggerSpecFlow(runID ulid.ULID, flow *state.Flow, trigger
 
 func (c *Coordinator) monitorStatusUpdates(ch chan *state.Run) {
 	for update := range ch {
-		_, err := c.state.Runs().Update(&serverstate.RunsUpdateReq{Run: update})
-		if err != nil {
+		if err := c.UpdateRun(update); err != nil {
 			c.logger.Error("failed to update run status", zap.String("run_id", update.ID.String()), zap.Error(err))
 			continue
 		}
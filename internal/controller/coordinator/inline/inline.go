@@ -3,6 +3,7 @@ package inline
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/nomad/api"
@@ -23,11 +24,27 @@ type InlineRunnerReq struct {
 	Vars     map[string]any
 	EvalCtx  *hcl.EvalContext
 	RPRCAddr string
+
+	// Trigger and TriggerTime identify what triggered the run, and are passed
+	// to the runner so it can populate the run context.
+	Trigger     string
+	TriggerTime time.Time
 }
 
+// cancelCleanupTimeout is the time, in addition to the cancel grace period,
+// that the runner is given to stop and run its cleanup steps after a run is
+// cancelled. Once elapsed, the Nomad job is deregistered.
+const cancelCleanupTimeout = 5 * time.Minute
+
 type InlineRunner struct {
-	cancel    chan struct{}
-	req       *InlineRunnerReq
+	cancel chan struct{}
+	req    *InlineRunnerReq
+
+	// cancelled is closed when the run is cancelled, which notifies runners
+	// waiting on the cancel RPC.
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+
 	jobSpec   *api.Job
 	queryOpts *api.QueryOptions
 }
@@ -35,16 +52,19 @@ type InlineRunner struct {
 func NewRunner(req *InlineRunnerReq) (*InlineRunner, error) {
 
 	r := InlineRunner{
-		cancel: make(chan struct{}),
-		req:    req,
+		cancel:    make(chan struct{}),
+		cancelled: make(chan struct{}),
+		req:       req,
 	}
 
 	jobBuildReq := jobBuilderReq{
-		runID:   req.RunID,
-		flow:    req.Flow,
-		vars:    req.Vars,
-		evalCtx: req.EvalCtx,
-		rpcAddr: req.RPRCAddr,
+		runID:       req.RunID,
+		flow:        req.Flow,
+		vars:        req.Vars,
+		evalCtx:     req.EvalCtx,
+		rpcAddr:     req.RPRCAddr,
+		trigger:     req.Trigger,
+		triggerTime: req.TriggerTime,
 	}
 
 	jobspec, err := newJobBuilder(&jobBuildReq).Build()
@@ -118,6 +138,12 @@ func (r *InlineRunner) getAlloc(jobID string) (*api.AllocationListStub, error) {
 	}
 }
 
+// Cancelled returns a channel which is closed when the run is cancelled.
+func (r *InlineRunner) Cancelled() <-chan struct{} { return r.cancelled }
+
+// Cancel notifies the runner of the cancellation, so it can gracefully stop
+// the running step and execute any cleanup steps. The Nomad job is only
+// deregistered if it has not stopped once the cancel timeout elapses.
 func (r *InlineRunner) Cancel() error {
 	r.req.Logger.Info("cancelling inline runner", zap.String("nomad_job_id", *r.jobSpec.ID))
 
@@ -126,6 +152,48 @@ func (r *InlineRunner) Cancel() error {
 	default:
 	}
 
-	_, _, err := r.req.Client.Jobs().Deregister(*r.jobSpec.ID, false, nil)
-	return err
+	r.cancelOnce.Do(func() {
+		close(r.cancelled)
+		go r.deregisterAfterCancel()
+	})
+
+	return nil
+}
+
+func (r *InlineRunner) deregisterAfterCancel() {
+
+	gracePeriod, err := r.req.Flow.Inline.GetCancelGracePeriod()
+	if err != nil {
+		gracePeriod = state.DefaultCancelGracePeriod
+	}
+
+	deadline := time.NewTimer(gracePeriod + cancelCleanupTimeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadline.C:
+			r.req.Logger.Info("inline runner did not stop within cancel timeout, deregistering job",
+				zap.String("nomad_job_id", *r.jobSpec.ID))
+
+			writeOpts := api.WriteOptions{Namespace: *r.jobSpec.Namespace}
+
+			if _, _, err := r.req.Client.Jobs().Deregister(*r.jobSpec.ID, false, &writeOpts); err != nil {
+				r.req.Logger.Error("failed to deregister job", zap.String("nomad_job_id", *r.jobSpec.ID), zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			job, _, err := r.req.Client.Jobs().Info(*r.jobSpec.ID, r.queryOpts)
+			if err != nil {
+				r.req.Logger.Error("failed to get job", zap.String("nomad_job_id", *r.jobSpec.ID), zap.Error(err))
+				continue
+			}
+			if *job.Status == "dead" {
+				return
+			}
+		}
+	}
 }
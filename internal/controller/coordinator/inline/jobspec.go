@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/hashicorp/nomad/api"
 	"github.com/oklog/ulid/v2"
@@ -21,22 +23,91 @@ type jobBuilderReq struct {
 	vars    map[string]any
 
 	rpcAddr string
+
+	trigger     string
+	triggerTime time.Time
 }
 
 type jobBuilder struct {
 	baseDir string
+	workDir string
 	req     *jobBuilderReq
+
+	// stepTasks maps each step ID to the name of the task that executes it.
+	// It is only populated when the flow uses more than one step image.
+	stepTasks map[string]string
+
+	// imageTasks is the ordered list of images which require their own task,
+	// excluding the runner image which is always run by the leader task.
+	imageTasks []string
+
+	// leaderRunsSteps is whether any step is run by the leader task, rather
+	// than a task of its own image.
+	leaderRunsSteps bool
 }
 
+const (
+	// installTaskName is the name of the prestart task which copies the runner
+	// binary into the shared allocation directory, so that step images do not
+	// need to include it.
+	installTaskName = "install-runner"
+
+	// installBinPath is the path, within the shared allocation directory, that
+	// the runner binary is installed to.
+	installBinPath = "${NOMAD_ALLOC_DIR}/bin"
+
+	// coordinatorTaskCPU and coordinatorTaskMemoryMB are the resources of the
+	// leader task when every step is run by an image task, so it only
+	// coordinates the run.
+	coordinatorTaskCPU      = 100
+	coordinatorTaskMemoryMB = 128
+)
+
 func newJobBuilder(req *jobBuilderReq) *jobBuilder {
-	return &jobBuilder{
+	b := jobBuilder{
 		baseDir: filepath.Join("local", req.runID.String()),
 		req:     req,
 	}
+
+	b.workDir = b.baseDir
+
+	for _, step := range req.flow.Inline.Steps {
+		if step.Image == "" || step.Image == req.flow.Inline.Runner.NomadOnDemand.Image {
+			b.leaderRunsSteps = true
+			continue
+		}
+		if !slices.Contains(b.imageTasks, step.Image) {
+			b.imageTasks = append(b.imageTasks, step.Image)
+		}
+	}
+
+	// When steps use their own images, the workspace must be shared between
+	// the tasks, so it is moved into the allocation directory.
+	if len(b.imageTasks) > 0 {
+		b.workDir = filepath.Join("alloc", req.runID.String())
+		b.stepTasks = make(map[string]string, len(req.flow.Inline.Steps))
+
+		for _, step := range req.flow.Inline.Steps {
+			b.stepTasks[step.ID] = b.taskName(step.Image)
+		}
+	}
+
+	return &b
 }
 
 func (b *jobBuilder) Build() (*api.Job, error) {
 
+	leaderTask := &api.Task{
+		Name:   b.req.flow.ID,
+		Driver: "docker",
+		Config: map[string]any{
+			"image":   b.req.flow.Inline.Runner.NomadOnDemand.Image,
+			"command": "nomad-pipeline-runner",
+			"args":    []string{"job", "run", "-config", filepath.Join(b.baseDir, "runner.json")},
+		},
+		Resources: b.getLeaderResources(),
+	}
+
 	j := api.Job{
 		Name:      helper.PointerOf(b.req.runID.String()),
 		ID:        helper.PointerOf(b.req.runID.String()),
@@ -53,18 +124,7 @@ func (b *jobBuilder) Build() (*api.Job, error) {
 					Attempts: helper.PointerOf(0),
 					Mode:     helper.PointerOf("fail"),
 				},
-				Tasks: []*api.Task{
-					{
-						Name:   b.req.flow.ID,
-						Driver: "docker",
-						Config: map[string]any{
-							"image":   b.req.flow.Inline.Runner.NomadOnDemand.Image,
-							"command": "nomad-pipeline-runner",
-							"args":    []string{"job", "run", "-config", filepath.Join(b.baseDir, "runner.json")},
-						},
-						Resources: b.getResources(),
-					},
-				},
+				Tasks: []*api.Task{leaderTask},
 			},
 		},
 	}
@@ -81,33 +141,111 @@ func (b *jobBuilder) Build() (*api.Job, error) {
 		}
 
 		if artifact.Dest != "" {
-			taskArtifact.RelativeDest = helper.PointerOf(filepath.Join(b.baseDir, artifact.Dest))
+			taskArtifact.RelativeDest = helper.PointerOf(b.artifactDest(artifact.Dest))
 		}
 
-		j.TaskGroups[0].Tasks[0].Artifacts = append(j.TaskGroups[0].Tasks[0].Artifacts, taskArtifact)
+		leaderTask.Artifacts = append(leaderTask.Artifacts, taskArtifact)
+	}
+
+	if err := b.addRunnerConfig(leaderTask, true); err != nil {
+		return nil, err
 	}
 
+	if len(b.imageTasks) == 0 {
+		return &j, nil
+	}
+
+	// The install task uses the runner image to copy the runner binary into
+	// the shared allocation directory before any of the step tasks start.
+	j.TaskGroups[0].Tasks = append(j.TaskGroups[0].Tasks, &api.Task{
+		Name:   installTaskName,
+		Driver: "docker",
+		Config: map[string]any{
+			"image":   b.req.flow.Inline.Runner.NomadOnDemand.Image,
+			"command": "nomad-pipeline-runner",
+			"args":    []string{"job", "install", "-dest", installBinPath},
+		},
+		Lifecycle: &api.TaskLifecycle{
+			Hook:    api.TaskLifecycleHookPrestart,
+			Sidecar: false,
+		},
+	})
+
+	for _, image := range b.imageTasks {
+		task := &api.Task{
+			Name:   b.taskName(image),
+			Driver: "docker",
+			Config: map[string]any{
+				"image":   image,
+				"command": installBinPath + "/nomad-pipeline-runner",
+				"args":    []string{"job", "run", "-config", filepath.Join(b.baseDir, "runner.json")},
+			},
+			Resources: b.getResources(),
+		}
+
+		if err := b.addRunnerConfig(task, false); err != nil {
+			return nil, err
+		}
+
+		j.TaskGroups[0].Tasks = append(j.TaskGroups[0].Tasks, task)
+	}
+
+	return &j, nil
+}
+
+// addRunnerConfig adds the templated runner configuration file to the task.
+// Each task receives its own copy, which identifies the task name, so the
+// runner knows which steps it is responsible for executing.
+func (b *jobBuilder) addRunnerConfig(task *api.Task, leader bool) error {
+
 	hostCfg := host.RunConfig{
 		ID:            b.req.runID,
 		Namespace:     b.req.flow.Namespace,
 		Flow:          b.req.flow,
+		Trigger:       b.req.trigger,
+		TriggerTime:   b.req.triggerTime,
 		JobID:         b.req.flow.Inline.ID,
 		JobSteps:      b.req.flow.Inline.Steps,
 		ControllerRPC: b.req.rpcAddr,
 		Variables:     b.req.vars,
+		WorkDir:       b.workDir,
+		Task:          task.Name,
+		Leader:        leader,
+		StepTasks:     b.stepTasks,
 	}
 
 	data, err := json.Marshal(&hostCfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal runner config: %w", err)
+		return fmt.Errorf("failed to marshal runner config: %w", err)
 	}
 
-	j.TaskGroups[0].Tasks[0].Templates = append(j.TaskGroups[0].Tasks[0].Templates, &api.Template{
+	task.Templates = append(task.Templates, &api.Template{
 		DestPath:     helper.PointerOf(filepath.Join(b.baseDir, "runner.json")),
 		EmbeddedTmpl: helper.PointerOf(string(data)),
 	})
 
-	return &j, nil
+	return nil
+}
+
+// taskName returns the name of the task responsible for running steps using
+// the passed image. Steps without an image, or using the runner image, are run
+// by the leader task.
+func (b *jobBuilder) taskName(image string) string {
+	idx := slices.Index(b.imageTasks, image)
+	if image == "" || idx < 0 {
+		return b.req.flow.ID
+	}
+	return fmt.Sprintf("%s-image-%d", b.req.flow.ID, idx)
+}
+
+// artifactDest returns the artifact destination relative to the task
+// directory. Artifacts are written into the workspace, which lives within the
+// shared allocation directory when the run is split across multiple tasks.
+func (b *jobBuilder) artifactDest(dest string) string {
+	if len(b.imageTasks) > 0 {
+		return filepath.Join("..", b.workDir, dest)
+	}
+	return filepath.Join(b.workDir, dest)
 }
 
 func (b *jobBuilder) getNamespace() string {
@@ -117,6 +255,20 @@ func (b *jobBuilder) getNamespace() string {
 	return api.DefaultNamespace
 }
 
+// getLeaderResources returns the resources of the leader task. Steps are run
+// one at a time, but the tasks of the allocation each reserve their resources
+// for the whole run, so a leader which does not run any steps is given only
+// enough to coordinate the run.
+func (b *jobBuilder) getLeaderResources() *api.Resources {
+	if len(b.imageTasks) == 0 || b.leaderRunsSteps {
+		return b.getResources()
+	}
+	return &api.Resources{
+		CPU:      helper.PointerOf(coordinatorTaskCPU),
+		MemoryMB: helper.PointerOf(coordinatorTaskMemoryMB),
+	}
+}
+
 func (b *jobBuilder) getResources() *api.Resources {
 	r := api.Resources{}
 
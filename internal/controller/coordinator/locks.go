@@ -0,0 +1,46 @@
+package coordinator
+
+import "sync"
+
+// keyedLocks holds a lock for each key which is in use, such as a step log or
+// run. Locks are removed once they are no longer held or waited on, so the
+// map only contains the keys with operations in flight.
+type keyedLocks[K comparable] struct {
+	mu    sync.Mutex
+	locks map[K]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedLocks[K comparable]() *keyedLocks[K] {
+	return &keyedLocks[K]{locks: make(map[K]*keyedLock)}
+}
+
+// lock acquires the lock of the key, returning the function which releases
+// it.
+func (l *keyedLocks[K]) lock(key K) func() {
+
+	l.mu.Lock()
+	keyLock, ok := l.locks[key]
+	if !ok {
+		keyLock = &keyedLock{}
+		l.locks[key] = keyLock
+	}
+	keyLock.refs++
+	l.mu.Unlock()
+
+	keyLock.Lock()
+
+	return func() {
+		keyLock.Unlock()
+
+		l.mu.Lock()
+		if keyLock.refs--; keyLock.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
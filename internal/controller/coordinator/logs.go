@@ -1,70 +1,205 @@
 package coordinator
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"path/filepath"
+	"iter"
+	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
-func (c *Coordinator) Getlogs(namespace, runID, stepID, logType string) ([]string, error) {
+// LogsFilter is used to select the log lines returned from a step log.
+type LogsFilter struct {
+	// Stream is the output stream to return lines for; stdout, stderr or
+	// combined.
+	Stream string
+
+	// Since and Until filter lines by their capture timestamp, and are ignored
+	// when zero.
+	Since time.Time
+	Until time.Time
+}
+
+// Match returns whether the log line passes the filter.
+func (f *LogsFilter) Match(line *state.LogLine) bool {
+	if f.Stream != state.LogStreamCombined && f.Stream != line.Stream {
+		return false
+	}
+	if !f.Since.IsZero() && line.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && line.Timestamp.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Getlogs returns the step log lines matching the filter, ordered as they were
+// captured by the runner.
+func (c *Coordinator) Getlogs(namespace, runID, stepID string, filter *LogsFilter) ([]*state.LogLine, error) {
 
-	var lines []string
+	var lines []*state.LogLine
 
-	fileHandle, err := os.Open(logPath(c.dataDir, namespace, runID, stepID, logType))
+	err := c.streamLogs(namespace, runID, stepID, func(line *state.LogLine) bool {
+		if filter.Match(line) {
+			lines = append(lines, line)
+		}
+		return true
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer fileHandle.Close()
+	return lines, nil
+}
+
+// streamLogs calls fn with each line of the step log, ordered as they were
+// captured by the runner, without holding the whole log in memory. It stops
+// early if fn returns false.
+//
+// Batches from each stream are written as they arrive, so the log interleaves
+// the streams by batch, while the lines of each stream are in order. The
+// captured order is restored by reading each stream separately, and merging
+// them using the step sequence number.
+func (c *Coordinator) streamLogs(namespace, runID, stepID string, fn func(*state.LogLine) bool) error {
+
+	streams := []string{state.LogStreamStdout, state.LogStreamStderr}
+
+	var (
+		nexts = make([]func() (*state.LogLine, bool), len(streams))
+		heads = make([]*state.LogLine, len(streams))
+		errs  = make([]error, len(streams))
+	)
+
+	for i, stream := range streams {
+
+		next, stop := iter.Pull(func(yield func(*state.LogLine) bool) {
+			errs[i] = c.logStore.Stream(namespace, runID, stepID, 0, func(line *state.LogLine) bool {
+				return line.Stream != stream || yield(line)
+			})
+		})
+		defer stop()
+
+		nexts[i] = next
+		heads[i], _ = next()
+	}
+
+	for {
+		idx := -1
+
+		for i, head := range heads {
+			if head != nil && (idx == -1 || head.Sequence < heads[idx].Sequence) {
+				idx = i
+			}
+		}
+		if idx == -1 {
+			break
+		}
 
-	scanner := bufio.NewScanner(fileHandle)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+		if !fn(heads[idx]) {
+			return nil
+		}
+		heads[idx], _ = nexts[idx]()
 	}
 
-	return lines, scanner.Err()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *Coordinator) StreamLogs(namespace, runID, stepID, logType string) *LogStream {
-	return NewLogStream(logPath(c.dataDir, namespace, runID, stepID, logType))
+func (c *Coordinator) StreamLogs(namespace, runID, stepID string, filter *LogsFilter) *LogStream {
+	return newLogStream(c, newLogTopicKey(namespace, runID, stepID), filter)
 }
 
-func (c *Coordinator) WriteLogsBatch(namespace, runID, stepID, logType string, lines []string) error {
+// DeleteLogs removes the logs of all steps within the run from the log store.
+func (c *Coordinator) DeleteLogs(namespace, runID string) error {
+	return c.logStore.Delete(namespace, runID)
+}
 
-	path := logPath(c.dataDir, namespace, runID, stepID, logType)
+// subscribeLogs subscribes to the lines of the step log. This holds the lock
+// of the step log, so the subscription is not created part way through a batch
+// write.
+func (c *Coordinator) subscribeLogs(key logTopicKey) (*logSubscription, error) {
 
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
+	unlock := c.logLocks.lock(key)
+	defer unlock()
+
+	return c.logBroker.subscribe(key)
+}
+
+// gcLogBroker periodically removes idle step topics from the log broker,
+// until the coordinator is stopped.
+func (c *Coordinator) gcLogBroker() {
+
+	ticker := time.NewTicker(logBrokerGCInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.shutdownCh:
+			return
+		case <-ticker.C:
+			c.logBroker.gc(logBrokerIdleTimeout)
+		}
 	}
-	defer f.Close()
+}
+
+// WriteLogsBatch appends the batch of log lines to the step log. Runners
+// retry batches they could not confirm were sent, so batches with a sequence
+// number lower than or equal to the last written are ignored. A sequence of
+// zero disables this de-duplication, for runners which do not set one.
+func (c *Coordinator) WriteLogsBatch(namespace, runID, stepID, logType string, seq uint64, lines []*state.LogLine) error {
+
+	key := newLogTopicKey(namespace, runID, stepID)
 
-	totalBytes := 0
+	// Batches of both streams are serialised, as the broker positions lines
+	// using the length of the step log, which both streams append to. Batches
+	// of other steps are written concurrently.
+	unlock := c.logLocks.lock(key)
+	defer unlock()
 
-	// Write the log bytes
-	for _, line := range lines {
-		bytesWritten, err := f.WriteString(line + "\n")
+	if seq > 0 {
+		lastSeq, err := c.logStore.Sequence(namespace, runID, stepID, logType)
 		if err != nil {
-			return fmt.Errorf("failed to write log batch: %w", err)
+			return err
+		}
+		if seq <= lastSeq {
+			c.logger.Debug("ignoring duplicate log batch",
+				zap.String("run_id", runID),
+				zap.String("step_id", stepID),
+				zap.String("type", logType),
+				zap.Uint64("sequence", seq))
+			return nil
 		}
-		totalBytes += bytesWritten
 	}
 
-	c.logger.Debug("successfully wrote log batch to disk",
+	// Find the index of the first line before writing, so the broker can
+	// position the lines within the log.
+	offset, err := c.logBroker.len(key)
+	if err != nil {
+		return err
+	}
+
+	if err := c.logStore.Append(namespace, runID, stepID, lines); err != nil {
+		return err
+	}
+
+	c.logBroker.publish(key, offset, lines)
+
+	if seq > 0 {
+		if err := c.logStore.SetSequence(namespace, runID, stepID, logType, seq); err != nil {
+			return err
+		}
+	}
+
+	c.logger.Debug("successfully wrote log batch",
 		zap.String("run_id", runID),
 		zap.String("step_id", stepID),
 		zap.String("type", logType),
-		zap.Int("bytes", totalBytes))
+		zap.Int("lines", len(lines)))
 
 	return nil
 }
-
-func logPath(dataDir, namespace, runID, stepID, logType string) string {
-	return filepath.Join(logDir(dataDir, namespace, runID, stepID), "logs", fmt.Sprintf("%s.log", logType))
-}
-
-func logDir(dataDir, namespace, runID, stepID string) string {
-	return filepath.Join(dataDir, namespace, runID, stepID)
-}
@@ -0,0 +1,327 @@
+package coordinator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/logstore"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// logBrokerRingSize is the number of recent lines held in memory for each
+	// step. Subscribers which start, or fall, further behind than this read
+	// the older lines from the log store.
+	logBrokerRingSize = 1000
+
+	// logBrokerIdleTimeout is the time after which a step topic with no
+	// subscribers and no new lines is removed from memory.
+	logBrokerIdleTimeout = 5 * time.Minute
+
+	logBrokerGCInterval = 1 * time.Minute
+)
+
+// logBroker fans out log lines written by runners to the clients following
+// the logs, so each follower does not need to poll the log store. Each step
+// has a topic holding a bounded ring buffer of its most recent lines, keyed by
+// the index of each line within the step log. This allows a subscriber to read
+// its history from the store, and then switch to the ring buffer without
+// missing or duplicating lines.
+type logBroker struct {
+	store logstore.Store
+
+	lock   sync.Mutex
+	topics map[logTopicKey]*logTopic
+
+	// runs holds the runs whose logs are being followed, so followers are
+	// notified of step and run status changes without polling the state.
+	runs map[state.RunNamespacedKey]*runWatch
+}
+
+// runWatch holds the latest update of a run, which is nil until the run is
+// updated after the watch was created. The update channel is closed and
+// replaced on each update.
+type runWatch struct {
+	run      *state.Run
+	updateCh chan struct{}
+	refs     int
+}
+
+type logTopicKey struct {
+	namespace string
+	runID     string
+	stepID    string
+}
+
+func newLogTopicKey(namespace, runID, stepID string) logTopicKey {
+	return logTopicKey{namespace: namespace, runID: runID, stepID: stepID}
+}
+
+type logTopic struct {
+	key logTopicKey
+
+	// ring holds the most recent lines of the step, where start is the index
+	// of the first line and end is the index after the last.
+	ring  []*state.LogLine
+	start int
+	end   int
+
+	subs     map[*logSubscription]struct{}
+	lastUsed time.Time
+}
+
+// logSubscription follows the lines of a single step. The position is the
+// index of the next line the subscriber has not yet read.
+type logSubscription struct {
+	broker   *logBroker
+	topic    *logTopic
+	pos      int
+	notifyCh chan struct{}
+}
+
+func newLogBroker(store logstore.Store) *logBroker {
+	return &logBroker{
+		store:  store,
+		topics: make(map[logTopicKey]*logTopic),
+		runs:   make(map[state.RunNamespacedKey]*runWatch),
+	}
+}
+
+// getTopic returns the topic for the step log, creating it if needed. A new
+// topic starts at the current end of the log, so any existing lines are
+// treated as history. The caller must hold the broker lock.
+func (b *logBroker) getTopic(key logTopicKey) (*logTopic, error) {
+
+	if topic, ok := b.topics[key]; ok {
+		return topic, nil
+	}
+
+	n, err := b.store.Len(key.namespace, key.runID, key.stepID)
+	if err != nil {
+		return nil, err
+	}
+
+	topic := logTopic{
+		key:      key,
+		start:    n,
+		end:      n,
+		subs:     make(map[*logSubscription]struct{}),
+		lastUsed: time.Now(),
+	}
+	b.topics[key] = &topic
+
+	return &topic, nil
+}
+
+// len returns the number of lines within the step log.
+func (b *logBroker) len(key logTopicKey) (int, error) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	topic, err := b.getTopic(key)
+	if err != nil {
+		return 0, err
+	}
+	return topic.end, nil
+}
+
+// subscribe creates a subscription which reads the step log from the start.
+// Callers must serialise this with writes to the log, so the topic is created
+// with the correct length.
+func (b *logBroker) subscribe(key logTopicKey) (*logSubscription, error) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	topic, err := b.getTopic(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := logSubscription{
+		broker:   b,
+		topic:    topic,
+		notifyCh: make(chan struct{}, 1),
+	}
+	topic.subs[&sub] = struct{}{}
+	topic.lastUsed = time.Now()
+
+	return &sub, nil
+}
+
+// publish adds the lines, which were appended to the step log starting at the
+// passed index, and notifies the subscribers.
+func (b *logBroker) publish(key logTopicKey, offset int, lines []*state.LogLine) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	topic, ok := b.topics[key]
+	if !ok {
+		return
+	}
+
+	// The log should only be written via the broker, but if the indexes
+	// disagree then discard the ring, so subscribers read from the store
+	// instead of receiving lines at the wrong position.
+	if topic.end != offset {
+		topic.ring = nil
+		topic.start = offset
+		topic.end = offset
+	}
+
+	topic.ring = append(topic.ring, lines...)
+	topic.end += len(lines)
+
+	if over := len(topic.ring) - logBrokerRingSize; over > 0 {
+		topic.ring = append([]*state.LogLine(nil), topic.ring[over:]...)
+		topic.start += over
+	}
+
+	topic.lastUsed = time.Now()
+
+	for sub := range topic.subs {
+		select {
+		case sub.notifyCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watchRun starts watching the run for updates, until unwatchRun is called.
+func (b *logBroker) watchRun(key state.RunNamespacedKey) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	watch, ok := b.runs[key]
+	if !ok {
+		watch = &runWatch{updateCh: make(chan struct{})}
+		b.runs[key] = watch
+	}
+	watch.refs++
+}
+
+func (b *logBroker) unwatchRun(key state.RunNamespacedKey) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if watch, ok := b.runs[key]; ok {
+		if watch.refs--; watch.refs == 0 {
+			delete(b.runs, key)
+		}
+	}
+}
+
+// runUpdate returns the latest update of the watched run, and the channel
+// closed on its next update.
+func (b *logBroker) runUpdate(key state.RunNamespacedKey) (*state.Run, <-chan struct{}) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	watch, ok := b.runs[key]
+	if !ok {
+		return nil, nil
+	}
+	return watch.run, watch.updateCh
+}
+
+// runUpdated notifies the watchers of the run that it has been updated. It is
+// a noop when the run is not being watched.
+func (b *logBroker) runUpdated(run *state.Run) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	watch, ok := b.runs[state.RunNamespacedKey{ID: run.ID, Namespace: run.Namespace}]
+	if !ok {
+		return
+	}
+
+	watch.run = run
+	close(watch.updateCh)
+	watch.updateCh = make(chan struct{})
+}
+
+// gc removes the topics which have no subscribers and have been idle for
+// longer than the timeout.
+func (b *logBroker) gc(timeout time.Duration) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for key, topic := range b.topics {
+		if len(topic.subs) == 0 && time.Since(topic.lastUsed) > timeout {
+			delete(b.topics, key)
+		}
+	}
+}
+
+// NotifyCh receives when new lines have been published since the last read.
+func (s *logSubscription) NotifyCh() <-chan struct{} { return s.notifyCh }
+
+// Read calls fn with each line published since the last read, reading any
+// lines no longer held in the ring buffer from the store. It stops early if
+// fn returns false.
+func (s *logSubscription) Read(fn func(*state.LogLine) bool) error {
+
+	for {
+		s.broker.lock.Lock()
+
+		if s.pos < s.topic.start {
+			end := s.topic.start
+			s.broker.lock.Unlock()
+
+			key := s.topic.key
+
+			var stopped bool
+
+			err := s.broker.store.Stream(key.namespace, key.runID, key.stepID, s.pos, func(line *state.LogLine) bool {
+				if s.pos >= end {
+					return false
+				}
+				s.pos++
+
+				if !fn(line) {
+					stopped = true
+					return false
+				}
+				return true
+			})
+			if err != nil {
+				return err
+			}
+			if stopped {
+				return nil
+			}
+			s.pos = end
+			continue
+		}
+
+		lines := s.topic.ring[s.pos-s.topic.start:]
+		s.pos = s.topic.end
+		s.broker.lock.Unlock()
+
+		for _, entry := range lines {
+			// Each subscriber may modify its copy of the line, such as
+			// setting the step ID.
+			line := *entry
+			if !fn(&line) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+func (s *logSubscription) Close() {
+
+	s.broker.lock.Lock()
+	defer s.broker.lock.Unlock()
+
+	delete(s.topic.subs, s)
+	s.topic.lastUsed = time.Now()
+}
@@ -0,0 +1,167 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+
+	"github.com/oklog/ulid/v2"
+
+	serverstate "github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// RunLogStream streams the logs of every step within an inline run, in
+// execution order. It follows each step until it reaches a terminal status,
+// before moving onto the next, and finishes once the run is terminal and all
+// output has been sent.
+type RunLogStream struct {
+	coordinator *Coordinator
+	namespace   string
+	runID       ulid.ULID
+	filter      *LogsFilter
+
+	errorCh  chan error
+	streamCh chan *state.LogLine
+	doneCh   chan struct{}
+}
+
+func (c *Coordinator) StreamRunLogs(namespace string, runID ulid.ULID, filter *LogsFilter) *RunLogStream {
+	return &RunLogStream{
+		coordinator: c,
+		namespace:   namespace,
+		runID:       runID,
+		filter:      filter,
+		errorCh:     make(chan error),
+		streamCh:    make(chan *state.LogLine),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+func (s *RunLogStream) ErrorCh() <-chan error { return s.errorCh }
+
+func (s *RunLogStream) StreamCh() <-chan *state.LogLine { return s.streamCh }
+
+// DoneCh is closed once all the run logs have been streamed.
+func (s *RunLogStream) DoneCh() <-chan struct{} { return s.doneCh }
+
+func (s *RunLogStream) Run(ctx context.Context) {
+
+	var (
+		stepIdx int
+		sub     *logSubscription
+	)
+
+	defer func() {
+		if sub != nil {
+			sub.Close()
+		}
+	}()
+
+	// Step and run status changes are received from the log broker, which is
+	// notified of each update to the run, so the state is only read once
+	// however many clients follow the run.
+	key := state.RunNamespacedKey{ID: s.runID, Namespace: s.namespace}
+
+	s.coordinator.logBroker.watchRun(key)
+	defer s.coordinator.logBroker.unwatchRun(key)
+
+	var run *state.Run
+
+	for {
+		// The update channel is taken before the run is read, so an update
+		// made in between is not missed.
+		latest, updateCh := s.coordinator.logBroker.runUpdate(key)
+
+		switch {
+		case latest != nil:
+			run = latest
+		case run == nil:
+			resp, stateErr := s.coordinator.state.Runs().Get(&serverstate.RunsGetReq{ID: s.runID, Namespace: s.namespace})
+			if stateErr != nil {
+				s.sendError(ctx, stateErr)
+				return
+			}
+			run = resp.Run
+		}
+
+		if run.InlineRun == nil {
+			s.sendError(ctx, errors.New("run logs are only available for inline runs"))
+			return
+		}
+
+		// Once the run is terminal, all steps are drained of their remaining
+		// output, regardless of their own status.
+		runTerminal := state.IsTerminalStatus(run.Status)
+		steps := run.InlineRun.Steps
+
+		for stepIdx < len(steps) {
+
+			step := steps[stepIdx]
+			stepTerminal := runTerminal || state.IsTerminalStatus(step.Status)
+
+			if step.Status == state.RunStatusPending && !stepTerminal {
+				break
+			}
+
+			if sub == nil {
+				var err error
+				sub, err = s.coordinator.subscribeLogs(newLogTopicKey(s.namespace, s.runID.String(), step.ID))
+				if err != nil {
+					s.sendError(ctx, err)
+					return
+				}
+			}
+
+			if err := sub.Read(func(line *state.LogLine) bool {
+				if !s.filter.Match(line) {
+					return true
+				}
+				line.StepID = step.ID
+
+				select {
+				case <-ctx.Done():
+					return false
+				case s.streamCh <- line:
+					return true
+				}
+			}); err != nil {
+				s.sendError(ctx, err)
+				return
+			}
+
+			if !stepTerminal {
+				break
+			}
+
+			sub.Close()
+			sub = nil
+			stepIdx++
+		}
+
+		if runTerminal && stepIdx == len(steps) {
+			close(s.doneCh)
+			return
+		}
+
+		// New lines of the current step are received via the subscription,
+		// and step and run status changes via the run update.
+		var notifyCh <-chan struct{}
+		if sub != nil {
+			notifyCh = sub.NotifyCh()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-notifyCh:
+		case <-updateCh:
+		}
+	}
+}
+
+func (s *RunLogStream) sendError(ctx context.Context, err error) {
+	select {
+	case <-ctx.Done():
+	case s.errorCh <- err:
+	}
+}
@@ -0,0 +1,312 @@
+package coordinator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	serverstate "github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// LogsSearchDefaultLimit is the number of matches returned by a search
+	// which does not set a limit.
+	LogsSearchDefaultLimit = 100
+
+	// logsSearchMaxLimit is the maximum number of matches returned by a
+	// search, which larger limits are reduced to.
+	logsSearchMaxLimit = 1000
+
+	// logsSearchMaxContext is the maximum number of context lines which can
+	// be requested either side of a match.
+	logsSearchMaxContext = 50
+
+	// LogsSearchDefaultWindow is the age of the oldest runs searched by a
+	// namespace wide search which does not set the since time.
+	LogsSearchDefaultWindow = 7 * 24 * time.Hour
+
+	// logsSearchMaxRuns is the maximum number of runs whose logs are read by a
+	// namespace wide search, after which the result is truncated.
+	logsSearchMaxRuns = 200
+)
+
+// LogsSearchQuery describes the lines to find within step logs.
+type LogsSearchQuery struct {
+	// Query is the text to find, which is treated as a regular expression if
+	// Regex is set.
+	Query string
+	Regex bool
+
+	// Context is the number of lines to include either side of each match.
+	Context int
+
+	// Limit is the maximum number of matches to return, which is capped at
+	// logsSearchMaxLimit.
+	Limit int
+
+	// Filter restricts the lines which are searched, such as to a stream.
+	Filter *LogsFilter
+}
+
+// LogsSearchResult holds the matches of a log search. Truncated is set when
+// the limit of matches or runs searched was reached, and more matches may
+// exist.
+type LogsSearchResult struct {
+	Matches   []*state.LogMatch
+	Truncated bool
+}
+
+// RunsLogsSearchReq selects the runs searched by a namespace wide log
+// search.
+type RunsLogsSearchReq struct {
+	Namespace string
+
+	// FlowID restricts the search to runs of a single flow, when set.
+	FlowID string
+
+	// Since and Until restrict the search to runs created within the time
+	// range. Since defaults to LogsSearchDefaultWindow before now, and Until
+	// is ignored when zero.
+	Since time.Time
+	Until time.Time
+}
+
+type logsMatcher func(string) bool
+
+// Validate checks the query is valid, and sets the default filter if none was
+// provided.
+func (q *LogsSearchQuery) Validate() error {
+
+	if q.Query == "" {
+		return errors.New("search query not provided")
+	}
+	if q.Context < 0 || q.Context > logsSearchMaxContext {
+		return fmt.Errorf("context must be between 0 and %v", logsSearchMaxContext)
+	}
+	if q.Regex {
+		if _, err := regexp.Compile(q.Query); err != nil {
+			return fmt.Errorf("failed to compile search query: %w", err)
+		}
+	}
+	if q.Filter == nil {
+		q.Filter = &LogsFilter{Stream: state.LogStreamCombined}
+	}
+	return nil
+}
+
+func (q *LogsSearchQuery) matcher() (logsMatcher, error) {
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !q.Regex {
+		return func(s string) bool { return strings.Contains(s, q.Query) }, nil
+	}
+	return regexp.MustCompile(q.Query).MatchString, nil
+}
+
+func (q *LogsSearchQuery) limit() int {
+	if q.Limit <= 0 {
+		return LogsSearchDefaultLimit
+	}
+	return min(q.Limit, logsSearchMaxLimit)
+}
+
+// SearchRunLogs searches the logs of the run steps. If the step ID is set,
+// only that step is searched.
+func (c *Coordinator) SearchRunLogs(ctx context.Context, namespace string, runID ulid.ULID, stepID string, query *LogsSearchQuery) (*LogsSearchResult, error) {
+
+	match, err := query.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, stateErr := c.state.Runs().Get(&serverstate.RunsGetReq{ID: runID, Namespace: namespace})
+	if stateErr != nil {
+		return nil, stateErr
+	}
+
+	var result LogsSearchResult
+
+	if err := c.searchRun(ctx, resp.Run, stepID, query, match, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SearchLogs searches the logs of all runs within the namespace which match
+// the request, newest first. The search stops once the limit of matches or
+// runs searched is reached, or the context is cancelled.
+func (c *Coordinator) SearchLogs(ctx context.Context, req *RunsLogsSearchReq, query *LogsSearchQuery) (*LogsSearchResult, error) {
+
+	match, err := query.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	since := req.Since
+	if since.IsZero() {
+		since = time.Now().Add(-LogsSearchDefaultWindow)
+	}
+
+	listResp, stateErr := c.state.Runs().List(&serverstate.RunsListReq{Namespace: req.Namespace})
+	if stateErr != nil {
+		return nil, stateErr
+	}
+
+	var (
+		result   LogsSearchResult
+		searched int
+	)
+
+	// Run IDs are ULIDs, so sorting them in reverse orders the runs from
+	// newest to oldest.
+	slices.SortFunc(listResp.Runs, func(a, b *state.RunStub) int { return b.ID.Compare(a.ID) })
+
+	for _, stub := range listResp.Runs {
+
+		if req.FlowID != "" && stub.FlowID != req.FlowID {
+			continue
+		}
+		if stub.CreateTime.Before(since) {
+			continue
+		}
+		if !req.Until.IsZero() && stub.CreateTime.After(req.Until) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if searched == logsSearchMaxRuns {
+			result.Truncated = true
+			break
+		}
+		searched++
+
+		resp, stateErr := c.state.Runs().Get(&serverstate.RunsGetReq{ID: stub.ID, Namespace: stub.Namespace})
+		if stateErr != nil {
+			return nil, stateErr
+		}
+
+		if err := c.searchRun(ctx, resp.Run, "", query, match, &result); err != nil {
+			return nil, err
+		}
+		if result.Truncated {
+			break
+		}
+	}
+
+	return &result, nil
+}
+
+// searchRun appends the matches within the logs of the run to the result,
+// setting it as truncated once the limit of matches is reached.
+func (c *Coordinator) searchRun(ctx context.Context, run *state.Run, stepID string, query *LogsSearchQuery, match logsMatcher, result *LogsSearchResult) error {
+
+	// Only inline runs have logs stored by the controller.
+	if run.InlineRun == nil {
+		return nil
+	}
+
+	for _, step := range run.InlineRun.Steps {
+
+		if stepID != "" && step.ID != stepID {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := c.searchStep(ctx, run, step.ID, query, match, result); err != nil {
+			// Steps which have not written any output, such as skipped
+			// steps, do not have a log.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to read logs of step %q: %w", step.ID, err)
+		}
+		if result.Truncated {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// searchStep appends the matches within the step log to the result. The log is
+// scanned line by line, holding only the context lines before the current
+// line, and the matches still waiting for their context lines after.
+func (c *Coordinator) searchStep(ctx context.Context, run *state.Run, stepID string, query *LogsSearchQuery, match logsMatcher, result *LogsSearchResult) error {
+
+	var (
+		lineNumber int
+		before     []*state.LogLine
+		pending    []*state.LogMatch
+	)
+
+	// The whole combined output is scanned, so line numbers and context lines
+	// are the same regardless of the filter.
+	err := c.streamLogs(run.Namespace, run.ID.String(), stepID, func(line *state.LogLine) bool {
+
+		if ctx.Err() != nil {
+			return false
+		}
+		lineNumber++
+
+		for _, m := range pending {
+			m.After = append(m.After, line)
+		}
+		pending = slices.DeleteFunc(pending, func(m *state.LogMatch) bool { return len(m.After) == query.Context })
+
+		// Once the limit is reached, only the context lines of the last
+		// matches are read, rather than reading further to find whether more
+		// matches exist.
+		if result.Truncated {
+			return len(pending) > 0
+		}
+
+		if query.Filter.Match(line) && match(line.Text) {
+
+			m := state.LogMatch{
+				RunID:      run.ID.String(),
+				StepID:     stepID,
+				LineNumber: lineNumber,
+				LogLine:    line,
+				Before:     slices.Clone(before),
+			}
+			result.Matches = append(result.Matches, &m)
+
+			if query.Context > 0 {
+				pending = append(pending, &m)
+			}
+			if len(result.Matches) == query.limit() {
+				result.Truncated = true
+			}
+		}
+
+		if query.Context > 0 {
+			if len(before) == query.Context {
+				before = before[1:]
+			}
+			before = append(before, line)
+		}
+
+		return !result.Truncated || len(pending) > 0
+	})
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
@@ -3,54 +3,70 @@ package coordinator
 import (
 	"context"
 
-	"github.com/hpcloud/tail"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
+// LogStream follows the logs of a single step, sending the existing lines
+// followed by new lines as they are written.
 type LogStream struct {
-	path     string
-	errorCh  chan error
-	streamCh chan string
+	coordinator *Coordinator
+	key         logTopicKey
+	filter      *LogsFilter
+	errorCh     chan error
+	streamCh    chan *state.LogLine
 }
 
-func NewLogStream(path string) *LogStream {
+func newLogStream(c *Coordinator, key logTopicKey, filter *LogsFilter) *LogStream {
 	return &LogStream{
-		path:     path,
-		errorCh:  make(chan error),
-		streamCh: make(chan string),
+		coordinator: c,
+		key:         key,
+		filter:      filter,
+		errorCh:     make(chan error),
+		streamCh:    make(chan *state.LogLine),
 	}
 }
 
 func (s *LogStream) ErrorCh() <-chan error { return s.errorCh }
 
-func (s *LogStream) StreamCh() <-chan string { return s.streamCh }
+func (s *LogStream) StreamCh() <-chan *state.LogLine { return s.streamCh }
 
+// Run streams the logs until the context is cancelled.
 func (s *LogStream) Run(ctx context.Context) {
 
-	fileTail, err := tail.TailFile(s.path,
-		tail.Config{
-			Follow: true,
-			Location: &tail.SeekInfo{
-				Offset: 0,
-				Whence: 0,
-			},
-			Logger: tail.DiscardingLogger,
-		},
-	)
+	sub, err := s.coordinator.subscribeLogs(s.key)
 	if err != nil {
-		s.errorCh <- err
+		s.sendError(ctx, err)
 		return
 	}
-
-	defer func(fileTail *tail.Tail) { _ = fileTail.Stop() }(fileTail)
+	defer sub.Close()
 
 	for {
+		if err := sub.Read(func(line *state.LogLine) bool {
+			if !s.filter.Match(line) {
+				return true
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case s.streamCh <- line:
+				return true
+			}
+		}); err != nil {
+			s.sendError(ctx, err)
+			return
+		}
+
 		select {
 		case <-ctx.Done():
 			return
-		case line := <-fileTail.Lines:
-			if line != nil {
-				s.streamCh <- line.Text
-			}
+		case <-sub.NotifyCh():
 		}
 	}
 }
+
+func (s *LogStream) sendError(ctx context.Context, err error) {
+	select {
+	case <-ctx.Done():
+	case s.errorCh <- err:
+	}
+}
@@ -0,0 +1,166 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	serverstate "github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// LatestRunReq selects the most recent run of a flow.
+type LatestRunReq struct {
+	Namespace string
+	FlowID    string
+
+	// Variables filters the runs to those where each variable matches any of
+	// the listed values. Variables are keyed by their name within the run,
+	// where trigger variables are prefixed, such as "trigger.git_ref".
+	Variables map[string][]string
+}
+
+const (
+	// latestRunMaxCandidates is the maximum number of runs of the flow read
+	// when looking for the latest run matching the variables. Older runs are
+	// not considered, so a filter which matches nothing does not read the
+	// whole run history.
+	latestRunMaxCandidates = 50
+
+	// latestRunCacheTTL is how long the latest run of a request is cached, and
+	// latestRunCacheMaxEntries the number of requests cached before the cache
+	// is cleared.
+	latestRunCacheTTL        = 10 * time.Second
+	latestRunCacheMaxEntries = 1000
+)
+
+// LatestRun returns the most recently created run of the flow which matches
+// the request. It returns nil if no run matches. Results are cached briefly,
+// as the request is used by unauthenticated badges which may be fetched
+// often.
+func (c *Coordinator) LatestRun(req *LatestRunReq) (*state.Run, error) {
+
+	key, err := req.cacheKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if run, ok := c.latestRuns.get(key); ok {
+		return run, nil
+	}
+
+	run, err := c.latestRun(req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.latestRuns.set(key, run)
+	return run, nil
+}
+
+func (c *Coordinator) latestRun(req *LatestRunReq) (*state.Run, error) {
+
+	listResp, stateErr := c.state.Runs().List(&serverstate.RunsListReq{Namespace: req.Namespace})
+	if stateErr != nil {
+		return nil, stateErr
+	}
+
+	// Run IDs are ULIDs, so sorting them in reverse orders the runs from
+	// newest to oldest.
+	slices.SortFunc(listResp.Runs, func(a, b *state.RunStub) int { return b.ID.Compare(a.ID) })
+
+	var candidates int
+
+	for _, stub := range listResp.Runs {
+
+		if stub.FlowID != req.FlowID {
+			continue
+		}
+
+		if candidates == latestRunMaxCandidates {
+			break
+		}
+		candidates++
+
+		resp, stateErr := c.state.Runs().Get(&serverstate.RunsGetReq{ID: stub.ID, Namespace: stub.Namespace})
+		if stateErr != nil {
+			return nil, stateErr
+		}
+
+		if matchRunVariables(resp.Run, req.Variables) {
+			return resp.Run, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func matchRunVariables(run *state.Run, vars map[string][]string) bool {
+	for name, values := range vars {
+		val, ok := run.Variables[name]
+		if !ok || !slices.Contains(values, fmt.Sprint(val)) {
+			return false
+		}
+	}
+	return true
+}
+
+// cacheKey returns the key identifying the request within the latest run
+// cache. Map keys are sorted when encoded, so the key is stable.
+func (r *LatestRunReq) cacheKey() (string, error) {
+	key, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode latest run request: %w", err)
+	}
+	return string(key), nil
+}
+
+// latestRunCache caches the latest run of requests for latestRunCacheTTL.
+type latestRunCache struct {
+	lock    sync.Mutex
+	entries map[string]*latestRunCacheEntry
+}
+
+type latestRunCacheEntry struct {
+	run     *state.Run
+	expires time.Time
+}
+
+func newLatestRunCache() *latestRunCache {
+	return &latestRunCache{entries: make(map[string]*latestRunCacheEntry)}
+}
+
+func (l *latestRunCache) get(key string) (*state.Run, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.run, true
+}
+
+func (l *latestRunCache) set(key string, run *state.Run) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+
+	// Drop expired entries before adding, and clear the cache entirely if the
+	// requests are varied enough to still fill it.
+	if len(l.entries) >= latestRunCacheMaxEntries {
+		for k, entry := range l.entries {
+			if now.After(entry.expires) {
+				delete(l.entries, k)
+			}
+		}
+		if len(l.entries) >= latestRunCacheMaxEntries {
+			clear(l.entries)
+		}
+	}
+
+	l.entries[key] = &latestRunCacheEntry{run: run, expires: now.Add(latestRunCacheTTL)}
+}
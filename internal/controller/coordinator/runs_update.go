@@ -0,0 +1,86 @@
+package coordinator
+
+import "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+
+// mergeRunUpdate returns the update merged into the stored run. Each runner
+// task of an inline run sends complete snapshots of the run, which are
+// delivered independently and so can arrive out of order, such as when a
+// task retries its queued updates after the controller restarts. Statuses
+// therefore only ever move forward, from pending to running to a terminal
+// status, and the first terminal status is kept. This also stops runner
+// updates from overwriting a cancelled run.
+func mergeRunUpdate(stored, update *state.Run) *state.Run {
+
+	merged := *update
+
+	if !statusAdvances(stored.Status, update.Status) {
+		merged.Status = stored.Status
+		merged.StartTime = stored.StartTime
+		merged.EndTime = stored.EndTime
+	}
+
+	if update.InlineRun != nil && stored.InlineRun != nil {
+
+		storedSteps := make(map[string]*state.InlineStep, len(stored.InlineRun.Steps))
+		for _, step := range stored.InlineRun.Steps {
+			if step != nil {
+				storedSteps[step.ID] = step
+			}
+		}
+
+		inline := *update.InlineRun
+		inline.Steps = make([]*state.InlineStep, len(update.InlineRun.Steps))
+
+		for i, step := range update.InlineRun.Steps {
+			if storedStep, ok := storedSteps[step.ID]; ok && step != nil && !statusAdvances(storedStep.Status, step.Status) {
+				step = storedStep
+			}
+			inline.Steps[i] = step
+		}
+		merged.InlineRun = &inline
+	}
+
+	if update.SpecRun != nil && stored.SpecRun != nil {
+
+		storedSpecs := make(map[string]*state.Spec, len(stored.SpecRun.Specs))
+		for _, spec := range stored.SpecRun.Specs {
+			if spec != nil {
+				storedSpecs[spec.ID] = spec
+			}
+		}
+
+		specRun := *update.SpecRun
+		specRun.Specs = make([]*state.Spec, len(update.SpecRun.Specs))
+
+		for i, spec := range update.SpecRun.Specs {
+			if storedSpec, ok := storedSpecs[spec.ID]; ok && spec != nil && !statusAdvances(storedSpec.Status, spec.Status) {
+				spec = storedSpec
+			}
+			specRun.Specs[i] = spec
+		}
+		merged.SpecRun = &specRun
+	}
+
+	return &merged
+}
+
+// statusAdvances returns whether a run, step, or specification can move from
+// the stored status to the updated status. A terminal status can only be
+// replaced by itself, which allows later updates to the rest of the object.
+func statusAdvances(stored, update string) bool {
+	if state.IsTerminalStatus(stored) {
+		return update == stored
+	}
+	return statusRank(update) >= statusRank(stored)
+}
+
+func statusRank(status string) int {
+	switch status {
+	case state.RunStatusPending:
+		return 0
+	case state.RunStatusRunning:
+		return 1
+	default:
+		return 2
+	}
+}
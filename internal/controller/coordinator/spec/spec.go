@@ -3,6 +3,7 @@ package spec
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -24,21 +25,38 @@ type SpecRunnerReq struct {
 	Trigger  string
 	UpdateCh chan *state.Run
 	Vars     map[string]any
+
+	TriggerTime time.Time
+
+	// ShutdownCh is closed when the controller is shutting down, which stops
+	// any background cleanup of the run.
+	ShutdownCh <-chan struct{}
 }
 
+// errCancelled is returned when monitoring a job is stopped due to the run
+// being cancelled.
+var errCancelled = errors.New("cancelled")
+
 type SpecRunner struct {
+	// cancel is closed when the run is cancelled.
 	cancel     chan struct{}
+	cancelOnce sync.Once
+
 	context    *context.Context
 	inProgress atomic.Value
 	req        *SpecRunnerReq
 	queryOpts  *api.QueryOptions
+
+	// workspace is the shared workspace host volume for the run, which is
+	// only set when the flow defines a workspace block.
+	workspace *workspace
 }
 
 func NewRunner(req *SpecRunnerReq) (*SpecRunner, error) {
 
 	r := SpecRunner{
 		cancel:    make(chan struct{}),
-		context:   context.New(req.RunID, req.Trigger, req.Flow, req.Vars),
+		context:   context.New(req.RunID, req.Trigger, req.TriggerTime, req.Flow, req.Vars),
 		req:       req,
 		queryOpts: &api.QueryOptions{},
 	}
@@ -60,15 +78,26 @@ func (s *SpecRunner) Start() error {
 func (s *SpecRunner) Cancel() error {
 	s.req.Logger.Info("cancelling spec runner")
 
-	select {
-	case s.cancel <- struct{}{}:
-	default:
+	s.cancelOnce.Do(func() { close(s.cancel) })
+
+	jobID, ok := s.inProgress.Load().(string)
+	if !ok {
+		return nil
 	}
 
-	_, _, err := s.req.Client.Jobs().Deregister(s.inProgress.Load().(string), false, nil)
+	_, _, err := s.req.Client.Jobs().Deregister(jobID, false, nil)
 	return err
 }
 
+func (s *SpecRunner) isCancelled() bool {
+	select {
+	case <-s.cancel:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *SpecRunner) start() {
 
 	defer func() {
@@ -79,38 +108,79 @@ func (s *SpecRunner) start() {
 	s.context.StartRun()
 	s.req.UpdateCh <- s.context.Run()
 
-	var failed bool
+	if s.req.Flow.Workspace != nil {
+		ws, err := s.createWorkspace(s.req.Flow.Workspace)
+		if err != nil {
+			s.req.Logger.Error("failed to create workspace", zap.Error(err))
+			s.context.EndRun(state.RunStatusFailed)
+			s.req.UpdateCh <- s.context.Run()
+			return
+		}
+		s.workspace = ws
+
+		// The workspace is deleted in the background, as waiting for the
+		// claims on the volume to be released should not delay the end of
+		// the run.
+		defer func() { go s.deleteWorkspace(ws) }()
+	}
+
+	var failed, cancelled bool
 
 	for _, job := range s.req.Flow.Specification {
 
-		should := true
+		// Once the run has been cancelled, the status is updated straight away
+		// so conditions can use cancelled() and updates do not regress it.
+		if !cancelled && s.isCancelled() {
+			cancelled = true
+			s.context.EndRun(state.RunStatusCancelled)
+		}
 
-		if job.Condition != "" {
-			eval, err := s.context.ParseBoolExpr(job.Condition)
-			if err != nil {
-				s.req.Logger.Error("failed to evaluate condition", zap.String("spec_id", job.ID), zap.Error(err))
-				s.context.EndRun(state.RunStatusFailed)
-				s.req.UpdateCh <- s.context.Run()
-				return
-			}
-			should = eval
+		// Conditions fully control whether a specification runs, with those
+		// that do not define one only running while the run is successful. An
+		// invalid condition only fails its own specification, so those which
+		// run on failure, such as cleanup, still run.
+		should, err := s.context.EvalCondition(job.Condition)
+		if err != nil {
+			s.req.Logger.Error("failed to evaluate condition", zap.String("spec_id", job.ID), zap.Error(err))
+			s.context.EndSpecification(job.ID, state.RunStatusFailed)
+			s.req.UpdateCh <- s.context.Run()
+			failed = true
+			continue
 		}
 
-		if !should || failed {
-			s.req.Logger.Info("skipping spec due to condition evaluation", zap.String("spec_id", job.ID))
-			s.context.EndSpecification(job.ID, state.RunStatusSkipped)
+		if !should {
+			status := state.RunStatusSkipped
+			if cancelled {
+				status = state.RunStatusCancelled
+			}
+
+			s.req.Logger.Info("skipping spec due to condition evaluation",
+				zap.String("spec_id", job.ID), zap.String("status", status))
+			s.context.EndSpecification(job.ID, status)
 			s.req.UpdateCh <- s.context.Run()
 			continue
 		}
 
-		if err := s.runSpec(job); err != nil {
+		// Specifications run after cancellation are used for cleanup, so must
+		// not be interrupted by the same cancellation.
+		var cancelCh <-chan struct{}
+		if !cancelled {
+			cancelCh = s.cancel
+		}
+
+		if err := s.runSpec(job, cancelCh); err != nil {
 			s.req.Logger.Error("specification run failed", zap.String("spec_id", job.ID), zap.Error(err))
-			failed = true
+			if !errors.Is(err, errCancelled) {
+				failed = true
+			}
 		}
 	}
 
 	endState := state.RunStatusSuccess
-	if failed {
+	switch {
+	case s.isCancelled():
+		endState = state.RunStatusCancelled
+	case failed:
 		endState = state.RunStatusFailed
 	}
 
@@ -118,7 +188,7 @@ func (s *SpecRunner) start() {
 	s.req.UpdateCh <- s.context.Run()
 }
 
-func (s *SpecRunner) runSpec(spec *state.SpecificationFlow) error {
+func (s *SpecRunner) runSpec(spec *state.SpecificationFlow, cancelCh <-chan struct{}) error {
 
 	inputVars := []string{}
 
@@ -157,11 +227,19 @@ func (s *SpecRunner) runSpec(spec *state.SpecificationFlow) error {
 
 	job.Canonicalize()
 
+	if s.workspace != nil {
+		if err := s.workspace.injectWorkspace(job); err != nil {
+			return fmt.Errorf("failed to add workspace to job: %w", err)
+		}
+	}
+
 	s.context.StartSpecification(spec.ID, *job.Namespace, *job.ID)
 	s.req.UpdateCh <- s.context.Run()
 
 	defer func() {
-		if err != nil {
+		if errors.Is(err, errCancelled) {
+			s.context.EndSpecification(spec.ID, state.RunStatusCancelled)
+		} else if err != nil {
 			s.context.EndSpecification(spec.ID, state.RunStatusFailed)
 		} else {
 			s.context.EndSpecification(spec.ID, state.RunStatusSuccess)
@@ -189,18 +267,19 @@ func (s *SpecRunner) runSpec(spec *state.SpecificationFlow) error {
 	}
 
 	s.inProgress.Store(jobID)
-	return s.monitorJob(jobID)
+	err = s.monitorJob(jobID, cancelCh)
+	return err
 }
 
-func (s *SpecRunner) monitorJob(id string) error {
+func (s *SpecRunner) monitorJob(id string, cancelCh <-chan struct{}) error {
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-s.cancel:
-			return errors.New("cancelled")
+		case <-cancelCh:
+			return errCancelled
 		case <-ticker.C:
 			job, _, err := s.req.Client.Jobs().Info(id, s.queryOpts)
 			if err != nil {
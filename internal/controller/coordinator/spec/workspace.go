@@ -0,0 +1,156 @@
+package spec
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/helper"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// workspaceVolumeName is the name of the volume block injected into each
+	// task group of the specification jobs.
+	workspaceVolumeName = "nomad-pipeline-workspace"
+
+	defaultWorkspaceNamespace = "default"
+	defaultWorkspaceMountPath = "/workspace"
+
+	// workspaceDeleteAttempts and workspaceDeleteInterval control how long the
+	// runner will wait for allocations to release their claim on the volume,
+	// before giving up on deleting it.
+	workspaceDeleteAttempts = 12
+	workspaceDeleteInterval = 5 * time.Second
+)
+
+// workspace tracks the dynamic host volume created to provide a shared
+// workspace across the specification jobs of a single run.
+type workspace struct {
+	id        string
+	name      string
+	namespace string
+	mountPath string
+
+	// nodeID is the client the volume was placed on, which the specification
+	// jobs are constrained to.
+	nodeID string
+}
+
+func (s *SpecRunner) createWorkspace(cfg *state.FlowWorkspace) (*workspace, error) {
+
+	ws := workspace{
+		name:      "nomad-pipeline-" + strings.ToLower(s.req.RunID.String()),
+		namespace: cfg.Namespace,
+		mountPath: cfg.MountPath,
+	}
+
+	if ws.namespace == "" {
+		ws.namespace = defaultWorkspaceNamespace
+	}
+	if ws.mountPath == "" {
+		ws.mountPath = defaultWorkspaceMountPath
+	}
+
+	resp, _, err := s.req.Client.HostVolumes().Create(&api.HostVolumeCreateRequest{
+		Volume: &api.HostVolume{
+			Namespace:                 ws.namespace,
+			Name:                      ws.name,
+			PluginID:                  cfg.PluginID,
+			NodePool:                  cfg.NodePool,
+			RequestedCapacityMinBytes: int64(cfg.CapacityMin) * 1024 * 1024,
+			RequestedCapacityMaxBytes: int64(cfg.CapacityMax) * 1024 * 1024,
+			RequestedCapabilities: []*api.HostVolumeCapability{
+				{
+					AttachmentMode: api.HostVolumeAttachmentModeFilesystem,
+					AccessMode:     api.HostVolumeAccessModeSingleNodeMultiWriter,
+				},
+			},
+			Parameters: cfg.Parameters,
+		},
+	}, &api.WriteOptions{Namespace: ws.namespace})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host volume: %w", err)
+	}
+
+	ws.id = resp.Volume.ID
+	ws.nodeID = resp.Volume.NodeID
+
+	s.req.Logger.Info("created workspace host volume",
+		zap.String("volume_id", ws.id),
+		zap.String("volume_name", ws.name),
+		zap.String("node_id", resp.Volume.NodeID),
+	)
+
+	return &ws, nil
+}
+
+// deleteWorkspace removes the workspace host volume. Allocations of cancelled
+// jobs may still hold a claim on the volume for a short time, so the delete is
+// retried until the claims are released, or the controller is shutting down.
+func (s *SpecRunner) deleteWorkspace(ws *workspace) {
+
+	var err error
+
+	for i := 0; i < workspaceDeleteAttempts; i++ {
+		_, _, err = s.req.Client.HostVolumes().Delete(
+			&api.HostVolumeDeleteRequest{ID: ws.id},
+			&api.WriteOptions{Namespace: ws.namespace},
+		)
+		if err == nil {
+			s.req.Logger.Info("deleted workspace host volume", zap.String("volume_id", ws.id))
+			return
+		}
+
+		select {
+		case <-s.req.ShutdownCh:
+			s.req.Logger.Warn("controller shutting down, not retrying workspace host volume delete",
+				zap.String("volume_id", ws.id), zap.Error(err))
+			return
+		case <-time.After(workspaceDeleteInterval):
+		}
+	}
+
+	s.req.Logger.Error("failed to delete workspace host volume",
+		zap.String("volume_id", ws.id), zap.Error(err))
+}
+
+// injectWorkspace adds the workspace volume to every task group of the job and
+// mounts it within every task.
+func (ws *workspace) injectWorkspace(job *api.Job) error {
+
+	if job.Namespace != nil && *job.Namespace != ws.namespace {
+		return fmt.Errorf("job namespace %q does not match workspace namespace %q",
+			*job.Namespace, ws.namespace)
+	}
+
+	if ws.nodeID != "" {
+		job.Constrain(api.NewConstraint("${node.unique.id}", "=", ws.nodeID))
+	}
+
+	for _, tg := range job.TaskGroups {
+		if tg.Volumes == nil {
+			tg.Volumes = make(map[string]*api.VolumeRequest)
+		}
+
+		tg.Volumes[workspaceVolumeName] = &api.VolumeRequest{
+			Name:           workspaceVolumeName,
+			Type:           api.CSIVolumeTypeHost,
+			Source:         ws.name,
+			AccessMode:     string(api.HostVolumeAccessModeSingleNodeMultiWriter),
+			AttachmentMode: string(api.HostVolumeAttachmentModeFilesystem),
+		}
+
+		for _, task := range tg.Tasks {
+			task.VolumeMounts = append(task.VolumeMounts, &api.VolumeMount{
+				Volume:      helper.PointerOf(workspaceVolumeName),
+				Destination: helper.PointerOf(ws.mountPath),
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,291 @@
+package fs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// logFileName is the name of the structured log file for each step, which
+// holds the lines of both output streams as JSON, one per line.
+const logFileName = "logs.jsonl"
+
+// Store implements the log store using the local filesystem of the
+// controller. Logs are written within the data directory, in the layout:
+//
+//	<namespace>/<run-id>/<step-id>/logs/logs.jsonl
+type Store struct {
+	dir string
+
+	// lens caches the number of lines within each step log, so each log is
+	// only read once to find its length.
+	lens     map[string]int
+	lensLock sync.Mutex
+}
+
+func New(dir string) *Store {
+	return &Store{
+		dir:  dir,
+		lens: make(map[string]int),
+	}
+}
+
+func (s *Store) Append(namespace, runID, stepID string, lines []*state.LogLine) error {
+
+	path := s.logPath(namespace, runID, stepID)
+
+	s.lensLock.Lock()
+	defer s.lensLock.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	// Encode the whole batch before writing, so a failure does not leave a
+	// partial batch within the file.
+	var buf []byte
+
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return fmt.Errorf("failed to encode log line: %w", err)
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+
+	if _, err := f.Write(buf); err != nil {
+		delete(s.lens, path)
+		return fmt.Errorf("failed to write log batch: %w", err)
+	}
+
+	if n, ok := s.lens[path]; ok {
+		s.lens[path] = n + len(lines)
+	}
+	return nil
+}
+
+func (s *Store) Read(namespace, runID, stepID string, offset int) ([]*state.LogLine, error) {
+
+	var lines []*state.LogLine
+
+	err := s.Stream(namespace, runID, stepID, offset, func(line *state.LogLine) bool {
+		lines = append(lines, line)
+		return true
+	})
+	return lines, err
+}
+
+func (s *Store) Stream(namespace, runID, stepID string, offset int, fn func(*state.LogLine) bool) error {
+
+	fileHandle, err := os.Open(s.logPath(namespace, runID, stepID))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		lines, err := s.readLegacy(namespace, runID, stepID, offset)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if !fn(line) {
+				return nil
+			}
+		}
+		return nil
+	}
+	defer fileHandle.Close()
+
+	scanner := bufio.NewScanner(fileHandle)
+	scanner.Buffer(nil, 1024*1024)
+
+	for idx := 0; scanner.Scan(); idx++ {
+
+		if idx < offset {
+			continue
+		}
+
+		var line state.LogLine
+
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("failed to decode log line: %w", err)
+		}
+		if !fn(&line) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readLegacy reads the plain text, per stream, log files written before lines
+// were captured with their metadata. These lines do not have a timestamp, and
+// the streams are not interleaved.
+func (s *Store) readLegacy(namespace, runID, stepID string, offset int) ([]*state.LogLine, error) {
+
+	var (
+		lines []*state.LogLine
+		found bool
+	)
+
+	for _, stream := range []string{state.LogStreamStdout, state.LogStreamStderr} {
+
+		data, err := os.ReadFile(filepath.Join(s.stepDir(namespace, runID, stepID), stream+".log"))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+
+		if len(data) == 0 {
+			continue
+		}
+
+		for _, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			lines = append(lines, &state.LogLine{
+				Stream:   stream,
+				Sequence: uint64(len(lines) + 1),
+				Text:     text,
+			})
+		}
+	}
+
+	if !found {
+		return nil, os.ErrNotExist
+	}
+	if offset >= len(lines) {
+		return nil, nil
+	}
+	return lines[offset:], nil
+}
+
+func (s *Store) Len(namespace, runID, stepID string) (int, error) {
+
+	path := s.logPath(namespace, runID, stepID)
+
+	s.lensLock.Lock()
+	defer s.lensLock.Unlock()
+
+	if n, ok := s.lens[path]; ok {
+		return n, nil
+	}
+
+	n, err := s.countLines(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+
+		// The legacy log files are never appended to, so their length is
+		// cached in the same way.
+		legacy, err := s.readLegacy(namespace, runID, stepID, 0)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, err
+		}
+		n = len(legacy)
+	}
+
+	s.lens[path] = n
+	return n, nil
+}
+
+// countLines returns the number of lines within the log file, by counting the
+// line endings rather than decoding each line.
+func (s *Store) countLines(path string) (int, error) {
+
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer fileHandle.Close()
+
+	var (
+		count int
+		buf   = make([]byte, 32*1024)
+	)
+
+	for {
+		n, err := fileHandle.Read(buf)
+		count += bytes.Count(buf[:n], []byte{'\n'})
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return count, nil
+			}
+			return 0, err
+		}
+	}
+}
+
+func (s *Store) Sequence(namespace, runID, stepID, stream string) (uint64, error) {
+
+	data, err := os.ReadFile(s.sequencePath(namespace, runID, stepID, stream))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read log sequence: %w", err)
+	}
+
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse log sequence: %w", err)
+	}
+	return seq, nil
+}
+
+func (s *Store) SetSequence(namespace, runID, stepID, stream string, seq uint64) error {
+
+	path := s.sequencePath(namespace, runID, stepID, stream)
+	tmpPath := path + ".tmp"
+
+	if err := os.WriteFile(tmpPath, []byte(strconv.FormatUint(seq, 10)), 0644); err != nil {
+		return fmt.Errorf("failed to write log sequence: %w", err)
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (s *Store) Delete(namespace, runID string) error {
+
+	runDir := filepath.Join(s.dir, namespace, runID)
+
+	s.lensLock.Lock()
+	defer s.lensLock.Unlock()
+
+	for path := range s.lens {
+		if strings.HasPrefix(path, runDir+string(filepath.Separator)) {
+			delete(s.lens, path)
+		}
+	}
+	return os.RemoveAll(runDir)
+}
+
+func (s *Store) logPath(namespace, runID, stepID string) string {
+	return filepath.Join(s.stepDir(namespace, runID, stepID), logFileName)
+}
+
+func (s *Store) sequencePath(namespace, runID, stepID, stream string) string {
+	return filepath.Join(s.stepDir(namespace, runID, stepID), fmt.Sprintf("%s.seq", stream))
+}
+
+func (s *Store) stepDir(namespace, runID, stepID string) string {
+	return filepath.Join(s.dir, namespace, runID, stepID, "logs")
+}
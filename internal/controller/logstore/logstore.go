@@ -0,0 +1,172 @@
+package logstore
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/logstore/fs"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/logstore/s3"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	BackendFilesystem = "filesystem"
+	BackendS3         = "s3"
+)
+
+// Store is the interface implemented by the log storage backends. Each step
+// log is an ordered list of lines, which is only ever appended to, so lines
+// can be referenced by their index within the log. Following logs is built on
+// top of this by the coordinator, which publishes appended lines to followers
+// and only streams from the store for history.
+type Store interface {
+
+	// Append adds the lines to the end of the step log.
+	Append(namespace, runID, stepID string, lines []*state.LogLine) error
+
+	// Read returns the lines of the step log, starting at the passed index.
+	Read(namespace, runID, stepID string, offset int) ([]*state.LogLine, error)
+
+	// Stream calls fn with each line of the step log, starting at the passed
+	// index, without holding the whole log in memory. It stops early if fn
+	// returns false. Lines appended while streaming may not be included.
+	Stream(namespace, runID, stepID string, offset int, fn func(*state.LogLine) bool) error
+
+	// Len returns the number of lines within the step log.
+	Len(namespace, runID, stepID string) (int, error)
+
+	// Sequence returns the last batch sequence number recorded for the step
+	// log stream, or zero if none has been recorded.
+	Sequence(namespace, runID, stepID, stream string) (uint64, error)
+
+	// SetSequence records the last batch sequence number written for the step
+	// log stream.
+	SetSequence(namespace, runID, stepID, stream string, seq uint64) error
+
+	// Delete removes the logs of all steps within the run.
+	Delete(namespace, runID string) error
+}
+
+type Config struct {
+	Backend string    `hcl:"backend,optional"`
+	S3      *S3Config `hcl:"s3,block"`
+}
+
+// S3Config configures the S3 backend, which can be used with any S3
+// compatible object store, such as MinIO.
+type S3Config struct {
+	Endpoint string `hcl:"endpoint,optional"`
+	Bucket   string `hcl:"bucket,optional"`
+	Prefix   string `hcl:"prefix,optional"`
+	Region   string `hcl:"region,optional"`
+
+	// AccessKeyID and SecretAccessKey are optional, and when not set the
+	// credentials are read from the environment or instance metadata.
+	AccessKeyID     string `hcl:"access_key_id,optional"`
+	SecretAccessKey string `hcl:"secret_access_key,optional"`
+	SessionToken    string `hcl:"session_token,optional"`
+
+	// Insecure disables TLS when connecting to the endpoint.
+	Insecure bool `hcl:"insecure,optional"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Backend: BackendFilesystem,
+		S3: &S3Config{
+			Endpoint: "s3.amazonaws.com",
+			Prefix:   "nomad-pipeline",
+		},
+	}
+}
+
+func (c *Config) Merge(other *Config) *Config {
+	if c == nil {
+		return other
+	}
+
+	result := *c
+
+	if other.Backend != "" {
+		result.Backend = other.Backend
+	}
+
+	if other.S3 != nil {
+		if result.S3 == nil {
+			result.S3 = &S3Config{}
+		}
+		s3 := *result.S3
+
+		if other.S3.Endpoint != "" {
+			s3.Endpoint = other.S3.Endpoint
+		}
+		if other.S3.Bucket != "" {
+			s3.Bucket = other.S3.Bucket
+		}
+		if other.S3.Prefix != "" {
+			s3.Prefix = other.S3.Prefix
+		}
+		if other.S3.Region != "" {
+			s3.Region = other.S3.Region
+		}
+		if other.S3.AccessKeyID != "" {
+			s3.AccessKeyID = other.S3.AccessKeyID
+		}
+		if other.S3.SecretAccessKey != "" {
+			s3.SecretAccessKey = other.S3.SecretAccessKey
+		}
+		if other.S3.SessionToken != "" {
+			s3.SessionToken = other.S3.SessionToken
+		}
+		if other.S3.Insecure {
+			s3.Insecure = other.S3.Insecure
+		}
+		result.S3 = &s3
+	}
+
+	return &result
+}
+
+func (c *Config) Validate() error {
+	switch c.Backend {
+	case BackendFilesystem:
+		return nil
+	case BackendS3:
+		if c.S3 == nil || c.S3.Endpoint == "" {
+			return errors.New("s3 log storage requires an endpoint")
+		}
+		if c.S3.Bucket == "" {
+			return errors.New("s3 log storage requires a bucket")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported log storage backend: %s", c.Backend)
+	}
+}
+
+// NewStore creates the configured log store. The data directory is used by
+// the filesystem backend.
+func NewStore(cfg *Config, dataDir string, logger *zap.Logger) (Store, error) {
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch cfg.Backend {
+	case BackendS3:
+		return s3.New(&s3.Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Bucket:          cfg.S3.Bucket,
+			Prefix:          cfg.S3.Prefix,
+			Region:          cfg.S3.Region,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.SecretAccessKey,
+			SessionToken:    cfg.S3.SessionToken,
+			Insecure:        cfg.S3.Insecure,
+		}, logger)
+	default:
+		return fs.New(dataDir), nil
+	}
+}
@@ -0,0 +1,382 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/logger"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// requestTimeout is the maximum time allowed for each object store operation.
+const requestTimeout = 30 * time.Second
+
+type Config struct {
+	Endpoint        string
+	Bucket          string
+	Prefix          string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Insecure        bool
+}
+
+// Store implements the log store using an S3 compatible object store. Objects
+// cannot be appended to, so each batch of lines is written as its own object,
+// named using the index range of the lines it holds:
+//
+//	<prefix>/<namespace>/<run-id>/<step-id>/lines/<start>-<end>.jsonl
+//
+// The zero padded indexes mean listing the objects returns them in order, and
+// the length of the log can be found from the name of the last object.
+//
+// The store may be shared by multiple controllers, so the length of each log
+// is found by listing before every append, rather than trusting a length held
+// in memory which another controller may have since appended past. Appends to
+// the same step log by multiple controllers at the same time are not
+// supported, as each could write a batch starting at the same index.
+type Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+	logger *zap.Logger
+
+	// lens caches the number of lines within each step log, when last seen by
+	// this controller. It is used to only list the objects written since.
+	lens     map[string]int
+	lensLock sync.Mutex
+}
+
+func New(cfg *Config, zLogger *zap.Logger) (*Store, error) {
+
+	creds := credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)
+
+	if cfg.AccessKeyID == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.IAM{},
+		})
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check S3 bucket: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("S3 bucket %q does not exist", cfg.Bucket)
+	}
+
+	return &Store{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: cfg.Prefix,
+		logger: zLogger.Named(logger.ComponentNameLogStore),
+		lens:   make(map[string]int),
+	}, nil
+}
+
+func (s *Store) Append(namespace, runID, stepID string, lines []*state.LogLine) error {
+
+	if len(lines) == 0 {
+		return nil
+	}
+
+	s.lensLock.Lock()
+	defer s.lensLock.Unlock()
+
+	linesPrefix := s.linesPrefix(namespace, runID, stepID)
+
+	start, err := s.len(linesPrefix)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+
+	for _, line := range lines {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return fmt.Errorf("failed to encode log line: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	end := start + len(lines)
+	name := fmt.Sprintf("%s%020d-%020d.jsonl", linesPrefix, start, end)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := s.client.PutObject(ctx, s.bucket, name, &buf, int64(buf.Len()),
+		minio.PutObjectOptions{ContentType: "application/x-ndjson"}); err != nil {
+		delete(s.lens, linesPrefix)
+		return fmt.Errorf("failed to write log batch: %w", err)
+	}
+
+	s.lens[linesPrefix] = end
+	return nil
+}
+
+func (s *Store) Read(namespace, runID, stepID string, offset int) ([]*state.LogLine, error) {
+
+	var lines []*state.LogLine
+
+	err := s.Stream(namespace, runID, stepID, offset, func(line *state.LogLine) bool {
+		lines = append(lines, line)
+		return true
+	})
+	return lines, err
+}
+
+func (s *Store) Stream(namespace, runID, stepID string, offset int, fn func(*state.LogLine) bool) error {
+
+	objects, err := s.listLines(s.linesPrefix(namespace, runID, stepID), 0)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objects {
+		if obj.end <= offset {
+			continue
+		}
+
+		more, err := s.streamObject(obj.name, max(offset-obj.start, 0), fn)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Len(namespace, runID, stepID string) (int, error) {
+
+	linesPrefix := s.linesPrefix(namespace, runID, stepID)
+
+	s.lensLock.Lock()
+	defer s.lensLock.Unlock()
+
+	return s.len(linesPrefix)
+}
+
+func (s *Store) Sequence(namespace, runID, stepID, stream string) (uint64, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	obj, err := s.client.GetObject(ctx, s.bucket, s.sequenceName(namespace, runID, stepID, stream), minio.GetObjectOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read log sequence: %w", err)
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read log sequence: %w", err)
+	}
+
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse log sequence: %w", err)
+	}
+	return seq, nil
+}
+
+func (s *Store) SetSequence(namespace, runID, stepID, stream string, seq uint64) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	data := []byte(strconv.FormatUint(seq, 10))
+
+	if _, err := s.client.PutObject(ctx, s.bucket, s.sequenceName(namespace, runID, stepID, stream),
+		bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "text/plain"}); err != nil {
+		return fmt.Errorf("failed to write log sequence: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) Delete(namespace, runID string) error {
+
+	runPrefix := path.Join(s.prefix, namespace, runID) + "/"
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	objectsCh := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: runPrefix, Recursive: true})
+
+	for removeErr := range s.client.RemoveObjects(ctx, s.bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+		return fmt.Errorf("failed to delete %q: %w", removeErr.ObjectName, removeErr.Err)
+	}
+
+	s.lensLock.Lock()
+	for key := range s.lens {
+		if strings.HasPrefix(key, runPrefix) {
+			delete(s.lens, key)
+		}
+	}
+	s.lensLock.Unlock()
+
+	s.logger.Debug("deleted run logs", zap.String("namespace", namespace), zap.String("run_id", runID))
+
+	return nil
+}
+
+// linesObject is a batch of log lines, holding the lines from the start index
+// up to, but not including, the end index.
+type linesObject struct {
+	name  string
+	start int
+	end   int
+}
+
+// listLines lists the objects of the step log, which hold lines from the
+// passed index onwards.
+func (s *Store) listLines(linesPrefix string, from int) ([]*linesObject, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	opts := minio.ListObjectsOptions{Prefix: linesPrefix}
+
+	// Objects are named by their start index, so those starting before the
+	// index sort before the key and are skipped by the object store.
+	if from > 0 {
+		opts.StartAfter = fmt.Sprintf("%s%020d-", linesPrefix, from)
+	}
+
+	var objects []*linesObject
+
+	for info := range s.client.ListObjects(ctx, s.bucket, opts) {
+		if info.Err != nil {
+			return nil, fmt.Errorf("failed to list log objects: %w", info.Err)
+		}
+
+		start, end, ok := parseLinesName(strings.TrimPrefix(info.Key, linesPrefix))
+		if !ok {
+			s.logger.Warn("ignoring unexpected log object", zap.String("key", info.Key))
+			continue
+		}
+		objects = append(objects, &linesObject{name: info.Key, start: start, end: end})
+	}
+
+	return objects, nil
+}
+
+// streamObject calls fn with each line of the object, after skipping the
+// passed number of lines. It returns false if fn stopped the stream.
+func (s *Store) streamObject(name string, skip int, fn func(*state.LogLine) bool) (bool, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	obj, err := s.client.GetObject(ctx, s.bucket, name, minio.GetObjectOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to read log object: %w", err)
+	}
+	defer obj.Close()
+
+	scanner := bufio.NewScanner(obj)
+	scanner.Buffer(nil, 1024*1024)
+
+	for idx := 0; scanner.Scan(); idx++ {
+
+		if idx < skip {
+			continue
+		}
+
+		var line state.LogLine
+
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return false, fmt.Errorf("failed to decode log line: %w", err)
+		}
+		if !fn(&line) {
+			return false, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("failed to read log object: %w", err)
+	}
+	return true, nil
+}
+
+// len returns the number of lines within the step log, from the name of its
+// last object. Only the objects written since the cached length are listed,
+// which is usually none. The caller must hold the lens lock.
+func (s *Store) len(linesPrefix string) (int, error) {
+
+	n := s.lens[linesPrefix]
+
+	objects, err := s.listLines(linesPrefix, n)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(objects) > 0 {
+		n = objects[len(objects)-1].end
+	}
+
+	s.lens[linesPrefix] = n
+	return n, nil
+}
+
+func (s *Store) linesPrefix(namespace, runID, stepID string) string {
+	return path.Join(s.prefix, namespace, runID, stepID, "lines") + "/"
+}
+
+func (s *Store) sequenceName(namespace, runID, stepID, stream string) string {
+	return path.Join(s.prefix, namespace, runID, stepID, stream+".seq")
+}
+
+func parseLinesName(name string) (int, int, bool) {
+
+	startStr, endStr, ok := strings.Cut(strings.TrimSuffix(name, ".jsonl"), "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err := strconv.Atoi(endStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
@@ -1,20 +1,24 @@
 package server
 
 import (
+	"net/url"
+
 	"github.com/urfave/cli/v3"
 	"go.uber.org/zap"
 
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/logstore"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/state"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/logger"
 )
 
 type Config struct {
-	Data  *DataConfig    `hcl:"data,optional"`
-	Log   *logger.Config `hcl:"log,optional"`
-	HTTP  *HTTPConfig    `hcl:"http,optional"`
-	Nomad *NomadConfig   `hcl:"nomad,optional"`
-	RPC   *RPCConfig     `hcl:"rpc,optional"`
-	State *state.Config  `hcl:"state,optional"`
+	Data       *DataConfig      `hcl:"data,optional"`
+	Log        *logger.Config   `hcl:"log,optional"`
+	LogStorage *logstore.Config `hcl:"log_storage,optional"`
+	HTTP       *HTTPConfig      `hcl:"http,optional"`
+	Nomad      *NomadConfig     `hcl:"nomad,optional"`
+	RPC        *RPCConfig       `hcl:"rpc,optional"`
+	State      *state.Config    `hcl:"state,optional"`
 }
 
 type DataConfig struct {
@@ -24,6 +28,11 @@ type DataConfig struct {
 type HTTPConfig struct {
 	Addr           string `hcl:"addr,optional"`
 	AccessLogLevel string `hcl:"access_log_level,optional"`
+
+	// PublicURL is the URL clients use to reach the HTTP server, such as when
+	// it is behind a load balancer. Defaults to the address, when it includes
+	// the scheme.
+	PublicURL string `hcl:"public_url,optional"`
 }
 
 type RPCConfig struct {
@@ -35,12 +44,26 @@ type NomadConfig struct {
 	Token string `hcl:"token,optional"`
 }
 
+// publicURL returns the URL clients use to reach the HTTP server. The address
+// is only used when it is a URL, as a listen address such as ":8080" cannot be
+// linked to, and an empty string is returned otherwise.
+func (c *HTTPConfig) publicURL() string {
+	if c.PublicURL != "" {
+		return c.PublicURL
+	}
+	if u, err := url.Parse(c.Addr); err == nil && u.Scheme != "" && u.Host != "" {
+		return c.Addr
+	}
+	return ""
+}
+
 func DefaultConfig() *Config {
 	return &Config{
 		Data: &DataConfig{
 			Path: "/tmp/nomad-pipeline/data",
 		},
-		Log: logger.DefaultControlerConfig(),
+		Log:        logger.DefaultControlerConfig(),
+		LogStorage: logstore.DefaultConfig(),
 		HTTP: &HTTPConfig{
 			Addr:           "http://localhost:8080",
 			AccessLogLevel: zap.DebugLevel.String(),
@@ -62,11 +85,56 @@ func Flags() []cli.Flag {
 			Usage:   "The path to the data directory",
 			Sources: cli.EnvVars("NOMAD_PIPELINE_DATA_DIR"),
 		},
+		&cli.StringFlag{
+			Name:    "log-storage-backend",
+			Usage:   "The log storage backend to use (filesystem, s3)",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_BACKEND"),
+		},
+		&cli.StringFlag{
+			Name:    "log-storage-s3-endpoint",
+			Usage:   "The S3 compatible endpoint used to store logs",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_ENDPOINT"),
+		},
+		&cli.StringFlag{
+			Name:    "log-storage-s3-bucket",
+			Usage:   "The S3 bucket used to store logs",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_BUCKET"),
+		},
+		&cli.StringFlag{
+			Name:    "log-storage-s3-prefix",
+			Usage:   "The S3 object key prefix used to store logs",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_PREFIX"),
+		},
+		&cli.StringFlag{
+			Name:    "log-storage-s3-region",
+			Usage:   "The S3 region of the log storage bucket",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_REGION"),
+		},
+		&cli.StringFlag{
+			Name:    "log-storage-s3-access-key-id",
+			Usage:   "The S3 access key ID used to store logs",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_ACCESS_KEY_ID"),
+		},
+		&cli.StringFlag{
+			Name:    "log-storage-s3-secret-access-key",
+			Usage:   "The S3 secret access key used to store logs",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_SECRET_ACCESS_KEY"),
+		},
+		&cli.BoolFlag{
+			Name:    "log-storage-s3-insecure",
+			Usage:   "Disable TLS when connecting to the S3 endpoint",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_LOG_STORAGE_S3_INSECURE"),
+		},
 		&cli.StringFlag{
 			Name:    "http-addr",
 			Usage:   "The HTTP server address",
 			Sources: cli.EnvVars("NOMAD_PIPELINE_HTTP_ADDR"),
 		},
+		&cli.StringFlag{
+			Name:    "http-public-url",
+			Usage:   "The URL clients use to reach the HTTP server, used when linking to runs",
+			Sources: cli.EnvVars("NOMAD_PIPELINE_HTTP_PUBLIC_URL"),
+		},
 		&cli.StringFlag{
 			Name:    "http-access-log-level",
 			Usage:   "The HTTP access log level (debug, info, warn, error)",
@@ -105,9 +173,22 @@ func ConfigFromCLI(cmd *cli.Command) *Config {
 		Data: &DataConfig{
 			Path: cmd.String("data-dir"),
 		},
+		LogStorage: &logstore.Config{
+			Backend: cmd.String("log-storage-backend"),
+			S3: &logstore.S3Config{
+				Endpoint:        cmd.String("log-storage-s3-endpoint"),
+				Bucket:          cmd.String("log-storage-s3-bucket"),
+				Prefix:          cmd.String("log-storage-s3-prefix"),
+				Region:          cmd.String("log-storage-s3-region"),
+				AccessKeyID:     cmd.String("log-storage-s3-access-key-id"),
+				SecretAccessKey: cmd.String("log-storage-s3-secret-access-key"),
+				Insecure:        cmd.Bool("log-storage-s3-insecure"),
+			},
+		},
 		HTTP: &HTTPConfig{
 			Addr:           cmd.String("http-addr"),
 			AccessLogLevel: cmd.String("http-access-log-level"),
+			PublicURL:      cmd.String("http-public-url"),
 		},
 		Nomad: &NomadConfig{
 			Addr:  cmd.String("nomad-addr"),
@@ -153,6 +234,9 @@ func (c *Config) Merge(other *Config) *Config {
 		if other.HTTP.AccessLogLevel != "" {
 			result.HTTP.AccessLogLevel = other.HTTP.AccessLogLevel
 		}
+		if other.HTTP.PublicURL != "" {
+			result.HTTP.PublicURL = other.HTTP.PublicURL
+		}
 	}
 
 	if other.Nomad != nil {
@@ -176,6 +260,13 @@ func (c *Config) Merge(other *Config) *Config {
 		}
 	}
 
+	if other.LogStorage != nil {
+		if result.LogStorage == nil {
+			result.LogStorage = &logstore.Config{}
+		}
+		result.LogStorage = result.LogStorage.Merge(other.LogStorage)
+	}
+
 	if other.State != nil {
 		if result.State == nil {
 			result.State = &state.Config{}
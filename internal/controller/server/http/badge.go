@@ -0,0 +1,104 @@
+package http
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	sharedstate "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// badge is a status badge, made up of a label and a coloured message. The
+// colour is one of the named colours supported by shields.io, so the JSON
+// representation can be consumed by its endpoint badges.
+type badge struct {
+	Label   string
+	Message string
+	Color   string
+}
+
+// badgeColors maps the named badge colours to the colour used when rendering
+// the badge as SVG.
+var badgeColors = map[string]string{
+	"brightgreen": "#4c1",
+	"red":         "#e05d44",
+	"yellow":      "#dfb317",
+	"blue":        "#007ec6",
+	"lightgrey":   "#9f9f9f",
+}
+
+// newRunBadge returns the badge describing the status of the run, which may be
+// nil if the flow has no matching runs.
+func newRunBadge(label string, run *sharedstate.Run) *badge {
+
+	b := badge{Label: label, Message: "unknown", Color: "lightgrey"}
+
+	if run == nil {
+		return &b
+	}
+
+	switch run.Status {
+	case sharedstate.RunStatusSuccess:
+		b.Message, b.Color = "passing", "brightgreen"
+	case sharedstate.RunStatusFailed:
+		b.Message, b.Color = "failing", "red"
+	case sharedstate.RunStatusPending:
+		b.Message, b.Color = "pending", "yellow"
+	case sharedstate.RunStatusRunning:
+		b.Message, b.Color = "running", "blue"
+	default:
+		b.Message = run.Status
+	}
+
+	return &b
+}
+
+// badgeTextWidth estimates the rendered width, in pixels, of the text using
+// the 11px Verdana font of the badge.
+func badgeTextWidth(s string) int {
+	return utf8.RuneCountInString(s)*7 + 10
+}
+
+// SVG renders the badge in the flat style used by shields.io.
+func (b *badge) SVG() []byte {
+
+	var (
+		labelWidth   = badgeTextWidth(b.Label)
+		messageWidth = badgeTextWidth(b.Message)
+		width        = labelWidth + messageWidth
+		label        = html.EscapeString(b.Label)
+		message      = html.EscapeString(b.Message)
+	)
+
+	color, ok := badgeColors[b.Color]
+	if !ok {
+		color = badgeColors["lightgrey"]
+	}
+
+	var svg strings.Builder
+
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`,
+		width, label, message)
+	fmt.Fprintf(&svg, `<title>%s: %s</title>`, label, message)
+	svg.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&svg, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, width)
+	fmt.Fprintf(&svg, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`,
+		labelWidth, labelWidth, messageWidth, color, width)
+	svg.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+
+	for _, text := range []struct {
+		x int
+		s string
+	}{
+		{x: labelWidth / 2, s: label},
+		{x: labelWidth + messageWidth/2, s: message},
+	} {
+		fmt.Fprintf(&svg, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`,
+			text.x, text.s, text.x, text.s)
+	}
+
+	svg.WriteString(`</g></svg>`)
+
+	return []byte(svg.String())
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/oklog/ulid/v2"
@@ -36,6 +38,8 @@ func (f flowsEndpoint) routes() chi.Router {
 		r.Delete("/", f.delete)
 		r.Get("/", f.get)
 		r.Post("/run", f.run)
+		r.Get("/badge.svg", f.badgeSVG)
+		r.Get("/badge.json", f.badgeJSON)
 	})
 
 	return router
@@ -172,6 +176,7 @@ func (f flowsEndpoint) run(w http.ResponseWriter, r *http.Request) {
 		r.Context().Value("id").(string),
 		getNamespaceParam(r),
 		"manual",
+		time.Now(),
 		req.Variables,
 	)
 	if err != nil {
@@ -186,6 +191,98 @@ func (f flowsEndpoint) run(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// badgeVariablePrefix is the prefix of query parameters which filter the runs
+// used for a badge by a run variable, such as var.trigger.git_ref=main.
+const badgeVariablePrefix = "var."
+
+// badge builds the status badge of the flow from its latest run matching the
+// request parameters.
+func (f flowsEndpoint) badge(r *http.Request) (*badge, error) {
+
+	flowID := r.Context().Value("id").(string)
+
+	if _, err := f.state.Flows().Get(&state.FlowsGetReq{
+		ID:        flowID,
+		Namespace: getNamespaceParam(r),
+	}); err != nil {
+		return nil, NewResponseError(err.Err(), err.StatusCode())
+	}
+
+	req := coordinator.LatestRunReq{
+		Namespace: getNamespaceParam(r),
+		FlowID:    flowID,
+		Variables: make(map[string][]string),
+	}
+
+	for key, values := range r.URL.Query() {
+		if name, ok := strings.CutPrefix(key, badgeVariablePrefix); ok && name != "" {
+			req.Variables[name] = values
+		}
+	}
+
+	// Git triggers set the full ref of the push, so the branch matches either
+	// form.
+	if branch := r.URL.Query().Get("branch"); branch != "" {
+		req.Variables["trigger.git_ref"] = []string{branch, "refs/heads/" + branch}
+	}
+
+	run, err := f.runController.LatestRun(&req)
+	if err != nil {
+		return nil, NewResponseError(err, http.StatusInternalServerError)
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = flowID
+	}
+
+	return newRunBadge(label, run), nil
+}
+
+func (f flowsEndpoint) badgeSVG(w http.ResponseWriter, r *http.Request) {
+
+	b, err := f.badge(r)
+	if err != nil {
+		httpWriteResponseError(w, err)
+		return
+	}
+
+	// Badges are usually embedded within pages which are cached, so ask any
+	// intermediate cache to always check for a newer status.
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	w.Header().Set("Content-Type", "image/svg+xml")
+	_, _ = w.Write(b.SVG())
+}
+
+// FlowBadgeResp is the badge in the shields.io endpoint badge schema.
+type FlowBadgeResp struct {
+	SchemaVersion        int    `json:"schemaVersion"`
+	Label                string `json:"label"`
+	Message              string `json:"message"`
+	Color                string `json:"color"`
+	internalResponseMeta `json:"-"`
+}
+
+func (f flowsEndpoint) badgeJSON(w http.ResponseWriter, r *http.Request) {
+
+	b, err := f.badge(r)
+	if err != nil {
+		httpWriteResponseError(w, err)
+		return
+	}
+
+	resp := FlowBadgeResp{
+		SchemaVersion:        1,
+		Label:                b.Label,
+		Message:              b.Message,
+		Color:                b.Color,
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	httpWriteResponse(w, &resp)
+}
+
 func (f flowsEndpoint) context(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
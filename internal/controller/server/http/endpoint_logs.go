@@ -0,0 +1,135 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/coordinator"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
+	sharedstate "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+type logsEndpoint struct {
+	coordinator *coordinator.Coordinator
+	state       state.State
+}
+
+func (le logsEndpoint) routes() chi.Router {
+	router := chi.NewRouter()
+
+	router.Route("/search", func(r chi.Router) {
+		r.Use(namespaceCheckMiddleware(le.state))
+		r.Get("/", le.search)
+	})
+
+	return router
+}
+
+type LogsSearchResp struct {
+	Matches              []*sharedstate.LogMatch `json:"matches"`
+	Truncated            bool                    `json:"truncated"`
+	internalResponseMeta `json:"-"`
+}
+
+// search searches the logs of all runs within the namespace. The since and
+// until parameters filter the runs searched by their create time, and since
+// defaults to the default search window.
+func (le logsEndpoint) search(w http.ResponseWriter, r *http.Request) {
+
+	var (
+		stream = r.URL.Query().Get("type")
+		req    = coordinator.RunsLogsSearchReq{
+			Namespace: getNamespaceParam(r),
+			FlowID:    r.URL.Query().Get("flow"),
+		}
+	)
+
+	switch stream {
+	case "":
+		stream = sharedstate.LogStreamCombined
+	case sharedstate.LogStreamStdout, sharedstate.LogStreamStderr, sharedstate.LogStreamCombined:
+	default:
+		httpWriteResponseError(w, NewResponseError(fmt.Errorf("unsupported type %q", stream), http.StatusBadRequest))
+		return
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			httpWriteResponseError(w, NewResponseError(fmt.Errorf("failed to parse since: %w", err), http.StatusBadRequest))
+			return
+		}
+		req.Since = t
+	}
+	if until := r.URL.Query().Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339Nano, until)
+		if err != nil {
+			httpWriteResponseError(w, NewResponseError(fmt.Errorf("failed to parse until: %w", err), http.StatusBadRequest))
+			return
+		}
+		req.Until = t
+	}
+
+	query, err := parseLogsSearchQuery(r, &coordinator.LogsFilter{Stream: stream})
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+	if err := query.Validate(); err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+
+	result, err := le.coordinator.SearchLogs(r.Context(), &req, query)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusInternalServerError))
+		return
+	}
+
+	resp := LogsSearchResp{
+		Matches:              result.Matches,
+		Truncated:            result.Truncated,
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+	httpWriteResponse(w, &resp)
+}
+
+// parseLogsSearchQuery parses the search query parameters shared by the run
+// and namespace log search endpoints.
+func parseLogsSearchQuery(r *http.Request, filter *coordinator.LogsFilter) (*coordinator.LogsSearchQuery, error) {
+
+	query := coordinator.LogsSearchQuery{
+		Query:  r.URL.Query().Get("q"),
+		Filter: filter,
+	}
+
+	if regex := r.URL.Query().Get("regex"); regex != "" {
+		b, err := strconv.ParseBool(regex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse regex: %w", err)
+		}
+		query.Regex = b
+	}
+
+	if context := r.URL.Query().Get("context"); context != "" {
+		i, err := strconv.Atoi(context)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse context: %w", err)
+		}
+		query.Context = i
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		i, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse limit: %w", err)
+		}
+		query.Limit = i
+	}
+
+	return &query, nil
+}
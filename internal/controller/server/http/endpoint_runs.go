@@ -2,10 +2,12 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/oklog/ulid/v2"
@@ -40,6 +42,10 @@ func (re runsEndpoint) routes() chi.Router {
 		})
 		r.Route("/logs", func(r chi.Router) {
 			r.Get("/", re.logs)
+			r.Get("/search", re.logsSearch)
+		})
+		r.Route("/tests", func(r chi.Router) {
+			r.Get("/", re.tests)
 		})
 	})
 
@@ -90,13 +96,19 @@ type RunDeleteResp struct {
 
 func (re runsEndpoint) delete(w http.ResponseWriter, r *http.Request) {
 
+	id := r.Context().Value("id").(ulid.ULID)
+	namespace := getNamespaceParam(r)
+
 	_, err := re.state.Runs().Delete(&state.RunsDeleteReq{
-		ID:        r.Context().Value("id").(ulid.ULID),
-		Namespace: getNamespaceParam(r),
+		ID:        id,
+		Namespace: namespace,
 	})
 	if err != nil {
 		respErr := NewResponseError(err.Err(), err.StatusCode())
 		httpWriteResponseError(w, respErr)
+	} else if err := re.coordinator.DeleteLogs(namespace, id.String()); err != nil {
+		respErr := NewResponseError(fmt.Errorf("failed to delete run logs: %w", err), http.StatusInternalServerError)
+		httpWriteResponseError(w, respErr)
 	} else {
 		resp := RunDeleteResp{
 			internalResponseMeta: newInternalResponseMeta(http.StatusOK),
@@ -130,27 +142,65 @@ func (re runsEndpoint) get(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type RunTestsResp struct {
+	Summary              *sharedstate.TestReport       `json:"summary"`
+	Steps                []*sharedstate.StepTestReport `json:"steps"`
+	internalResponseMeta `json:"-"`
+}
+
+func (re runsEndpoint) tests(w http.ResponseWriter, r *http.Request) {
+
+	stateResp, err := re.state.Runs().Get(&state.RunsGetReq{
+		ID:        r.Context().Value("id").(ulid.ULID),
+		Namespace: getNamespaceParam(r),
+	})
+	if err != nil {
+		respErr := NewResponseError(err.Err(), err.StatusCode())
+		httpWriteResponseError(w, respErr)
+		return
+	}
+
+	summary, steps := stateResp.Run.TestReports()
+
+	resp := RunTestsResp{
+		Summary:              summary,
+		Steps:                steps,
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+	httpWriteResponse(w, &resp)
+}
+
 type RunsLogsReq struct {
 	StepID string `json:"step_id"`
 	Tail   bool   `json:"tail"`
 }
 
 type RunsLogsResp struct {
-	Logs                 []string `json:"logs"`
+	Logs                 []*sharedstate.LogLine `json:"logs"`
 	internalResponseMeta `json:"-"`
 }
 
 func (re runsEndpoint) logs(w http.ResponseWriter, r *http.Request) {
 
-	tail, err := strconv.ParseBool(r.URL.Query().Get("tail"))
+	// The follow parameter is an alias of tail, and takes precedence if both
+	// are provided.
+	follow := r.URL.Query().Get("follow")
+	if follow == "" {
+		follow = r.URL.Query().Get("tail")
+	}
+
+	tail, err := strconv.ParseBool(follow)
 	if err != nil {
 		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
 		return
 	}
 
-	if tail {
+	switch {
+	case tail && r.URL.Query().Get("step_id") == "":
+		re.logsStreamRun(w, r)
+	case tail:
 		re.logsStream(w, r)
-	} else {
+	default:
 		re.logsGet(w, r)
 	}
 }
@@ -159,14 +209,9 @@ func (re runsEndpoint) logsGet(w http.ResponseWriter, r *http.Request) {
 
 	runID := r.Context().Value("id").(ulid.ULID)
 
-	var stepID, logType string
-
-	if stepID = r.URL.Query().Get("step_id"); stepID == "" {
-		httpWriteResponseError(w, NewResponseError(errors.New("step_id not provided"), http.StatusBadRequest))
-		return
-	}
-	if logType = r.URL.Query().Get("type"); logType == "" {
-		httpWriteResponseError(w, NewResponseError(errors.New("type not provided"), http.StatusBadRequest))
+	stepID, filter, err := parseLogsParams(r)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
 		return
 	}
 
@@ -174,7 +219,7 @@ func (re runsEndpoint) logsGet(w http.ResponseWriter, r *http.Request) {
 		getNamespaceParam(r),
 		runID.String(),
 		stepID,
-		logType,
+		filter,
 	)
 	if err != nil {
 		respErr := NewResponseError(err, http.StatusInternalServerError)
@@ -196,18 +241,13 @@ func (re runsEndpoint) logsStream(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var stepID, logType string
-
-	if stepID = r.URL.Query().Get("step_id"); stepID == "" {
-		httpWriteResponseError(w, NewResponseError(errors.New("step_id not provided"), http.StatusBadRequest))
-		return
-	}
-	if logType = r.URL.Query().Get("type"); logType == "" {
-		httpWriteResponseError(w, NewResponseError(errors.New("type not provided"), http.StatusBadRequest))
+	stepID, filter, err := parseLogsParams(r)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Type", "application/x-ndjson")
 
 	id := r.Context().Value("id").(ulid.ULID)
 
@@ -215,10 +255,12 @@ func (re runsEndpoint) logsStream(w http.ResponseWriter, r *http.Request) {
 		getNamespaceParam(r),
 		id.String(),
 		stepID,
-		logType,
+		filter,
 	)
 
-	go logStreamer.Run(context.Background())
+	enc := json.NewEncoder(w)
+
+	go logStreamer.Run(r.Context())
 
 	for {
 		select {
@@ -228,12 +270,145 @@ func (re runsEndpoint) logsStream(w http.ResponseWriter, r *http.Request) {
 			httpWriteResponseError(w, err)
 			return
 		case line := <-logStreamer.StreamCh():
-			_, _ = fmt.Fprint(w, line+"\n")
+			_ = enc.Encode(line)
 			flusher.Flush()
 		}
 	}
 }
 
+// logsStreamRun streams the logs of all steps within the run, in execution
+// order, ending the response once the run is terminal.
+func (re runsEndpoint) logsStreamRun(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httpWriteResponseError(w, NewResponseError(errors.New("streaming not supported"), http.StatusInternalServerError))
+		return
+	}
+
+	filter, err := parseLogsFilter(r, sharedstate.LogStreamCombined)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+
+	id := r.Context().Value("id").(ulid.ULID)
+
+	logStreamer := re.coordinator.StreamRunLogs(getNamespaceParam(r), id, filter)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	enc := json.NewEncoder(w)
+
+	go logStreamer.Run(r.Context())
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-logStreamer.DoneCh():
+			return
+		case err := <-logStreamer.ErrorCh():
+			httpWriteResponseError(w, err)
+			return
+		case line := <-logStreamer.StreamCh():
+			_ = enc.Encode(line)
+			flusher.Flush()
+		}
+	}
+}
+
+func (re runsEndpoint) logsSearch(w http.ResponseWriter, r *http.Request) {
+
+	filter, err := parseLogsFilter(r, sharedstate.LogStreamCombined)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+
+	query, err := parseLogsSearchQuery(r, filter)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+	if err := query.Validate(); err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+
+	result, err := re.coordinator.SearchRunLogs(
+		r.Context(),
+		getNamespaceParam(r),
+		r.Context().Value("id").(ulid.ULID),
+		r.URL.Query().Get("step_id"),
+		query,
+	)
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusInternalServerError))
+		return
+	}
+
+	resp := LogsSearchResp{
+		Matches:              result.Matches,
+		Truncated:            result.Truncated,
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+	httpWriteResponse(w, &resp)
+}
+
+// parseLogsParams parses the step ID and filter of a step logs request from
+// the query parameters.
+func parseLogsParams(r *http.Request) (string, *coordinator.LogsFilter, error) {
+
+	stepID := r.URL.Query().Get("step_id")
+	if stepID == "" {
+		return "", nil, errors.New("step_id not provided")
+	}
+
+	filter, err := parseLogsFilter(r, "")
+	if err != nil {
+		return "", nil, err
+	}
+
+	return stepID, filter, nil
+}
+
+// parseLogsFilter parses the logs filter from the query parameters. When the
+// default stream is empty, the type parameter is required.
+func parseLogsFilter(r *http.Request, defaultStream string) (*coordinator.LogsFilter, error) {
+
+	filter := coordinator.LogsFilter{Stream: r.URL.Query().Get("type")}
+
+	if filter.Stream == "" {
+		filter.Stream = defaultStream
+	}
+
+	switch filter.Stream {
+	case "":
+		return nil, errors.New("type not provided")
+	case sharedstate.LogStreamStdout, sharedstate.LogStreamStderr, sharedstate.LogStreamCombined:
+	default:
+		return nil, fmt.Errorf("unsupported type %q", filter.Stream)
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339Nano, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse since: %w", err)
+		}
+		filter.Since = t
+	}
+	if until := r.URL.Query().Get("until"); until != "" {
+		t, err := time.Parse(time.RFC3339Nano, until)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse until: %w", err)
+		}
+		filter.Until = t
+	}
+
+	return &filter, nil
+}
+
 func (re runsEndpoint) context(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
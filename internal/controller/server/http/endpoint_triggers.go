@@ -6,11 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/coordinator"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
 	sharedstate "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
@@ -34,7 +36,12 @@ func (t triggersEndpoint) routes() chi.Router {
 		r.Use(t.context)
 		r.Delete("/", t.delete)
 		r.Get("/", t.get)
+		r.Put("/", t.update)
+		r.Post("/pause", t.pause)
+		r.Post("/resume", t.resume)
 		r.Post("/webhooks", t.webhooks)
+		r.Get("/deliveries", t.deliveries)
+		r.Post("/deliveries/{deliveryID}/redeliver", t.redeliver)
 	})
 
 	return router
@@ -103,7 +110,7 @@ func (t triggersEndpoint) create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := TriggerCreateResp{
-		Trigger:              req.Trigger,
+		Trigger:              t.coordinator.TriggerRedacted(req.Trigger),
 		internalResponseMeta: newInternalResponseMeta(http.StatusCreated),
 	}
 	httpWriteResponse(w, &resp)
@@ -159,7 +166,12 @@ type TriggerGetReq struct {
 }
 
 type TriggerGetResp struct {
-	Trigger              *sharedstate.Trigger `json:"trigger"`
+	Trigger *sharedstate.Trigger `json:"trigger"`
+
+	// NextRun is the next time the trigger is scheduled to run the flow,
+	// which is only set for cron triggers which are not paused.
+	NextRun *time.Time `json:"next_run,omitempty"`
+
 	internalResponseMeta `json:"-"`
 }
 
@@ -176,13 +188,169 @@ func (t triggersEndpoint) get(w http.ResponseWriter, r *http.Request) {
 		httpWriteResponseError(w, respErr)
 	} else {
 		resp := TriggerGetResp{
-			Trigger:              stateResp.Trigger,
+			Trigger:              t.coordinator.TriggerRedacted(stateResp.Trigger),
 			internalResponseMeta: newInternalResponseMeta(http.StatusOK),
 		}
+		if nextRun, ok := t.coordinator.TriggerNextRun(stateResp.Trigger); ok {
+			resp.NextRun = &nextRun
+		}
 		httpWriteResponse(w, &resp)
 	}
 }
 
+type TriggerUpdateReq struct {
+	Trigger *sharedstate.Trigger `json:"trigger"`
+}
+
+type TriggerUpdateResp struct {
+	Trigger              *sharedstate.Trigger `json:"trigger"`
+	internalResponseMeta `json:"-"`
+}
+
+func (t triggersEndpoint) update(w http.ResponseWriter, r *http.Request) {
+
+	var req TriggerUpdateReq
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpWriteResponseError(w, NewResponseError(fmt.Errorf("failed to decode object: %w", err), 400))
+		return
+	}
+
+	if req.Trigger == nil {
+		httpWriteResponseError(w, NewResponseError(errors.New("trigger cannot be empty"), http.StatusBadRequest))
+		return
+	}
+
+	triggerID := r.Context().Value("id").(string)
+	namespace := getNamespaceParam(r)
+
+	// The trigger is identified by the request path, so the ID and namespace
+	// can be omitted from the object, but cannot be changed.
+	if req.Trigger.ID == "" {
+		req.Trigger.ID = triggerID
+	}
+	if req.Trigger.Namespace == "" {
+		req.Trigger.Namespace = namespace
+	}
+	if req.Trigger.ID != triggerID || req.Trigger.Namespace != namespace {
+		httpWriteResponseError(w, NewResponseError(
+			fmt.Errorf("trigger ID and namespace cannot be changed, expected %s/%s", namespace, triggerID),
+			http.StatusBadRequest))
+		return
+	}
+
+	if err := req.Trigger.Validate(); err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+
+	getResp, err := t.state.Triggers().Get(&state.TriggersGetReq{
+		ID:        triggerID,
+		Namespace: namespace,
+	})
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err.Err(), err.StatusCode()))
+		return
+	}
+
+	if _, err := t.state.Flows().Get(
+		&state.FlowsGetReq{
+			ID:        req.Trigger.Flow,
+			Namespace: req.Trigger.Namespace,
+		},
+	); err != nil {
+		httpWriteResponseError(w, NewResponseError(err.Err(), err.StatusCode()))
+		return
+	}
+
+	// The paused status and fire time are managed by the controller, so are
+	// kept from the existing trigger rather than set by the update. Secrets
+	// are redacted when the trigger is read, so any left redacted are kept.
+	req.Trigger.Paused = getResp.Trigger.Paused
+	req.Trigger.LastFireTime = getResp.Trigger.LastFireTime
+	t.coordinator.TriggerUnredact(getResp.Trigger, req.Trigger)
+
+	t.replaceTrigger(w, getResp.Trigger, req.Trigger)
+}
+
+func (t triggersEndpoint) pause(w http.ResponseWriter, r *http.Request) {
+	t.setPaused(w, r, true)
+}
+
+func (t triggersEndpoint) resume(w http.ResponseWriter, r *http.Request) {
+	t.setPaused(w, r, false)
+}
+
+// setPaused pauses or resumes the trigger. Pausing or resuming a trigger which
+// is already in that state is not an error.
+func (t triggersEndpoint) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+
+	getResp, err := t.state.Triggers().Get(&state.TriggersGetReq{
+		ID:        r.Context().Value("id").(string),
+		Namespace: getNamespaceParam(r),
+	})
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err.Err(), err.StatusCode()))
+		return
+	}
+
+	if getResp.Trigger.Paused == paused {
+		resp := TriggerUpdateResp{
+			Trigger:              t.coordinator.TriggerRedacted(getResp.Trigger),
+			internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+		}
+		httpWriteResponse(w, &resp)
+		return
+	}
+
+	// Copy the trigger, as the state may return the stored object.
+	trigger := *getResp.Trigger
+	trigger.Paused = paused
+
+	// The times scheduled while the trigger was paused are not caught up, so
+	// the trigger is treated as having last fired when it is resumed. Times
+	// missed after this, such as while the controller is not running, are
+	// still caught up.
+	if !paused {
+		trigger.LastFireTime = time.Now()
+	}
+
+	t.replaceTrigger(w, getResp.Trigger, &trigger)
+}
+
+// replaceTrigger applies the updated trigger to the coordinator, and then
+// stores it in state. The coordinator is reverted to the existing trigger if
+// the state update fails, so the two do not diverge.
+func (t triggersEndpoint) replaceTrigger(w http.ResponseWriter, existing, trigger *sharedstate.Trigger) {
+
+	if err := t.coordinator.UpdateTrigger(existing, trigger); err != nil {
+		httpWriteResponseError(w, NewResponseError(err, http.StatusBadRequest))
+		return
+	}
+
+	if _, err := t.state.Triggers().Update(
+		&state.TriggersUpdateReq{Trigger: trigger},
+	); err != nil {
+		if revertErr := t.coordinator.UpdateTrigger(trigger, existing); revertErr != nil {
+			httpWriteResponseError(w, NewResponseError(
+				fmt.Errorf("failed to update trigger: %w; additionally failed to rollback trigger update: %v",
+					err.Err(), revertErr),
+				http.StatusInternalServerError,
+			))
+			return
+		}
+
+		httpWriteResponseError(w, NewResponseError(err.Err(), err.StatusCode()))
+		return
+	}
+
+	resp := TriggerUpdateResp{
+		Trigger:              t.coordinator.TriggerRedacted(trigger),
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+	httpWriteResponse(w, &resp)
+}
+
 type TriggerListResp struct {
 	Triggers             []*sharedstate.TriggerStub `json:"triggers"`
 	internalResponseMeta `json:"-"`
@@ -218,9 +386,11 @@ func (t triggersEndpoint) webhooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if stateResp.Trigger.Source.Provider != "git-webhook" {
+	switch stateResp.Trigger.Source.Provider {
+	case "git-webhook", "webhook":
+	default:
 		httpWriteResponseError(w, NewResponseError(
-			fmt.Errorf("trigger %s is not configured for git webhooks", stateResp.Trigger.ID),
+			fmt.Errorf("trigger %s is not configured for webhooks", stateResp.Trigger.ID),
 			http.StatusBadRequest))
 		return
 	}
@@ -228,6 +398,66 @@ func (t triggersEndpoint) webhooks(w http.ResponseWriter, r *http.Request) {
 	t.coordinator.HandleWebhook(w, r, stateResp.Trigger)
 }
 
+type TriggerDeliveriesResp struct {
+	Deliveries           []*sharedstate.TriggerDelivery `json:"deliveries"`
+	internalResponseMeta `json:"-"`
+}
+
+func (t triggersEndpoint) deliveries(w http.ResponseWriter, r *http.Request) {
+
+	stateResp, err := t.state.Triggers().Get(&state.TriggersGetReq{
+		ID:        r.Context().Value("id").(string),
+		Namespace: getNamespaceParam(r),
+	})
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err.Err(), err.StatusCode()))
+		return
+	}
+
+	resp := TriggerDeliveriesResp{
+		Deliveries:           t.coordinator.TriggerDeliveries(stateResp.Trigger),
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+	httpWriteResponse(w, &resp)
+}
+
+type TriggerRedeliverResp struct {
+	Delivery             *sharedstate.TriggerDelivery `json:"delivery"`
+	internalResponseMeta `json:"-"`
+}
+
+func (t triggersEndpoint) redeliver(w http.ResponseWriter, r *http.Request) {
+
+	stateResp, err := t.state.Triggers().Get(&state.TriggersGetReq{
+		ID:        r.Context().Value("id").(string),
+		Namespace: getNamespaceParam(r),
+	})
+	if err != nil {
+		httpWriteResponseError(w, NewResponseError(err.Err(), err.StatusCode()))
+		return
+	}
+
+	deliveryID := chi.URLParam(r, "deliveryID")
+
+	redelivery, redeliverErr := t.coordinator.TriggerRedeliver(stateResp.Trigger, deliveryID)
+	switch {
+	case errors.Is(redeliverErr, delivery.ErrNotFound):
+		httpWriteResponseError(w, NewResponseError(
+			fmt.Errorf("delivery %s not found", deliveryID), http.StatusNotFound))
+		return
+	case redeliverErr != nil:
+		httpWriteResponseError(w, NewResponseError(
+			fmt.Errorf("failed to redeliver delivery %s: %w", deliveryID, redeliverErr), http.StatusConflict))
+		return
+	}
+
+	resp := TriggerRedeliverResp{
+		Delivery:             redelivery,
+		internalResponseMeta: newInternalResponseMeta(http.StatusOK),
+	}
+	httpWriteResponse(w, &resp)
+}
+
 func (t triggersEndpoint) context(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -14,6 +14,10 @@ func newRouter(req *ServerReq) *chi.Mux {
 			runController: req.Coordinator,
 			state:         req.State,
 		}.routes())
+		r.Mount("/logs", logsEndpoint{
+			coordinator: req.Coordinator,
+			state:       req.State,
+		}.routes())
 		r.Mount("/namespaces", namespacesEndpoint{
 			state: req.State,
 		}.routes())
@@ -1,11 +1,19 @@
 package rpc
 
 import (
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/coordinator"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
 	intrpc "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/rpc"
 )
 
+// runnerWaitCancelTime is the maximum time a JobWaitCancel request is held
+// before returning, after which the runner will make a new request.
+const runnerWaitCancelTime = 30 * time.Second
+
 type RunnerEndpoint struct {
 	coordinator *coordinator.Coordinator
 	state       state.State
@@ -20,10 +28,7 @@ func (r *RunnerEndpoint) JobUpdate(
 		return err
 	}
 
-	if _, err := r.state.Runs().Update(&state.RunsUpdateReq{Run: req.Run}); err != nil {
-		return err
-	}
-	return nil
+	return r.coordinator.UpdateRun(req.Run)
 }
 
 // JobLogsBatch receives a batch of log lines from a runner and writes them to disk
@@ -41,6 +46,27 @@ func (r *RunnerEndpoint) JobLogsBatch(
 		req.RunID,
 		req.StepID,
 		req.Type,
+		req.Sequence,
 		req.Logs,
 	)
 }
+
+// JobWaitCancel blocks until the run is cancelled or the wait time elapses,
+// allowing runners to gracefully stop their steps on cancellation.
+func (r *RunnerEndpoint) JobWaitCancel(
+	req *intrpc.RunnerWaitCancelReq,
+	reply *intrpc.RunnerWaitCancelResp,
+) error {
+
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	runID, err := ulid.Parse(req.RunID)
+	if err != nil {
+		return err
+	}
+
+	reply.Cancelled = r.coordinator.WaitInlineCancel(runID, req.Namespace, runnerWaitCancelTime)
+	return nil
+}
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"github.com/hashicorp/nomad/api"
 	"go.uber.org/zap"
 
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/coordinator"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/logstore"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/http"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/rpc"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
@@ -75,12 +77,19 @@ func NewServer(cfg *Config) (*Server, error) {
 		return nil, fmt.Errorf("failed to setup default state objects: %w", err)
 	}
 
+	logStore, err := logstore.NewStore(cfg.LogStorage, filepath.Join(cfg.Data.Path, "runs"), zapLogger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log store: %w", err)
+	}
+
 	server.runnerController = coordinator.New(&coordinator.CoordinatorConfig{
 		Logger:      zapLogger,
 		NomadClient: server.nomadClient,
 		State:       server.state,
 		DataDir:     cfg.Data.Path,
 		RPCAddr:     cfg.RPC.Addr,
+		LogStore:    logStore,
+		PublicURL:   cfg.HTTP.publicURL(),
 	})
 
 	//
@@ -1,12 +1,21 @@
 package state
 
-import sharedstate "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+import (
+	"time"
+
+	sharedstate "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
 
 type Triggers interface {
 	Create(*TriggersCreateReq) (*TriggersCreateResp, *ErrorResp)
 	Delete(*TriggersDeleteReq) (*TriggersDeleteResp, *ErrorResp)
 	Get(*TriggersGetReq) (*TriggersGetResp, *ErrorResp)
 	List(*TriggersListReq) (*TriggersListResp, *ErrorResp)
+	Update(*TriggersUpdateReq) (*TriggersUpdateResp, *ErrorResp)
+
+	// SetLastFireTime updates only the last fire time of the trigger, so it
+	// does not overwrite concurrent updates to the rest of the trigger.
+	SetLastFireTime(*TriggersSetLastFireTimeReq) (*TriggersSetLastFireTimeResp, *ErrorResp)
 }
 
 type TriggersCreateReq struct {
@@ -38,3 +47,17 @@ type TriggersListReq struct {
 type TriggersListResp struct {
 	Triggers []*sharedstate.TriggerStub
 }
+
+type TriggersUpdateReq struct {
+	Trigger *sharedstate.Trigger
+}
+
+type TriggersUpdateResp struct{}
+
+type TriggersSetLastFireTimeReq struct {
+	ID           string
+	Namespace    string
+	LastFireTime time.Time
+}
+
+type TriggersSetLastFireTimeResp struct{}
@@ -56,8 +56,8 @@ func (r *Runs) Get(req *state.RunsGetReq) (*state.RunsGetResp, *state.ErrorResp)
 }
 
 func (r *Runs) List(req *state.RunsListReq) (*state.RunsListResp, *state.ErrorResp) {
-	r.s.flowsLock.RLock()
-	defer r.s.flowsLock.RUnlock()
+	r.s.runsLock.RLock()
+	defer r.s.runsLock.RUnlock()
 
 	var runs []*sharedstate.RunStub
 
@@ -72,8 +72,8 @@ func (r *Runs) List(req *state.RunsListReq) (*state.RunsListResp, *state.ErrorRe
 }
 
 func (r *Runs) Update(req *state.RunsUpdateReq) (*state.RunsUpdateResp, *state.ErrorResp) {
-	r.s.flowsLock.Lock()
-	defer r.s.flowsLock.Unlock()
+	r.s.runsLock.Lock()
+	defer r.s.runsLock.Unlock()
 
 	k := runCompositeKey{id: req.Run.ID, namesapce: req.Run.Namespace}
 
@@ -83,6 +83,7 @@ func (r *Runs) Update(req *state.RunsUpdateReq) (*state.RunsUpdateResp, *state.E
 		req.Run.Variables = stateRun.Variables
 		req.Run.CreateTime = stateRun.CreateTime
 		req.Run.Trigger = stateRun.Trigger
+		req.Run.TriggerTime = stateRun.TriggerTime
 	}
 
 	r.s.runs[k] = req.Run
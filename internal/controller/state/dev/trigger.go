@@ -43,6 +43,40 @@ func (t *Triggers) Delete(req *state.TriggersDeleteReq) (*state.TriggersDeleteRe
 	return &state.TriggersDeleteResp{}, nil
 }
 
+func (t *Triggers) Update(req *state.TriggersUpdateReq) (*state.TriggersUpdateResp, *state.ErrorResp) {
+	t.s.triggersLock.Lock()
+	defer t.s.triggersLock.Unlock()
+
+	key := triggerCompositeKey{id: req.Trigger.ID, namesapce: req.Trigger.Namespace}
+
+	if _, ok := t.s.triggers[key]; !ok {
+		return nil, state.NewErrorResp(errors.New("trigger not found"), 404)
+	}
+
+	t.s.triggers[key] = req.Trigger
+	return &state.TriggersUpdateResp{}, nil
+}
+
+func (t *Triggers) SetLastFireTime(req *state.TriggersSetLastFireTimeReq) (*state.TriggersSetLastFireTimeResp, *state.ErrorResp) {
+	t.s.triggersLock.Lock()
+	defer t.s.triggersLock.Unlock()
+
+	key := triggerCompositeKey{id: req.ID, namesapce: req.Namespace}
+
+	trigger, ok := t.s.triggers[key]
+	if !ok {
+		return nil, state.NewErrorResp(errors.New("trigger not found"), 404)
+	}
+
+	// Replace the stored trigger with a copy, as Get returns the stored
+	// object to callers.
+	updated := *trigger
+	updated.LastFireTime = req.LastFireTime
+
+	t.s.triggers[key] = &updated
+	return &state.TriggersSetLastFireTimeResp{}, nil
+}
+
 func (t *Triggers) Get(req *state.TriggersGetReq) (*state.TriggersGetResp, *state.ErrorResp) {
 	t.s.triggersLock.RLock()
 	defer t.s.triggersLock.RUnlock()
@@ -202,6 +202,7 @@ func (r *Runs) Update(req *serverstate.RunsUpdateReq) (*serverstate.RunsUpdateRe
 		req.Run.Variables = stateRun.Variables
 		req.Run.CreateTime = stateRun.CreateTime
 		req.Run.Trigger = stateRun.Trigger
+		req.Run.TriggerTime = stateRun.TriggerTime
 	}
 
 	// Update the variable
@@ -92,6 +92,100 @@ func (t *Triggers) Delete(req *serverstate.TriggersDeleteReq) (*serverstate.Trig
 	return &serverstate.TriggersDeleteResp{}, nil
 }
 
+func (t *Triggers) Update(req *serverstate.TriggersUpdateReq) (*serverstate.TriggersUpdateResp, *serverstate.ErrorResp) {
+	t.s.triggersLock.Lock()
+	defer t.s.triggersLock.Unlock()
+
+	k := triggerCompositeKey{id: req.Trigger.ID, namespace: req.Trigger.Namespace}
+
+	// Check if trigger exists
+	if t.s.enableCache {
+		if _, ok := t.s.triggersCache[k]; !ok {
+			return nil, serverstate.NewErrorResp(errors.New("trigger not found"), 404)
+		}
+	} else {
+		_, err := t.s.getVariable(triggerVarPath(req.Trigger.Namespace, req.Trigger.ID))
+		if err != nil {
+			return nil, serverstate.NewErrorResp(errors.New("trigger not found"), 404)
+		}
+	}
+
+	// Update the variable
+	v, err := encodeToVariable(triggerVarPath(req.Trigger.Namespace, req.Trigger.ID), req.Trigger)
+	if err != nil {
+		t.s.logger.Error("failed to encode trigger", zap.Error(err))
+		return nil, serverstate.NewErrorResp(fmt.Errorf("failed to encode trigger: %w", err), 500)
+	}
+
+	if err := t.s.putVariable(v); err != nil {
+		t.s.logger.Error("failed to store trigger in Nomad Variables", zap.Error(err))
+		return nil, serverstate.NewErrorResp(fmt.Errorf("failed to store trigger: %w", err), 500)
+	}
+
+	// Update cache
+	if t.s.enableCache {
+		t.s.triggersCache[k] = req.Trigger
+	}
+
+	t.s.logger.Debug("trigger updated",
+		zap.String("namespace", req.Trigger.Namespace),
+		zap.String("trigger_id", req.Trigger.ID))
+
+	return &serverstate.TriggersUpdateResp{}, nil
+}
+
+func (t *Triggers) SetLastFireTime(req *serverstate.TriggersSetLastFireTimeReq) (*serverstate.TriggersSetLastFireTimeResp, *serverstate.ErrorResp) {
+	t.s.triggersLock.Lock()
+	defer t.s.triggersLock.Unlock()
+
+	k := triggerCompositeKey{id: req.ID, namespace: req.Namespace}
+
+	// Read the current trigger while holding the lock, so only the last fire
+	// time is changed.
+	var trigger state.Trigger
+
+	if t.s.enableCache {
+		cached, ok := t.s.triggersCache[k]
+		if !ok {
+			return nil, serverstate.NewErrorResp(errors.New("trigger not found"), 404)
+		}
+		trigger = *cached
+	} else {
+		v, err := t.s.getVariable(triggerVarPath(req.Namespace, req.ID))
+		if err != nil {
+			if isNotFoundError(err) {
+				return nil, serverstate.NewErrorResp(errors.New("trigger not found"), 404)
+			}
+			t.s.logger.Error("failed to get trigger from Nomad Variables", zap.Error(err))
+			return nil, serverstate.NewErrorResp(fmt.Errorf("failed to get trigger: %w", err), 500)
+		}
+		if err := decodeFromVariable(v, &trigger); err != nil {
+			t.s.logger.Error("failed to decode trigger", zap.Error(err))
+			return nil, serverstate.NewErrorResp(fmt.Errorf("failed to decode trigger: %w", err), 500)
+		}
+	}
+
+	trigger.LastFireTime = req.LastFireTime
+
+	v, err := encodeToVariable(triggerVarPath(req.Namespace, req.ID), &trigger)
+	if err != nil {
+		t.s.logger.Error("failed to encode trigger", zap.Error(err))
+		return nil, serverstate.NewErrorResp(fmt.Errorf("failed to encode trigger: %w", err), 500)
+	}
+
+	if err := t.s.putVariable(v); err != nil {
+		t.s.logger.Error("failed to store trigger in Nomad Variables", zap.Error(err))
+		return nil, serverstate.NewErrorResp(fmt.Errorf("failed to store trigger: %w", err), 500)
+	}
+
+	// Update cache
+	if t.s.enableCache {
+		t.s.triggersCache[k] = &trigger
+	}
+
+	return &serverstate.TriggersSetLastFireTimeResp{}, nil
+}
+
 func (t *Triggers) Get(req *serverstate.TriggersGetReq) (*serverstate.TriggersGetResp, *serverstate.ErrorResp) {
 	t.s.triggersLock.RLock()
 	defer t.s.triggersLock.RUnlock()
@@ -0,0 +1,361 @@
+// Package delivery records the webhook requests received by triggers, so
+// deliveries which did not run a flow can be inspected and redelivered.
+package delivery
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// MaxPayloadSize is the maximum size of webhook payload read, which matches
+// the limit GitHub applies to the payloads it sends.
+const MaxPayloadSize = 25 * 1024 * 1024
+
+// MaxPerTrigger is the number of deliveries retained for each trigger, after
+// which the oldest deliveries are removed.
+const MaxPerTrigger = 50
+
+// MaxStoreSize is the total size of the payloads retained by the store, after
+// which the payloads of the oldest deliveries are dropped. Their records are
+// kept, but they can no longer be redelivered.
+const MaxStoreSize = 100 * 1024 * 1024
+
+var (
+	// ErrNotFound is returned when redelivering a delivery which is not
+	// recorded for the trigger.
+	ErrNotFound = errors.New("delivery not found")
+
+	// ErrPayloadNotRetained is returned when redelivering a delivery whose
+	// payload has been dropped, either because it was rejected or to keep the
+	// store within its size limit.
+	ErrPayloadNotRetained = errors.New("delivery payload is not retained")
+)
+
+// idHeaders are the headers which the supported providers use to send the
+// unique ID of each delivery, in the order they are checked.
+var idHeaders = []string{
+	"X-GitHub-Delivery",
+	"X-Gitlab-Event-UUID",
+	"X-Gitea-Delivery",
+	"X-Forgejo-Delivery",
+	"X-Request-UUID",
+	"X-Delivery-ID",
+}
+
+// redactedHeaders are the headers whose values are not exposed by the
+// delivery record, as they may contain the trigger secret.
+var redactedHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"X-Gitlab-Token",
+}
+
+// Result is the outcome of processing a webhook delivery.
+type Result struct {
+	// StatusCode and Message form the response sent to the webhook sender.
+	StatusCode int
+	Message    string
+
+	// Decision is one of the state.TriggerDeliveryDecision constants.
+	Decision string
+	Event    string
+	RunID    string
+
+	// Err is the detailed error of rejected and failed deliveries, which is
+	// recorded but not sent to the webhook sender.
+	Err error
+}
+
+// Delivery is a recorded delivery, along with the request used to redeliver
+// it. The header and payload are nil once dropped by the store.
+type Delivery struct {
+	record   *state.TriggerDelivery
+	header   http.Header
+	payload  []byte
+	retained bool
+}
+
+// New returns the delivery of the payload to the trigger. The ID is read from
+// the headers of the provider, and is generated when the delivery does not
+// include one.
+func New(trigger *state.Trigger, header http.Header, payload []byte) *Delivery {
+
+	sum := sha256.Sum256(payload)
+
+	record := state.TriggerDelivery{
+		TriggerID:   trigger.ID,
+		Namespace:   trigger.Namespace,
+		Time:        time.Now(),
+		Headers:     make(map[string]string, len(header)),
+		PayloadHash: hex.EncodeToString(sum[:]),
+		PayloadSize: len(payload),
+	}
+
+	for _, name := range idHeaders {
+		if id := header.Get(name); id != "" {
+			record.ID = id
+			break
+		}
+	}
+	if record.ID == "" {
+		record.ID = ulid.Make().String()
+	}
+
+	for name, values := range header {
+		if slices.Contains(redactedHeaders, http.CanonicalHeaderKey(name)) {
+			record.Headers[name] = "<redacted>"
+		} else {
+			record.Headers[name] = strings.Join(values, ", ")
+		}
+	}
+
+	return &Delivery{
+		record:   &record,
+		header:   header.Clone(),
+		payload:  payload,
+		retained: true,
+	}
+}
+
+// ID returns the ID of the delivery.
+func (d *Delivery) ID() string { return d.record.ID }
+
+// ReadPayload reads the payload of the webhook request.
+func ReadPayload(r *http.Request) ([]byte, error) {
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxPayloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook payload: %w", err)
+	}
+	if len(body) > MaxPayloadSize {
+		return nil, errors.New("webhook payload is too large")
+	}
+
+	return body, nil
+}
+
+// Request returns a new request containing the delivered headers and
+// payload. It must be called before the delivery is added to the store, as the
+// store may drop the payload once added.
+func (d *Delivery) Request() *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, "/", bytes.NewReader(d.payload))
+	req.Header = d.header.Clone()
+	return req
+}
+
+type triggerKey struct {
+	namespace string
+	id        string
+}
+
+// Store retains the most recent deliveries of each trigger in memory.
+type Store struct {
+	deliveries map[triggerKey][]*Delivery
+
+	// size is the total size of the retained payloads.
+	size int
+
+	lock sync.RWMutex
+}
+
+func NewStore() *Store {
+	return &Store{deliveries: make(map[triggerKey][]*Delivery)}
+}
+
+// Add records the delivery, returning false if a delivery with the same ID
+// has already been recorded for the trigger and either is being processed or
+// was triggered or ignored. Deliveries which were rejected or failed do not
+// prevent the sender from retrying them with the same ID.
+func (s *Store) Add(d *Delivery) bool {
+
+	key := triggerKey{namespace: d.record.Namespace, id: d.record.TriggerID}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	deliveries := s.deliveries[key]
+
+	if slices.ContainsFunc(deliveries, func(existing *Delivery) bool {
+		return existing.record.ID == d.record.ID && isDuplicateDecision(existing.record.Decision)
+	}) {
+		return false
+	}
+
+	if len(deliveries) >= MaxPerTrigger {
+		n := len(deliveries) - MaxPerTrigger + 1
+		for _, removed := range deliveries[:n] {
+			s.dropPayload(removed)
+		}
+		deliveries = slices.Delete(deliveries, 0, n)
+	}
+
+	s.deliveries[key] = append(deliveries, d)
+	s.size += len(d.payload)
+	s.enforceSize()
+
+	return true
+}
+
+// isDuplicateDecision returns whether a delivery with the decision prevents
+// another delivery with the same ID from being processed. Deliveries which
+// have not been completed do not have a decision.
+func isDuplicateDecision(decision string) bool {
+	switch decision {
+	case "", state.TriggerDeliveryDecisionTriggered, state.TriggerDeliveryDecisionIgnored:
+		return true
+	default:
+		return false
+	}
+}
+
+// enforceSize drops the payloads of the oldest deliveries until the retained
+// payloads are within the size limit. The lock must be held.
+func (s *Store) enforceSize() {
+
+	for s.size > MaxStoreSize {
+
+		var oldest *Delivery
+
+		for _, deliveries := range s.deliveries {
+			for _, d := range deliveries {
+				if d.retained && (oldest == nil || d.record.Time.Before(oldest.record.Time)) {
+					oldest = d
+				}
+			}
+		}
+
+		if oldest == nil {
+			return
+		}
+		s.dropPayload(oldest)
+	}
+}
+
+// dropPayload releases the header and payload of the delivery, after which it
+// cannot be redelivered. The lock must be held.
+func (s *Store) dropPayload(d *Delivery) {
+	if d.retained {
+		s.size -= len(d.payload)
+		d.header, d.payload, d.retained = nil, nil, false
+	}
+}
+
+// Complete records the result of processing the delivery, and returns a copy
+// of the delivery record. The payload of rejected deliveries is not retained,
+// as they cannot be redelivered successfully.
+func (s *Store) Complete(d *Delivery, result *Result) *state.TriggerDelivery {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	d.record.Event = result.Event
+	d.record.Decision = result.Decision
+	d.record.Reason = result.Message
+	d.record.StatusCode = result.StatusCode
+	d.record.RunID = result.RunID
+
+	if result.Err != nil {
+		d.record.Error = result.Err.Error()
+	}
+
+	if result.Decision == state.TriggerDeliveryDecisionRejected {
+		s.dropPayload(d)
+	}
+
+	return copyRecord(d.record)
+}
+
+// Get returns the delivery of the trigger with the ID. A delivery which was
+// retried by the sender has multiple records, in which case the most recent
+// is returned.
+func (s *Store) Get(namespace, triggerID, id string) (*Delivery, bool) {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	deliveries := s.deliveries[triggerKey{namespace: namespace, id: triggerID}]
+
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		if deliveries[i].record.ID == id {
+			return deliveries[i], true
+		}
+	}
+	return nil, false
+}
+
+// Redelivery returns a new delivery of the same request as the delivery, or
+// ErrPayloadNotRetained if the payload of the delivery has been dropped.
+func (s *Store) Redelivery(d *Delivery) (*Delivery, error) {
+
+	s.lock.RLock()
+	record := *d.record
+	header, payload, retained := d.header, d.payload, d.retained
+	s.lock.RUnlock()
+
+	if !retained {
+		return nil, ErrPayloadNotRetained
+	}
+
+	record.ID = ulid.Make().String()
+	record.RedeliveryOf = d.record.ID
+	record.Time = time.Now()
+	record.Event, record.Decision, record.Reason, record.RunID, record.Error = "", "", "", "", ""
+	record.StatusCode = 0
+
+	return &Delivery{
+		record:   &record,
+		header:   header,
+		payload:  payload,
+		retained: true,
+	}, nil
+}
+
+// List returns copies of the delivery records of the trigger, ordered from
+// newest to oldest.
+func (s *Store) List(namespace, triggerID string) []*state.TriggerDelivery {
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	deliveries := s.deliveries[triggerKey{namespace: namespace, id: triggerID}]
+
+	records := make([]*state.TriggerDelivery, 0, len(deliveries))
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		records = append(records, copyRecord(deliveries[i].record))
+	}
+	return records
+}
+
+// Delete removes the deliveries of the trigger.
+func (s *Store) Delete(namespace, triggerID string) {
+
+	key := triggerKey{namespace: namespace, id: triggerID}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, d := range s.deliveries[key] {
+		s.dropPayload(d)
+	}
+	delete(s.deliveries, key)
+}
+
+func copyRecord(record *state.TriggerDelivery) *state.TriggerDelivery {
+	c := *record
+	c.Headers = maps.Clone(record.Headers)
+	return &c
+}
@@ -0,0 +1,274 @@
+package git
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
+)
+
+const (
+	bitbucketEventHeader     = "X-Event-Key"
+	bitbucketSignatureHeader = "X-Hub-Signature"
+
+	bitbucketEventPush = "repo:push"
+
+	// bitbucketEventPullRequestPrefix prefixes the event keys of pull requests,
+	// where the suffix is the action which caused the event.
+	bitbucketEventPullRequestPrefix = "pullrequest:"
+)
+
+type bitbucketLink struct {
+	HTML struct {
+		Href string `json:"href"`
+	} `json:"html"`
+}
+
+type bitbucketUser struct {
+	Nickname    string `json:"nickname"`
+	DisplayName string `json:"display_name"`
+}
+
+type bitbucketRepository struct {
+	Name     string        `json:"name"`
+	FullName string        `json:"full_name"`
+	Links    bitbucketLink `json:"links"`
+}
+
+type bitbucketCommit struct {
+	Hash    string        `json:"hash"`
+	Message string        `json:"message"`
+	Links   bitbucketLink `json:"links"`
+	Author  struct {
+		Raw  string         `json:"raw"`
+		User *bitbucketUser `json:"user"`
+	} `json:"author"`
+}
+
+type bitbucketRef struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name"`
+	Target bitbucketCommit `json:"target"`
+}
+
+// bitbucketPushChange is a ref updated by a push, where New is nil when the
+// branch or tag was deleted, and Old is nil when it was created.
+type bitbucketPushChange struct {
+	New *bitbucketRef `json:"new"`
+	Old *bitbucketRef `json:"old"`
+}
+
+type bitbucketPushEvent struct {
+	Actor      bitbucketUser       `json:"actor"`
+	Repository bitbucketRepository `json:"repository"`
+	Push       struct {
+		Changes []*bitbucketPushChange `json:"changes"`
+	} `json:"push"`
+}
+
+type bitbucketPullRequestBranch struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+	Repository bitbucketRepository `json:"repository"`
+}
+
+type bitbucketPullRequestEvent struct {
+	Actor       bitbucketUser       `json:"actor"`
+	Repository  bitbucketRepository `json:"repository"`
+	PullRequest struct {
+		ID          int                        `json:"id"`
+		Title       string                     `json:"title"`
+		Draft       bool                       `json:"draft"`
+		Links       bitbucketLink              `json:"links"`
+		Author      bitbucketUser              `json:"author"`
+		Source      bitbucketPullRequestBranch `json:"source"`
+		Destination bitbucketPullRequestBranch `json:"destination"`
+	} `json:"pullrequest"`
+}
+
+func (h *Trigger) handleBitbucketWebhook(r *http.Request, cfg *triggerConfig) (*webhookPayload, error) {
+
+	eventType := r.Header.Get(bitbucketEventHeader)
+
+	h.logger.Debug("processing Bitbucket webhook",
+		zap.String("content-type", r.Header.Get("Content-Type")),
+		zap.String("event-type", eventType))
+
+	body, err := delivery.ReadPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := webhookPayload{}
+
+	switch {
+	case eventType == bitbucketEventPush:
+		var event bitbucketPushEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode Bitbucket push event: %w", err)
+		}
+		if len(event.Push.Changes) == 0 {
+			return nil, errors.New("push event has no changes")
+		}
+		resp.repo = event.Repository.FullName
+		resp.event = "push"
+
+		change, dropped := selectBitbucketPushChange(&event)
+		if change == nil {
+			resp.ignore = "push only deleted branches or tags"
+			break
+		}
+		if len(dropped) > 0 {
+			h.logger.Warn("Bitbucket push updated multiple branches or tags, only the first is used",
+				zap.String("ref", bitbucketRefName(change.New)),
+				zap.Strings("dropped_refs", dropped))
+		}
+
+		resp.ref = bitbucketRefName(change.New)
+		resp.vars = buildBitbucketPushVars(&event, change)
+	case strings.HasPrefix(eventType, bitbucketEventPullRequestPrefix):
+		var event bitbucketPullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode Bitbucket pull request event: %w", err)
+		}
+		resp.repo = event.Repository.FullName
+		resp.event = "pull_request"
+		resp.action = bitbucketPullRequestAction(eventType)
+		resp.ref = refBranchPrefix + event.PullRequest.Destination.Branch.Name
+		resp.vars = buildBitbucketPullRequestVars(&event, resp.action)
+	default:
+		return nil, fmt.Errorf("unsupported Bitbucket event type: %s", eventType)
+	}
+
+	return &resp, nil
+}
+
+// bitbucketPullRequestAction maps the pull request event key onto the
+// equivalent GitHub pull request action. Bitbucket sends the same update
+// event when commits are pushed and when the details of the pull request are
+// edited, so both map to synchronize.
+func bitbucketPullRequestAction(eventType string) string {
+	switch action := strings.TrimPrefix(eventType, bitbucketEventPullRequestPrefix); action {
+	case "created":
+		return "opened"
+	case "updated":
+		return "synchronize"
+	case "fulfilled":
+		return "merged"
+	case "rejected":
+		return "closed"
+	default:
+		return action
+	}
+}
+
+// selectBitbucketPushChange returns the first change of the push which did not
+// delete the branch or tag, along with the names of the other updated refs. A
+// single push can update multiple branches and tags, but only one is used to
+// run the flow. Nil is returned when every change is a deletion.
+func selectBitbucketPushChange(event *bitbucketPushEvent) (*bitbucketPushChange, []string) {
+
+	var (
+		selected *bitbucketPushChange
+		dropped  []string
+	)
+
+	for _, change := range event.Push.Changes {
+		switch {
+		case change == nil || change.New == nil:
+		case selected == nil:
+			selected = change
+		default:
+			dropped = append(dropped, bitbucketRefName(change.New))
+		}
+	}
+
+	return selected, dropped
+}
+
+func bitbucketRefName(ref *bitbucketRef) string {
+	if ref.Type == "tag" {
+		return refTagPrefix + ref.Name
+	}
+	return refBranchPrefix + ref.Name
+}
+
+func buildBitbucketPushVars(event *bitbucketPushEvent, change *bitbucketPushChange) map[string]any {
+
+	ref := change.New
+
+	vars := map[string]any{
+		"git_event":          "push",
+		"git_ref":            bitbucketRefName(ref),
+		"git_pusher":         event.Actor.Nickname,
+		"git_sha":            ref.Target.Hash,
+		"git_commit_message": ref.Target.Message,
+		"git_commit_url":     ref.Target.Links.HTML.Href,
+	}
+
+	if change.Old != nil {
+		vars["git_before"] = change.Old.Target.Hash
+	}
+
+	if ref.Target.Author.User != nil {
+		vars["git_commit_author"] = ref.Target.Author.User.DisplayName
+	} else {
+		vars["git_commit_author"] = ref.Target.Author.Raw
+	}
+
+	if ref.Type == "tag" {
+		vars["git_tag"] = ref.Name
+	}
+
+	addBitbucketRepoVars(vars, &event.Repository)
+
+	return map[string]any{"trigger": vars}
+}
+
+func buildBitbucketPullRequestVars(event *bitbucketPullRequestEvent, action string) map[string]any {
+
+	pr := event.PullRequest
+
+	// Bitbucket does not provide a ref for the head of pull requests, so the
+	// source branch is used, which is within the head repository when the pull
+	// request is from a fork. Bitbucket also only includes abbreviated commit
+	// hashes, and pull requests do not have labels.
+	vars := map[string]any{
+		"git_event":           "pull_request",
+		"git_action":          action,
+		"git_ref":             refBranchPrefix + pr.Source.Branch.Name,
+		"git_sha":             pr.Source.Commit.Hash,
+		"git_pr_number":       pr.ID,
+		"git_pr_title":        pr.Title,
+		"git_pr_url":          pr.Links.HTML.Href,
+		"git_pr_author":       pr.Author.Nickname,
+		"git_pr_draft":        pr.Draft,
+		"git_head_ref":        pr.Source.Branch.Name,
+		"git_head_sha":        pr.Source.Commit.Hash,
+		"git_head_repository": pr.Source.Repository.FullName,
+		"git_base_ref":        pr.Destination.Branch.Name,
+		"git_base_sha":        pr.Destination.Commit.Hash,
+		"git_sender":          event.Actor.Nickname,
+	}
+
+	addBitbucketRepoVars(vars, &event.Repository)
+
+	return map[string]any{"trigger": vars}
+}
+
+func addBitbucketRepoVars(vars map[string]any, repo *bitbucketRepository) {
+	vars["git_repository"] = repo.FullName
+	vars["git_repo_name"] = repo.Name
+	vars["git_repo_owner"] = path.Dir(repo.FullName)
+	vars["git_repo_url"] = repo.Links.HTML.Href
+}
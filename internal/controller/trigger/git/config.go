@@ -1,7 +1,9 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	"slices"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
@@ -10,12 +12,104 @@ import (
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
+const (
+	providerGitHub    = "github"
+	providerGitLab    = "gitlab"
+	providerGitea     = "gitea"
+	providerBitbucket = "bitbucket"
+)
+
 type triggerConfig struct {
 	Provider   string   `hcl:"provider" json:"provider"`
 	Repository string   `hcl:"repository" json:"repository"`
 	Secret     string   `hcl:"secret,optional" json:"secret,omitempty"`
-	Branches   []string `hcl:"branches,optional" json:"branches,omitempty"`
 	Events     []string `hcl:"events,optional" json:"events,omitempty"`
+
+	// PullRequestActions and ReleaseActions filter the actions of pull request
+	// and release events. When empty, the default actions of the event are
+	// used.
+	PullRequestActions []string `hcl:"pull_request_actions,optional" json:"pull_request_actions,omitempty"`
+	ReleaseActions     []string `hcl:"release_actions,optional" json:"release_actions,omitempty"`
+
+	// Branches, Tags, and Paths filter push events using glob patterns, which
+	// are matched against the pushed branch or tag name, and the files changed
+	// by the pushed commits.
+	Branches       []string `hcl:"branches,optional" json:"branches,omitempty"`
+	BranchesIgnore []string `hcl:"branches_ignore,optional" json:"branches_ignore,omitempty"`
+	Tags           []string `hcl:"tags,optional" json:"tags,omitempty"`
+	TagsIgnore     []string `hcl:"tags_ignore,optional" json:"tags_ignore,omitempty"`
+	Paths          []string `hcl:"paths,optional" json:"paths,omitempty"`
+	PathsIgnore    []string `hcl:"paths_ignore,optional" json:"paths_ignore,omitempty"`
+
+	// Status configures the commit statuses posted to the provider for runs
+	// started by the trigger. Statuses are not posted when it is not set.
+	Status *statusConfig `hcl:"status,block" json:"status,omitempty"`
+
+	filters *triggerFilters
+}
+
+// defaultEventActions are the actions which run the flow, for events which
+// have an action, when the trigger does not configure any.
+var defaultEventActions = map[string][]string{
+	"pull_request": {"opened", "synchronize", "reopened"},
+	"release":      {"published"},
+}
+
+// matchAction returns whether the event action should run the flow. Events
+// without an action always match.
+func (c *triggerConfig) matchAction(event, action string) bool {
+
+	if action == "" {
+		return true
+	}
+
+	var actions []string
+
+	switch event {
+	case "pull_request":
+		actions = c.PullRequestActions
+	case "release":
+		actions = c.ReleaseActions
+	}
+	if len(actions) == 0 {
+		actions = defaultEventActions[event]
+	}
+
+	return slices.Contains(actions, action)
+}
+
+type triggerKey struct {
+	namespace string
+	id        string
+}
+
+// cachedConfig is the decoded config of a trigger, along with the raw config
+// it was decoded from, so a changed config is decoded again.
+type cachedConfig struct {
+	raw []byte
+	cfg *triggerConfig
+}
+
+// config returns the decoded config of the trigger, only decoding it when the
+// config has changed since it was last decoded.
+func (h *Trigger) config(trigger *state.Trigger) (*triggerConfig, error) {
+
+	key := triggerKey{namespace: trigger.Namespace, id: trigger.ID}
+
+	h.configsLock.Lock()
+	defer h.configsLock.Unlock()
+
+	if cached, ok := h.configs[key]; ok && bytes.Equal(cached.raw, trigger.Source.Config) {
+		return cached.cfg, nil
+	}
+
+	cfg, err := decodeTriggerConfig(trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	h.configs[key] = &cachedConfig{raw: bytes.Clone(trigger.Source.Config), cfg: cfg}
+	return cfg, nil
 }
 
 func decodeTriggerConfig(trigger *state.Trigger) (*triggerConfig, error) {
@@ -35,5 +129,29 @@ func decodeTriggerConfig(trigger *state.Trigger) (*triggerConfig, error) {
 		return nil, fmt.Errorf("failed to decode HCL config: %w", diags)
 	}
 
+	switch cfg.Provider {
+	case providerGitHub, providerGitLab, providerGitea, providerBitbucket:
+	default:
+		return nil, fmt.Errorf("unsupported git provider %q", cfg.Provider)
+	}
+
+	// Bitbucket push events do not list the changed files, so path filters
+	// could never be applied.
+	if cfg.Provider == providerBitbucket && (len(cfg.Paths) > 0 || len(cfg.PathsIgnore) > 0) {
+		return nil, fmt.Errorf("paths and paths_ignore are not supported by the %q provider", providerBitbucket)
+	}
+
+	if cfg.Status != nil {
+		if err := cfg.Status.validate(cfg.Provider); err != nil {
+			return nil, err
+		}
+	}
+
+	filters, err := newTriggerFilters(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.filters = filters
+
 	return &cfg, nil
 }
@@ -0,0 +1,166 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	refBranchPrefix = "refs/heads/"
+	refTagPrefix    = "refs/tags/"
+)
+
+// globFilter is a set of glob patterns. Patterns support "*", which matches
+// any characters except "/", "**", which matches any characters including
+// "/", and "?", which matches a single character except "/".
+type globFilter []*regexp.Regexp
+
+func newGlobFilter(patterns []string) (globFilter, error) {
+
+	filter := make(globFilter, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		re, err := globRegexp(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		filter = append(filter, re)
+	}
+
+	return filter, nil
+}
+
+// matchAny returns whether the name matches any of the patterns.
+func (g globFilter) matchAny(name string) bool {
+	for _, re := range g {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+
+	if pattern == "" {
+		return nil, fmt.Errorf("pattern is empty")
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	expr.WriteString("$")
+
+	return regexp.Compile(expr.String())
+}
+
+// triggerFilters holds the compiled ref and path filters of the trigger
+// config.
+type triggerFilters struct {
+	branches       globFilter
+	branchesIgnore globFilter
+	tags           globFilter
+	tagsIgnore     globFilter
+	paths          globFilter
+	pathsIgnore    globFilter
+}
+
+func newTriggerFilters(cfg *triggerConfig) (*triggerFilters, error) {
+
+	var (
+		filters triggerFilters
+		err     error
+	)
+
+	for _, f := range []struct {
+		name     string
+		patterns []string
+		filter   *globFilter
+	}{
+		{name: "branches", patterns: cfg.Branches, filter: &filters.branches},
+		{name: "branches_ignore", patterns: cfg.BranchesIgnore, filter: &filters.branchesIgnore},
+		{name: "tags", patterns: cfg.Tags, filter: &filters.tags},
+		{name: "tags_ignore", patterns: cfg.TagsIgnore, filter: &filters.tagsIgnore},
+		{name: "paths", patterns: cfg.Paths, filter: &filters.paths},
+		{name: "paths_ignore", patterns: cfg.PathsIgnore, filter: &filters.pathsIgnore},
+	} {
+		if *f.filter, err = newGlobFilter(f.patterns); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.name, err)
+		}
+	}
+
+	return &filters, nil
+}
+
+// matchRef returns whether the pushed ref passes the branch and tag filters,
+// along with the reason when it does not. When only tag filters are set,
+// branch pushes do not match, and likewise when only branch filters are set,
+// tag pushes do not match.
+func (f *triggerFilters) matchRef(ref string) (bool, string) {
+
+	hasBranchFilters := len(f.branches) > 0 || len(f.branchesIgnore) > 0
+	hasTagFilters := len(f.tags) > 0 || len(f.tagsIgnore) > 0
+
+	if !hasBranchFilters && !hasTagFilters {
+		return true, ""
+	}
+
+	if branch, ok := strings.CutPrefix(ref, refBranchPrefix); ok {
+		return matchIncludeIgnore(branch, "branch", hasBranchFilters, f.branches, f.branchesIgnore)
+	}
+
+	if tag, ok := strings.CutPrefix(ref, refTagPrefix); ok {
+		return matchIncludeIgnore(tag, "tag", hasTagFilters, f.tags, f.tagsIgnore)
+	}
+
+	return false, fmt.Sprintf("ref %q is not a branch or tag", ref)
+}
+
+func matchIncludeIgnore(name, kind string, hasFilters bool, include, ignore globFilter) (bool, string) {
+
+	switch {
+	case !hasFilters:
+		return false, fmt.Sprintf("no %s filters configured", kind)
+	case len(include) > 0 && !include.matchAny(name):
+		return false, fmt.Sprintf("%s %q does not match filters", kind, name)
+	case ignore.matchAny(name):
+		return false, fmt.Sprintf("%s %q is ignored", kind, name)
+	default:
+		return true, ""
+	}
+}
+
+// matchPaths returns whether the changed files pass the path filters, along
+// with the reason when they do not. At least one file must match the paths
+// filter, and at least one must not be ignored.
+func (f *triggerFilters) matchPaths(paths []string) (bool, string) {
+
+	if len(f.paths) > 0 && !slices.ContainsFunc(paths, f.paths.matchAny) {
+		return false, "no changed files match the paths filter"
+	}
+
+	if len(f.pathsIgnore) > 0 && !slices.ContainsFunc(paths, func(p string) bool { return !f.pathsIgnore.matchAny(p) }) {
+		return false, "all changed files match the paths_ignore filter"
+	}
+
+	return true, ""
+}
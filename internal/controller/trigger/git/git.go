@@ -1,145 +1,348 @@
 package git
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v79/github"
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/logger"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
 type Trigger struct {
 	logger  *zap.Logger
-	runFlow func(flowID, namespace, trigger string, vars map[string]any) error
+	runFlow func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+	status  *statusReporter
+
+	// configs caches the decoded config of each trigger, so it is not decoded
+	// for every run update and delivery.
+	configs     map[triggerKey]*cachedConfig
+	configsLock sync.Mutex
 }
 
 type TriggerConfig struct {
 	Logger *zap.Logger
-	RunFn  func(flowID, namespace, trigger string, vars map[string]any) error
+	RunFn  func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+
+	// PublicURL is the URL of the controller HTTP API, which commit statuses
+	// link to.
+	PublicURL string
 }
 
 func NewTrigger(cfg *TriggerConfig) *Trigger {
+
+	log := cfg.Logger.Named(logger.ComponentNameTriggerGitWebhook)
+
 	return &Trigger{
-		logger:  cfg.Logger.Named(logger.ComponentNameTriggerGitWebhook),
+		logger:  log,
 		runFlow: cfg.RunFn,
+		status:  newStatusReporter(log, cfg.PublicURL),
+		configs: make(map[triggerKey]*cachedConfig),
 	}
 }
 
+func (h *Trigger) Start() { h.status.start() }
+
+func (h *Trigger) Stop() { h.status.stop() }
+
 type webhookPayload struct {
-	repo   string
-	event  string
-	branch string
+	repo  string
+	event string
+	ref   string
+
+	// action is the activity which caused the event, for events which have
+	// multiple activities, such as a pull request being opened.
+	action string
+
+	// paths is the list of files changed by the pushed commits, which is nil
+	// when the event does not include them.
+	paths []string
+
+	// ignore is the reason the event does not run the flow, regardless of
+	// the trigger filters, such as when a push only deletes refs.
+	ignore string
 
 	vars map[string]any
 }
 
-func (h *Trigger) HandleWebhook(w http.ResponseWriter, r *http.Request, trigger *state.Trigger) {
+// CreateTrigger validates the config of the trigger, so an invalid config is
+// rejected when the trigger is created rather than when a webhook arrives.
+func (h *Trigger) CreateTrigger(trigger *state.Trigger) error {
 
-	// Decode the trigger config from the any field
-	cfg, err := decodeTriggerConfig(trigger)
+	cfg, err := h.config(trigger)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Status != nil && h.status.publicURL == "" {
+		return errStatusPublicURL
+	}
+	return nil
+}
+
+// DeleteTrigger removes the cached config of the trigger.
+func (h *Trigger) DeleteTrigger(trigger *state.Trigger) {
+	h.configsLock.Lock()
+	delete(h.configs, triggerKey{namespace: trigger.Namespace, id: trigger.ID})
+	h.configsLock.Unlock()
+}
+
+// errStatusPublicURL is returned when a trigger reports commit statuses, but
+// the controller does not have a public URL for the statuses to link to.
+var errStatusPublicURL = errors.New("commit statuses require the controller public URL to be an http or https URL, set using the --http-public-url flag")
+
+// RunUpdated posts the status of the run to the commit which triggered it, if
+// the trigger is configured to report statuses.
+func (h *Trigger) RunUpdated(trigger *state.Trigger, run *state.Run) {
+
+	cfg, err := h.config(trigger)
 	if err != nil {
 		h.logger.Error("failed to decode trigger config",
 			zap.String("trigger_id", trigger.ID),
 			zap.Error(err))
-		http.Error(w, "failed to decode trigger config", http.StatusInternalServerError)
 		return
 	}
 
+	if cfg.Status == nil {
+		return
+	}
+
+	// Triggers created before the public URL was changed are still loaded, so
+	// skip reporting rather than posting statuses with a broken link.
+	if h.status.publicURL == "" {
+		h.logger.Warn("not posting commit status",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("run_id", run.ID.String()),
+			zap.Error(errStatusPublicURL))
+		return
+	}
+
+	h.status.report(cfg, run)
+}
+
+// Authenticate verifies the webhook delivery using the secret of the trigger,
+// in the way of its git provider. Deliveries to triggers without a secret are
+// not authenticated.
+func (h *Trigger) Authenticate(header http.Header, payload []byte, trigger *state.Trigger) error {
+
+	cfg, err := h.config(trigger)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Secret == "" {
+		return nil
+	}
+
+	switch cfg.Provider {
+	case providerGitHub:
+		signature := header.Get(github.SHA256SignatureHeader)
+		if signature == "" {
+			signature = header.Get(github.SHA1SignatureHeader)
+		}
+		return github.ValidateSignature(signature, payload, []byte(cfg.Secret))
+
+	case providerGitLab:
+		// GitLab sends the configured secret token as is, rather than
+		// signing the payload.
+		if subtle.ConstantTimeCompare([]byte(header.Get(gitlabTokenHeader)), []byte(cfg.Secret)) != 1 {
+			return errors.New("invalid GitLab webhook token")
+		}
+		return nil
+
+	case providerGitea:
+		signature := header.Get(giteaSignatureHeader)
+		if signature == "" {
+			signature = header.Get(forgejoSignatureHeader)
+		}
+		if !validHMACSignature(payload, cfg.Secret, signature) {
+			return errors.New("invalid Gitea webhook signature")
+		}
+		return nil
+
+	case providerBitbucket:
+		signature, ok := strings.CutPrefix(header.Get(bitbucketSignatureHeader), "sha256=")
+		if !ok || !validHMACSignature(payload, cfg.Secret, signature) {
+			return errors.New("invalid Bitbucket webhook signature")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported git provider %q", cfg.Provider)
+	}
+}
+
+// ProcessWebhook processes the authenticated webhook delivery, and runs the
+// flow if it matches the trigger.
+func (h *Trigger) ProcessWebhook(r *http.Request, trigger *state.Trigger) *delivery.Result {
+
+	// Decode the trigger config from the any field
+	cfg, err := h.config(trigger)
+	if err != nil {
+		h.logger.Error("failed to decode trigger config",
+			zap.String("trigger_id", trigger.ID),
+			zap.Error(err))
+		return &delivery.Result{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to decode trigger config",
+			Decision:   state.TriggerDeliveryDecisionFailed,
+			Err:        err,
+		}
+	}
+
 	var (
 		payload *webhookPayload
 	)
 
 	switch cfg.Provider {
-	case "github":
+	case providerGitHub:
 		payload, err = h.handleGitHubWebhook(r, cfg)
-		if err != nil {
-			http.Error(w, "failed to read request body", http.StatusBadRequest)
-			return
-		}
+	case providerGitLab:
+		payload, err = h.handleGitLabWebhook(r, cfg)
+	case providerGitea:
+		payload, err = h.handleGiteaWebhook(r, cfg)
+	case providerBitbucket:
+		payload, err = h.handleBitbucketWebhook(r, cfg)
 	default:
-		http.Error(w, "unsupported git provider", http.StatusBadRequest)
-		return
+		err = fmt.Errorf("unsupported git provider %q", cfg.Provider)
+	}
+
+	if err != nil {
+		h.logger.Debug("failed to process webhook",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("provider", cfg.Provider),
+			zap.Error(err))
+		return &delivery.Result{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to read request body",
+			Decision:   state.TriggerDeliveryDecisionRejected,
+			Err:        err,
+		}
+	}
+
+	ignored := func(reason string) *delivery.Result {
+		return &delivery.Result{
+			StatusCode: http.StatusOK,
+			Message:    reason + ", ignored",
+			Decision:   state.TriggerDeliveryDecisionIgnored,
+			Event:      payload.event,
+		}
 	}
 
 	if !slices.Contains(cfg.Events, payload.event) {
 		h.logger.Debug("event type not configured, ignoring",
 			zap.String("event", payload.event),
 			zap.Strings("configured_events", cfg.Events))
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("event type not configured, ignored"))
-		return
+		return ignored("event type not configured")
 	}
 
-	go func() {
-		if err := h.runFlow(trigger.Flow, trigger.Namespace, trigger.ID, payload.vars); err != nil {
-			h.logger.Error("failed to execute flow from webhook",
-				zap.String("trigger_id", trigger.ID),
-				zap.String("flow_id", trigger.Flow),
-				zap.Error(err))
-		}
-	}()
-
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("webhook processed successfully"))
-}
+	if payload.ignore != "" {
+		h.logger.Debug("webhook event does not run flows, ignoring",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("event", payload.event),
+			zap.String("reason", payload.ignore))
+		return ignored(payload.ignore)
+	}
 
-func (h *Trigger) handleGitHubWebhook(r *http.Request, cfg *triggerConfig) (*webhookPayload, error) {
+	if !cfg.matchAction(payload.event, payload.action) {
+		h.logger.Debug("event action not configured, ignoring",
+			zap.String("event", payload.event),
+			zap.String("action", payload.action))
+		return ignored("event action not configured")
+	}
 
-	h.logger.Debug("processing GitHub webhook",
-		zap.String("content-type", r.Header.Get("Content-Type")),
-		zap.String("event-type", r.Header.Get("X-GitHub-Event")))
+	if ok, reason := h.matchFilters(cfg, payload); !ok {
+		h.logger.Debug("webhook does not match trigger filters, ignoring",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("ref", payload.ref),
+			zap.String("reason", reason))
+		return ignored(reason)
+	}
 
-	payload, err := github.ValidatePayload(r, []byte(cfg.Secret))
+	runID, err := h.runFlow(trigger.Flow, trigger.Namespace, trigger.ID, time.Now(), payload.vars)
 	if err != nil {
-		return nil, err
+		h.logger.Error("failed to execute flow from webhook",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("flow_id", trigger.Flow),
+			zap.Error(err))
+		return &delivery.Result{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to run flow",
+			Decision:   state.TriggerDeliveryDecisionFailed,
+			Event:      payload.event,
+			Err:        err,
+		}
 	}
 
-	webHookType := github.WebHookType(r)
-
-	event, err := github.ParseWebHook(webHookType, payload)
-	if err != nil {
-		return nil, err
+	return &delivery.Result{
+		StatusCode: http.StatusOK,
+		Message:    "webhook processed successfully",
+		Decision:   state.TriggerDeliveryDecisionTriggered,
+		Event:      payload.event,
+		RunID:      runID.String(),
 	}
+}
 
-	resp := webhookPayload{}
+// matchFilters returns whether the webhook passes the ref and path filters of
+// the trigger, along with the reason when it does not. Path filters only apply
+// to branch pushes which list their changed files, so pushes such as tags and
+// new branches without commits are only filtered by their ref.
+func (h *Trigger) matchFilters(cfg *triggerConfig, payload *webhookPayload) (bool, string) {
 
-	switch event := event.(type) {
-	case *github.PushEvent:
-		resp.repo = event.GetRepo().GetFullName()
-		resp.event = webHookType
-		resp.branch = event.GetRef()
-		resp.vars = buildGithubVars(event)
-	default:
-		return nil, fmt.Errorf("unsupported GitHub event type: %s", webHookType)
+	if ok, reason := cfg.filters.matchRef(payload.ref); !ok {
+		return false, reason
+	}
+
+	if strings.HasPrefix(payload.ref, refBranchPrefix) && len(payload.paths) > 0 {
+		return cfg.filters.matchPaths(payload.paths)
 	}
 
-	return &resp, nil
+	return true, ""
 }
 
-func buildGithubVars(event *github.PushEvent) map[string]any {
-	vars := map[string]any{
-		"git_ref":        event.GetRef(),
-		"git_sha":        event.GetAfter(),
-		"git_before":     event.GetBefore(),
-		"git_repository": event.GetRepo().GetFullName(),
-		"git_pusher":     event.GetPusher().GetName(),
-	}
+// validHMACSignature returns whether the hex encoded signature is the
+// HMAC-SHA256 of the payload, using the secret as the key.
+func validHMACSignature(payload []byte, secret, signature string) bool {
 
-	if event.GetRepo() != nil {
-		vars["git_repo_name"] = event.GetRepo().GetName()
-		vars["git_repo_owner"] = event.GetRepo().GetOwner().GetLogin()
-		vars["git_repo_url"] = event.GetRepo().GetHTMLURL()
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
 	}
 
-	if event.GetHeadCommit() != nil {
-		vars["git_commit_message"] = event.GetHeadCommit().GetMessage()
-		vars["git_commit_author"] = event.GetHeadCommit().GetAuthor().GetName()
-		vars["git_commit_url"] = event.GetHeadCommit().GetURL()
-	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
 
-	return map[string]any{"trigger": vars}
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// isDeletedRef returns whether the revision a push updated the ref to is the
+// all zero SHA, which providers send when the branch or tag was deleted.
+func isDeletedRef(after string) bool {
+	return after != "" && strings.Trim(after, "0") == ""
+}
+
+// appendChangedPaths appends the changed files to the list of paths, skipping
+// any which are already present.
+func appendChangedPaths(paths []string, files ...[]string) []string {
+	for _, list := range files {
+		for _, file := range list {
+			if !slices.Contains(paths, file) {
+				paths = append(paths, file)
+			}
+		}
+	}
+	return paths
 }
@@ -0,0 +1,215 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
+)
+
+// Forgejo is a fork of Gitea, which sends the same payloads, but prefixes its
+// headers with its own name.
+const (
+	giteaEventHeader       = "X-Gitea-Event"
+	giteaSignatureHeader   = "X-Gitea-Signature"
+	forgejoEventHeader     = "X-Forgejo-Event"
+	forgejoSignatureHeader = "X-Forgejo-Signature"
+)
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaRepository struct {
+	Name     string    `json:"name"`
+	FullName string    `json:"full_name"`
+	HTMLURL  string    `json:"html_url"`
+	Owner    giteaUser `json:"owner"`
+}
+
+type giteaCommit struct {
+	ID       string   `json:"id"`
+	Message  string   `json:"message"`
+	URL      string   `json:"url"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+	Author   struct {
+		Name string `json:"name"`
+	} `json:"author"`
+}
+
+type giteaPushEvent struct {
+	Ref        string          `json:"ref"`
+	Before     string          `json:"before"`
+	After      string          `json:"after"`
+	HeadCommit *giteaCommit    `json:"head_commit"`
+	Commits    []*giteaCommit  `json:"commits"`
+	Repository giteaRepository `json:"repository"`
+	Pusher     giteaUser       `json:"pusher"`
+}
+
+type giteaPullRequestBranch struct {
+	Ref  string          `json:"ref"`
+	SHA  string          `json:"sha"`
+	Repo giteaRepository `json:"repo"`
+}
+
+type giteaPullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int    `json:"number"`
+	PullRequest struct {
+		Title   string    `json:"title"`
+		HTMLURL string    `json:"html_url"`
+		Draft   bool      `json:"draft"`
+		User    giteaUser `json:"user"`
+		Labels  []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		Head giteaPullRequestBranch `json:"head"`
+		Base giteaPullRequestBranch `json:"base"`
+	} `json:"pull_request"`
+	Repository giteaRepository `json:"repository"`
+	Sender     giteaUser       `json:"sender"`
+}
+
+func (h *Trigger) handleGiteaWebhook(r *http.Request, cfg *triggerConfig) (*webhookPayload, error) {
+
+	eventType := r.Header.Get(giteaEventHeader)
+	if eventType == "" {
+		eventType = r.Header.Get(forgejoEventHeader)
+	}
+
+	h.logger.Debug("processing Gitea webhook",
+		zap.String("content-type", r.Header.Get("Content-Type")),
+		zap.String("event-type", eventType))
+
+	body, err := delivery.ReadPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := webhookPayload{}
+
+	switch eventType {
+	case "push":
+		var event giteaPushEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode Gitea push event: %w", err)
+		}
+		resp.repo = event.Repository.FullName
+		resp.event = "push"
+		resp.ref = event.Ref
+		if isDeletedRef(event.After) {
+			resp.ignore = "push deleted the branch or tag"
+			break
+		}
+		resp.paths = giteaPushEventPaths(&event)
+		resp.vars = buildGiteaPushVars(&event)
+	case "pull_request":
+		var event giteaPullRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode Gitea pull request event: %w", err)
+		}
+		resp.repo = event.Repository.FullName
+		resp.event = "pull_request"
+		resp.action = giteaPullRequestAction(event.Action)
+		resp.ref = refBranchPrefix + event.PullRequest.Base.Ref
+		resp.vars = buildGiteaPullRequestVars(&event)
+	default:
+		return nil, fmt.Errorf("unsupported Gitea event type: %s", eventType)
+	}
+
+	return &resp, nil
+}
+
+// giteaPullRequestAction maps the pull request action onto the equivalent
+// GitHub action, which only differs for pushes to the head branch.
+func giteaPullRequestAction(action string) string {
+	if action == "synchronized" {
+		return "synchronize"
+	}
+	return action
+}
+
+func giteaPushEventPaths(event *giteaPushEvent) []string {
+
+	var paths []string
+
+	for _, commit := range event.Commits {
+		paths = appendChangedPaths(paths, commit.Added, commit.Removed, commit.Modified)
+	}
+
+	return paths
+}
+
+func buildGiteaPushVars(event *giteaPushEvent) map[string]any {
+
+	vars := map[string]any{
+		"git_event":  "push",
+		"git_ref":    event.Ref,
+		"git_sha":    event.After,
+		"git_before": event.Before,
+		"git_pusher": event.Pusher.Login,
+	}
+
+	if tag, ok := strings.CutPrefix(event.Ref, refTagPrefix); ok {
+		vars["git_tag"] = tag
+	}
+
+	if event.HeadCommit != nil {
+		vars["git_commit_message"] = event.HeadCommit.Message
+		vars["git_commit_author"] = event.HeadCommit.Author.Name
+		vars["git_commit_url"] = event.HeadCommit.URL
+	}
+
+	addGiteaRepoVars(vars, &event.Repository)
+
+	return map[string]any{"trigger": vars}
+}
+
+func buildGiteaPullRequestVars(event *giteaPullRequestEvent) map[string]any {
+
+	pr := event.PullRequest
+
+	labels := make([]string, 0, len(pr.Labels))
+	for _, label := range pr.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	// Gitea keeps the same pull refs as GitHub, so the head can be fetched
+	// even when the head branch is within a fork.
+	vars := map[string]any{
+		"git_event":           "pull_request",
+		"git_action":          giteaPullRequestAction(event.Action),
+		"git_ref":             fmt.Sprintf("refs/pull/%d/head", event.Number),
+		"git_sha":             pr.Head.SHA,
+		"git_pr_number":       event.Number,
+		"git_pr_title":        pr.Title,
+		"git_pr_url":          pr.HTMLURL,
+		"git_pr_author":       pr.User.Login,
+		"git_pr_draft":        pr.Draft,
+		"git_pr_labels":       strings.Join(labels, ","),
+		"git_head_ref":        pr.Head.Ref,
+		"git_head_sha":        pr.Head.SHA,
+		"git_head_repository": pr.Head.Repo.FullName,
+		"git_base_ref":        pr.Base.Ref,
+		"git_base_sha":        pr.Base.SHA,
+		"git_sender":          event.Sender.Login,
+	}
+
+	addGiteaRepoVars(vars, &event.Repository)
+
+	return map[string]any{"trigger": vars}
+}
+
+func addGiteaRepoVars(vars map[string]any, repo *giteaRepository) {
+	vars["git_repository"] = repo.FullName
+	vars["git_repo_name"] = repo.Name
+	vars["git_repo_owner"] = repo.Owner.Login
+	vars["git_repo_url"] = repo.HTMLURL
+}
@@ -0,0 +1,204 @@
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v79/github"
+	"go.uber.org/zap"
+)
+
+func (h *Trigger) handleGitHubWebhook(r *http.Request, cfg *triggerConfig) (*webhookPayload, error) {
+
+	h.logger.Debug("processing GitHub webhook",
+		zap.String("content-type", r.Header.Get("Content-Type")),
+		zap.String("event-type", r.Header.Get("X-GitHub-Event")))
+
+	// The signature has already been authenticated when the delivery was
+	// received, so is not checked again, which would fail redeliveries after
+	// the secret is changed. This only extracts the JSON payload of form
+	// encoded deliveries.
+	payload, err := github.ValidatePayloadFromBody(r.Header.Get("Content-Type"), r.Body, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	webHookType := github.WebHookType(r)
+
+	event, err := github.ParseWebHook(webHookType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := webhookPayload{}
+
+	switch event := event.(type) {
+	case *github.PushEvent:
+		resp.repo = event.GetRepo().GetFullName()
+		resp.event = webHookType
+		resp.ref = event.GetRef()
+		if event.GetDeleted() || isDeletedRef(event.GetAfter()) {
+			resp.ignore = "push deleted the branch or tag"
+			break
+		}
+		resp.paths = pushEventPaths(event)
+		resp.vars = buildGithubVars(event)
+	case *github.PullRequestEvent:
+		resp.repo = event.GetRepo().GetFullName()
+		resp.event = webHookType
+		resp.action = event.GetAction()
+		resp.ref = refBranchPrefix + event.GetPullRequest().GetBase().GetRef()
+		resp.vars = buildGithubPullRequestVars(event)
+	case *github.CreateEvent:
+		resp.repo = event.GetRepo().GetFullName()
+		resp.event = webHookType
+		resp.ref = createEventRef(event)
+		resp.vars = buildGithubCreateVars(event)
+	case *github.ReleaseEvent:
+		resp.repo = event.GetRepo().GetFullName()
+		resp.event = webHookType
+		resp.action = event.GetAction()
+		resp.ref = refTagPrefix + event.GetRelease().GetTagName()
+		resp.vars = buildGithubReleaseVars(event)
+	default:
+		return nil, fmt.Errorf("unsupported GitHub event type: %s", webHookType)
+	}
+
+	return &resp, nil
+}
+
+// pushEventPaths returns the unique files added, removed, or modified by the
+// commits of the push. GitHub limits the number of commits included within
+// the event, so the list may be incomplete for large pushes.
+func pushEventPaths(event *github.PushEvent) []string {
+
+	var paths []string
+
+	for _, commit := range event.Commits {
+		paths = appendChangedPaths(paths, commit.Added, commit.Removed, commit.Modified)
+	}
+
+	return paths
+}
+
+func buildGithubVars(event *github.PushEvent) map[string]any {
+	vars := map[string]any{
+		"git_event":      "push",
+		"git_ref":        event.GetRef(),
+		"git_sha":        event.GetAfter(),
+		"git_before":     event.GetBefore(),
+		"git_repository": event.GetRepo().GetFullName(),
+		"git_pusher":     event.GetPusher().GetName(),
+	}
+
+	if event.GetRepo() != nil {
+		vars["git_repo_name"] = event.GetRepo().GetName()
+		vars["git_repo_owner"] = event.GetRepo().GetOwner().GetLogin()
+		vars["git_repo_url"] = event.GetRepo().GetHTMLURL()
+	}
+
+	if event.GetHeadCommit() != nil {
+		vars["git_commit_message"] = event.GetHeadCommit().GetMessage()
+		vars["git_commit_author"] = event.GetHeadCommit().GetAuthor().GetName()
+		vars["git_commit_url"] = event.GetHeadCommit().GetURL()
+	}
+
+	return map[string]any{"trigger": vars}
+}
+
+func buildGithubPullRequestVars(event *github.PullRequestEvent) map[string]any {
+
+	pr := event.GetPullRequest()
+
+	labels := make([]string, 0, len(pr.Labels))
+	for _, label := range pr.Labels {
+		labels = append(labels, label.GetName())
+	}
+
+	// The head ref of the pull request is fetched using the pull ref, as the
+	// head branch may be within a fork.
+	vars := map[string]any{
+		"git_event":           "pull_request",
+		"git_action":          event.GetAction(),
+		"git_ref":             fmt.Sprintf("refs/pull/%d/head", pr.GetNumber()),
+		"git_sha":             pr.GetHead().GetSHA(),
+		"git_pr_number":       pr.GetNumber(),
+		"git_pr_title":        pr.GetTitle(),
+		"git_pr_url":          pr.GetHTMLURL(),
+		"git_pr_author":       pr.GetUser().GetLogin(),
+		"git_pr_draft":        pr.GetDraft(),
+		"git_pr_labels":       strings.Join(labels, ","),
+		"git_head_ref":        pr.GetHead().GetRef(),
+		"git_head_sha":        pr.GetHead().GetSHA(),
+		"git_head_repository": pr.GetHead().GetRepo().GetFullName(),
+		"git_base_ref":        pr.GetBase().GetRef(),
+		"git_base_sha":        pr.GetBase().GetSHA(),
+		"git_sender":          event.GetSender().GetLogin(),
+	}
+
+	addGithubRepoVars(vars, event.GetRepo())
+
+	return map[string]any{"trigger": vars}
+}
+
+// createEventRef returns the full ref of the branch or tag which was created.
+func createEventRef(event *github.CreateEvent) string {
+	if event.GetRefType() == "tag" {
+		return refTagPrefix + event.GetRef()
+	}
+	return refBranchPrefix + event.GetRef()
+}
+
+func buildGithubCreateVars(event *github.CreateEvent) map[string]any {
+
+	vars := map[string]any{
+		"git_event":    "create",
+		"git_ref":      createEventRef(event),
+		"git_ref_type": event.GetRefType(),
+		"git_sender":   event.GetSender().GetLogin(),
+	}
+
+	if event.GetRefType() == "tag" {
+		vars["git_tag"] = event.GetRef()
+	}
+
+	addGithubRepoVars(vars, event.GetRepo())
+
+	return map[string]any{"trigger": vars}
+}
+
+func buildGithubReleaseVars(event *github.ReleaseEvent) map[string]any {
+
+	release := event.GetRelease()
+
+	vars := map[string]any{
+		"git_event":              "release",
+		"git_action":             event.GetAction(),
+		"git_ref":                refTagPrefix + release.GetTagName(),
+		"git_tag":                release.GetTagName(),
+		"git_release_name":       release.GetName(),
+		"git_release_url":        release.GetHTMLURL(),
+		"git_release_target":     release.GetTargetCommitish(),
+		"git_release_draft":      release.GetDraft(),
+		"git_release_prerelease": release.GetPrerelease(),
+		"git_release_author":     release.GetAuthor().GetLogin(),
+		"git_sender":             event.GetSender().GetLogin(),
+	}
+
+	addGithubRepoVars(vars, event.GetRepo())
+
+	return map[string]any{"trigger": vars}
+}
+
+// addGithubRepoVars adds the variables describing the repository of the
+// event.
+func addGithubRepoVars(vars map[string]any, repo *github.Repository) {
+	if repo == nil {
+		return
+	}
+	vars["git_repository"] = repo.GetFullName()
+	vars["git_repo_name"] = repo.GetName()
+	vars["git_repo_owner"] = repo.GetOwner().GetLogin()
+	vars["git_repo_url"] = repo.GetHTMLURL()
+}
@@ -0,0 +1,226 @@
+package git
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
+)
+
+const (
+	gitlabEventHeader = "X-Gitlab-Event"
+	gitlabTokenHeader = "X-Gitlab-Token"
+
+	gitlabEventPush         = "Push Hook"
+	gitlabEventTagPush      = "Tag Push Hook"
+	gitlabEventMergeRequest = "Merge Request Hook"
+)
+
+type gitlabProject struct {
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	WebURL            string `json:"web_url"`
+}
+
+type gitlabCommit struct {
+	ID       string   `json:"id"`
+	Message  string   `json:"message"`
+	URL      string   `json:"url"`
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+	Author   struct {
+		Name string `json:"name"`
+	} `json:"author"`
+}
+
+// gitlabPushEvent is the payload of both push and tag push events.
+type gitlabPushEvent struct {
+	Ref          string          `json:"ref"`
+	Before       string          `json:"before"`
+	After        string          `json:"after"`
+	UserUsername string          `json:"user_username"`
+	Project      gitlabProject   `json:"project"`
+	Commits      []*gitlabCommit `json:"commits"`
+}
+
+type gitlabMergeRequestEvent struct {
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Project          gitlabProject `json:"project"`
+	ObjectAttributes struct {
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		URL          string `json:"url"`
+		Action       string `json:"action"`
+		Draft        bool   `json:"draft"`
+		OldRev       string `json:"oldrev"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		Source       struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"source"`
+		LastCommit struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+	Labels []struct {
+		Title string `json:"title"`
+	} `json:"labels"`
+}
+
+func (h *Trigger) handleGitLabWebhook(r *http.Request, cfg *triggerConfig) (*webhookPayload, error) {
+
+	eventType := r.Header.Get(gitlabEventHeader)
+
+	h.logger.Debug("processing GitLab webhook",
+		zap.String("content-type", r.Header.Get("Content-Type")),
+		zap.String("event-type", eventType))
+
+	body, err := delivery.ReadPayload(r)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := webhookPayload{}
+
+	switch eventType {
+	case gitlabEventPush, gitlabEventTagPush:
+		var event gitlabPushEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode GitLab push event: %w", err)
+		}
+		resp.repo = event.Project.PathWithNamespace
+		resp.event = "push"
+		resp.ref = event.Ref
+		if isDeletedRef(event.After) {
+			resp.ignore = "push deleted the branch or tag"
+			break
+		}
+		resp.paths = gitlabPushEventPaths(&event)
+		resp.vars = buildGitLabPushVars(&event)
+	case gitlabEventMergeRequest:
+		var event gitlabMergeRequestEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode GitLab merge request event: %w", err)
+		}
+		resp.repo = event.Project.PathWithNamespace
+		resp.event = "pull_request"
+		resp.action = gitlabMergeRequestAction(&event)
+		resp.ref = refBranchPrefix + event.ObjectAttributes.TargetBranch
+		resp.vars = buildGitLabMergeRequestVars(&event)
+	default:
+		return nil, fmt.Errorf("unsupported GitLab event type: %s", eventType)
+	}
+
+	return &resp, nil
+}
+
+// gitlabMergeRequestAction maps the merge request action onto the equivalent
+// GitHub pull request action, so action filters work across providers. An
+// update only maps to synchronize when it pushed new commits.
+func gitlabMergeRequestAction(event *gitlabMergeRequestEvent) string {
+	switch action := event.ObjectAttributes.Action; action {
+	case "open":
+		return "opened"
+	case "reopen":
+		return "reopened"
+	case "close":
+		return "closed"
+	case "merge":
+		return "merged"
+	case "update":
+		if event.ObjectAttributes.OldRev != "" {
+			return "synchronize"
+		}
+		return "edited"
+	default:
+		return action
+	}
+}
+
+func gitlabPushEventPaths(event *gitlabPushEvent) []string {
+
+	var paths []string
+
+	for _, commit := range event.Commits {
+		paths = appendChangedPaths(paths, commit.Added, commit.Removed, commit.Modified)
+	}
+
+	return paths
+}
+
+func buildGitLabPushVars(event *gitlabPushEvent) map[string]any {
+
+	vars := map[string]any{
+		"git_event":  "push",
+		"git_ref":    event.Ref,
+		"git_sha":    event.After,
+		"git_before": event.Before,
+		"git_pusher": event.UserUsername,
+	}
+
+	if tag, ok := strings.CutPrefix(event.Ref, refTagPrefix); ok {
+		vars["git_tag"] = tag
+	}
+
+	for _, commit := range event.Commits {
+		if commit.ID == event.After {
+			vars["git_commit_message"] = commit.Message
+			vars["git_commit_author"] = commit.Author.Name
+			vars["git_commit_url"] = commit.URL
+		}
+	}
+
+	addGitLabProjectVars(vars, &event.Project)
+
+	return map[string]any{"trigger": vars}
+}
+
+func buildGitLabMergeRequestVars(event *gitlabMergeRequestEvent) map[string]any {
+
+	attrs := event.ObjectAttributes
+
+	labels := make([]string, 0, len(event.Labels))
+	for _, label := range event.Labels {
+		labels = append(labels, label.Title)
+	}
+
+	// GitLab keeps the head of each merge request at a ref within the target
+	// project, which can be fetched even when the source branch is within a
+	// fork. The event does not include the target branch commit, or the
+	// username of the merge request author.
+	vars := map[string]any{
+		"git_event":           "pull_request",
+		"git_action":          gitlabMergeRequestAction(event),
+		"git_ref":             fmt.Sprintf("refs/merge-requests/%d/head", attrs.IID),
+		"git_sha":             attrs.LastCommit.ID,
+		"git_pr_number":       attrs.IID,
+		"git_pr_title":        attrs.Title,
+		"git_pr_url":          attrs.URL,
+		"git_pr_draft":        attrs.Draft,
+		"git_pr_labels":       strings.Join(labels, ","),
+		"git_head_ref":        attrs.SourceBranch,
+		"git_head_sha":        attrs.LastCommit.ID,
+		"git_head_repository": attrs.Source.PathWithNamespace,
+		"git_base_ref":        attrs.TargetBranch,
+		"git_sender":          event.User.Username,
+	}
+
+	addGitLabProjectVars(vars, &event.Project)
+
+	return map[string]any{"trigger": vars}
+}
+
+func addGitLabProjectVars(vars map[string]any, project *gitlabProject) {
+	vars["git_repository"] = project.PathWithNamespace
+	vars["git_repo_name"] = project.Name
+	vars["git_repo_owner"] = path.Dir(project.PathWithNamespace)
+	vars["git_repo_url"] = project.WebURL
+}
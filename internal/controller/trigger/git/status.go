@@ -0,0 +1,396 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v79/github"
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// statusQueueSize is the number of commit statuses which can be waiting to
+	// be posted, after which new statuses are dropped.
+	statusQueueSize = 256
+
+	// statusRequestTimeout is the maximum time a request to post a commit
+	// status can take.
+	statusRequestTimeout = 10 * time.Second
+
+	// statusReportedTTL is the time after which the last reported status of a
+	// run which has not been updated is forgotten, so runs which never reach
+	// a terminal status, such as those deleted while running, are not held
+	// forever.
+	statusReportedTTL = 24 * time.Hour
+
+	// statusVarSHA and statusVarRepository are the run variables which
+	// identify the commit the status is posted to.
+	statusVarSHA        = "trigger.git_sha"
+	statusVarRepository = "trigger.git_repository"
+)
+
+// defaultStatusAPIURLs are the API base URLs of the hosted git providers. Gitea
+// is always self-hosted, so does not have a default.
+var defaultStatusAPIURLs = map[string]string{
+	providerGitHub:    "https://api.github.com/",
+	providerGitLab:    "https://gitlab.com/api/v4",
+	providerBitbucket: "https://api.bitbucket.org/2.0",
+}
+
+// statusStates maps the run status onto the commit status state of each git
+// provider.
+var statusStates = map[string]map[string]string{
+	providerGitHub: {
+		state.RunStatusPending:   "pending",
+		state.RunStatusRunning:   "pending",
+		state.RunStatusSuccess:   "success",
+		state.RunStatusFailed:    "failure",
+		state.RunStatusCancelled: "error",
+	},
+	providerGitLab: {
+		state.RunStatusPending:   "pending",
+		state.RunStatusRunning:   "running",
+		state.RunStatusSuccess:   "success",
+		state.RunStatusFailed:    "failed",
+		state.RunStatusCancelled: "canceled",
+	},
+	providerGitea: {
+		state.RunStatusPending:   "pending",
+		state.RunStatusRunning:   "pending",
+		state.RunStatusSuccess:   "success",
+		state.RunStatusFailed:    "failure",
+		state.RunStatusCancelled: "error",
+	},
+	providerBitbucket: {
+		state.RunStatusPending:   "INPROGRESS",
+		state.RunStatusRunning:   "INPROGRESS",
+		state.RunStatusSuccess:   "SUCCESSFUL",
+		state.RunStatusFailed:    "FAILED",
+		state.RunStatusCancelled: "STOPPED",
+	},
+}
+
+// statusDescriptions are the descriptions of the commit status for each run
+// status.
+var statusDescriptions = map[string]string{
+	state.RunStatusPending:   "Run is pending",
+	state.RunStatusRunning:   "Run is in progress",
+	state.RunStatusSuccess:   "Run succeeded",
+	state.RunStatusFailed:    "Run failed",
+	state.RunStatusCancelled: "Run was cancelled",
+}
+
+// statusConfig configures the commit statuses posted for runs started by the
+// trigger.
+type statusConfig struct {
+	// Token is the API token used to post the statuses, which must be
+	// permitted to write commit statuses to the repository.
+	Token string `hcl:"token" json:"token"`
+
+	// Context identifies the status on the commit, allowing multiple flows to
+	// post statuses to the same commit. Defaults to "nomad-pipeline/<flow>".
+	Context string `hcl:"context,optional" json:"context,omitempty"`
+
+	// APIURL is the base URL of the provider API, which defaults to that of
+	// the hosted provider.
+	APIURL string `hcl:"api_url,optional" json:"api_url,omitempty"`
+}
+
+func (c *statusConfig) validate(provider string) error {
+
+	if c.Token == "" {
+		return fmt.Errorf("status token is required")
+	}
+
+	if c.APIURL == "" && defaultStatusAPIURLs[provider] == "" {
+		return fmt.Errorf("status api_url is required for provider %q", provider)
+	}
+
+	if c.APIURL != "" {
+		if _, err := url.ParseRequestURI(c.APIURL); err != nil {
+			return fmt.Errorf("invalid status api_url: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *statusConfig) apiURL(provider string) string {
+	if c.APIURL != "" {
+		return strings.TrimSuffix(c.APIURL, "/")
+	}
+	return strings.TrimSuffix(defaultStatusAPIURLs[provider], "/")
+}
+
+// commitStatus is a status to be posted to a commit.
+type commitStatus struct {
+	provider    string
+	cfg         *statusConfig
+	repo        string
+	sha         string
+	state       string
+	context     string
+	description string
+	targetURL   string
+}
+
+// statusReporter posts commit statuses in the background, in the order the
+// run updates are received, so updates are not delayed by the provider API.
+type statusReporter struct {
+	logger    *zap.Logger
+	client    *http.Client
+	publicURL string
+
+	// reported is the last run status reported for each run, so a status is
+	// only posted when the run status changes. Runs are removed once they
+	// reach a terminal status, or have not been updated within the TTL.
+	reported     map[state.RunNamespacedKey]*reportedStatus
+	reportedLock sync.Mutex
+
+	queue  chan *commitStatus
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// reportedStatus is the last status reported for a run, and when the run was
+// last updated.
+type reportedStatus struct {
+	status  string
+	updated time.Time
+}
+
+func newStatusReporter(logger *zap.Logger, publicURL string) *statusReporter {
+
+	// Statuses link to the run, so the public URL must be absolute for the
+	// provider to accept and render the link.
+	if u, err := url.Parse(publicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		publicURL = ""
+	}
+
+	return &statusReporter{
+		logger:    logger,
+		client:    &http.Client{Timeout: statusRequestTimeout},
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		reported:  make(map[state.RunNamespacedKey]*reportedStatus),
+		queue:     make(chan *commitStatus, statusQueueSize),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+func (s *statusReporter) start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+func (s *statusReporter) stop() {
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+func (s *statusReporter) run() {
+	defer s.wg.Done()
+
+	expireTicker := time.NewTicker(time.Hour)
+	defer expireTicker.Stop()
+
+	for {
+		select {
+		case <-expireTicker.C:
+			s.expireReported(time.Now())
+		case status := <-s.queue:
+			if err := s.post(status); err != nil {
+				s.logger.Error("failed to post commit status",
+					zap.String("provider", status.provider),
+					zap.String("repository", status.repo),
+					zap.String("sha", status.sha),
+					zap.Error(err))
+			}
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// report queues the commit status describing the run, if the run status has
+// changed since it was last reported.
+func (s *statusReporter) report(cfg *triggerConfig, run *state.Run) {
+
+	runState, ok := statusStates[cfg.Provider][run.Status]
+	if !ok {
+		return
+	}
+
+	sha, _ := run.Variables[statusVarSHA].(string)
+	repo, _ := run.Variables[statusVarRepository].(string)
+
+	if sha == "" || repo == "" {
+		s.logger.Debug("run does not include commit variables, skipping status",
+			zap.String("run_id", run.ID.String()),
+			zap.String("flow_id", run.FlowID))
+		return
+	}
+
+	key := state.RunNamespacedKey{ID: run.ID, Namespace: run.Namespace}
+
+	s.reportedLock.Lock()
+	if reported, ok := s.reported[key]; ok && reported.status == run.Status {
+		reported.updated = time.Now()
+		s.reportedLock.Unlock()
+		return
+	}
+	if state.IsTerminalStatus(run.Status) {
+		delete(s.reported, key)
+	} else {
+		s.reported[key] = &reportedStatus{status: run.Status, updated: time.Now()}
+	}
+	s.reportedLock.Unlock()
+
+	statusContext := cfg.Status.Context
+	if statusContext == "" {
+		statusContext = "nomad-pipeline/" + run.FlowID
+	}
+
+	status := commitStatus{
+		provider:    cfg.Provider,
+		cfg:         cfg.Status,
+		repo:        repo,
+		sha:         sha,
+		state:       runState,
+		context:     statusContext,
+		description: statusDescriptions[run.Status],
+		targetURL: fmt.Sprintf("%s/v1/runs/%s?namespace=%s",
+			s.publicURL, run.ID, url.QueryEscape(run.Namespace)),
+	}
+
+	select {
+	case s.queue <- &status:
+	default:
+		s.logger.Warn("commit status queue is full, dropping status",
+			zap.String("run_id", run.ID.String()),
+			zap.String("status", run.Status))
+	}
+}
+
+// expireReported removes the reported statuses of runs which have not been
+// updated within the TTL.
+func (s *statusReporter) expireReported(now time.Time) {
+
+	s.reportedLock.Lock()
+	defer s.reportedLock.Unlock()
+
+	for key, reported := range s.reported {
+		if now.Sub(reported.updated) > statusReportedTTL {
+			delete(s.reported, key)
+		}
+	}
+}
+
+func (s *statusReporter) post(status *commitStatus) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), statusRequestTimeout)
+	defer cancel()
+
+	switch status.provider {
+	case providerGitHub:
+		return s.postGitHub(ctx, status)
+	case providerGitLab:
+		return s.postJSON(ctx, status,
+			fmt.Sprintf("/projects/%s/statuses/%s", url.PathEscape(status.repo), status.sha),
+			map[string]string{"PRIVATE-TOKEN": status.cfg.Token},
+			map[string]string{
+				"state":       status.state,
+				"name":        status.context,
+				"description": status.description,
+				"target_url":  status.targetURL,
+			})
+	case providerGitea:
+		return s.postJSON(ctx, status,
+			fmt.Sprintf("/repos/%s/statuses/%s", status.repo, status.sha),
+			map[string]string{"Authorization": "token " + status.cfg.Token},
+			map[string]string{
+				"state":       status.state,
+				"context":     status.context,
+				"description": status.description,
+				"target_url":  status.targetURL,
+			})
+	case providerBitbucket:
+		return s.postJSON(ctx, status,
+			fmt.Sprintf("/repositories/%s/commit/%s/statuses/build", status.repo, status.sha),
+			map[string]string{"Authorization": "Bearer " + status.cfg.Token},
+			map[string]string{
+				"key":         status.context,
+				"name":        status.context,
+				"state":       status.state,
+				"description": status.description,
+				"url":         status.targetURL,
+			})
+	default:
+		return fmt.Errorf("unsupported git provider %q", status.provider)
+	}
+}
+
+func (s *statusReporter) postGitHub(ctx context.Context, status *commitStatus) error {
+
+	owner, repo, ok := strings.Cut(status.repo, "/")
+	if !ok {
+		return fmt.Errorf("invalid repository %q", status.repo)
+	}
+
+	client := github.NewClient(s.client).WithAuthToken(status.cfg.Token)
+
+	baseURL, err := url.Parse(status.cfg.apiURL(providerGitHub) + "/")
+	if err != nil {
+		return fmt.Errorf("invalid API URL: %w", err)
+	}
+	client.BaseURL = baseURL
+
+	_, _, err = client.Repositories.CreateStatus(ctx, owner, repo, status.sha, github.RepoStatus{
+		State:       github.Ptr(status.state),
+		Context:     github.Ptr(status.context),
+		Description: github.Ptr(status.description),
+		TargetURL:   github.Ptr(status.targetURL),
+	})
+	return err
+}
+
+// postJSON posts the JSON body to the API path of the provider, for providers
+// which do not have a client library.
+func (s *statusReporter) postJSON(ctx context.Context, status *commitStatus, path string, headers map[string]string, body any) error {
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		status.cfg.apiURL(status.provider)+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %q", resp.Status)
+	}
+
+	return nil
+}
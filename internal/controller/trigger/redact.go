@@ -0,0 +1,115 @@
+package trigger
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// redactedValue replaces the value of secret attributes within trigger
+// configs returned by the API.
+const redactedValue = "<redacted>"
+
+// secretAttributes are the names of the trigger config attributes, at any
+// depth, which hold secrets. These are the webhook secrets, and the API token
+// used to post commit statuses.
+var secretAttributes = []string{"secret", "token"}
+
+// Redact returns a copy of the trigger, where the values of secret attributes
+// within its config are replaced. A config which cannot be parsed is removed,
+// as its secrets cannot be found.
+func Redact(trigger *state.Trigger) *state.Trigger {
+
+	if trigger == nil || trigger.Source == nil || len(trigger.Source.Config) == 0 {
+		return trigger
+	}
+
+	redacted := *trigger
+	source := *trigger.Source
+	redacted.Source = &source
+
+	file, diags := hclwrite.ParseConfig(source.Config, "config.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		source.Config = nil
+		return &redacted
+	}
+
+	redactBody(file.Body())
+	source.Config = file.Bytes()
+
+	return &redacted
+}
+
+func redactBody(body *hclwrite.Body) {
+	for name := range body.Attributes() {
+		if slices.Contains(secretAttributes, name) {
+			body.SetAttributeValue(name, cty.StringVal(redactedValue))
+		}
+	}
+	for _, block := range body.Blocks() {
+		redactBody(block.Body())
+	}
+}
+
+// Unredact restores the secret attributes of the updated trigger config which
+// still hold the redacted value, from the existing trigger config. This allows
+// a trigger returned by the API to be modified and updated.
+func Unredact(existing, updated *state.Trigger) {
+
+	if existing.Source == nil || updated.Source == nil || len(updated.Source.Config) == 0 {
+		return
+	}
+
+	existingFile, diags := hclwrite.ParseConfig(existing.Source.Config, "config.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return
+	}
+	updatedFile, diags := hclwrite.ParseConfig(updated.Source.Config, "config.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return
+	}
+
+	if unredactBody(existingFile.Body(), updatedFile.Body()) {
+		updated.Source.Config = updatedFile.Bytes()
+	}
+}
+
+// unredactBody restores the redacted attributes of the updated body, and of
+// its blocks which have a matching block within the existing body. It returns
+// whether any attributes were restored.
+func unredactBody(existing, updated *hclwrite.Body) bool {
+
+	var restored bool
+
+	for name, attr := range updated.Attributes() {
+		if !slices.Contains(secretAttributes, name) || !isRedacted(attr) {
+			continue
+		}
+		if existingAttr := existing.GetAttribute(name); existingAttr != nil {
+			updated.SetAttributeRaw(name, existingAttr.Expr().BuildTokens(nil))
+			restored = true
+		}
+	}
+
+	for _, block := range updated.Blocks() {
+		if existingBlock := existing.FirstMatchingBlock(block.Type(), block.Labels()); existingBlock != nil {
+			if unredactBody(existingBlock.Body(), block.Body()) {
+				restored = true
+			}
+		}
+	}
+
+	return restored
+}
+
+// isRedacted returns whether the value of the attribute is the redacted value.
+func isRedacted(attr *hclwrite.Attribute) bool {
+	value := strings.TrimSpace(string(attr.Expr().BuildTokens(nil).Bytes()))
+	return value == strconv.Quote(redactedValue)
+}
@@ -1,17 +1,75 @@
 package schedule
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
+	// The controller may run within a container image which does not include
+	// the time zone database, so embed it to ensure time zones can be loaded.
+	_ "time/tzdata"
+
+	"github.com/hashicorp/cronexpr"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 
+	hhcl "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/hcl"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
+// The overlap policies control what happens when the trigger fires while a run
+// of the flow is active.
+const (
+	overlapAllow          = "allow"
+	overlapSkip           = "skip"
+	overlapQueue          = "queue"
+	overlapCancelPrevious = "cancel_previous"
+)
+
+// The catch-up policies control which of the times missed while the controller
+// was not running are run when it starts.
+const (
+	catchupNone   = "none"
+	catchupLatest = "latest"
+	catchupAll    = "all"
+)
+
+// defaultCatchupLimit is the maximum number of missed times run by the "all"
+// catch-up policy when the trigger does not set a limit.
+const defaultCatchupLimit = 10
+
 type triggerConfig struct {
 	Crons []string `hcl:"crons" json:"crons"`
+
+	// Timezone is the IANA time zone the cron expressions are evaluated in,
+	// which defaults to the local time zone of the controller.
+	Timezone string `hcl:"timezone,optional" json:"timezone,omitempty"`
+
+	// Jitter is the maximum random delay added to each scheduled time, which
+	// spreads the load of triggers scheduled at the same time.
+	Jitter string `hcl:"jitter,optional" json:"jitter,omitempty"`
+
+	Variables *variablesConfig `hcl:"variables,block" json:"-"`
+
+	// Overlap is the policy applied when the trigger fires while a run of the
+	// flow is pending or running, which defaults to starting another run.
+	Overlap string `hcl:"overlap,optional" json:"overlap,omitempty"`
+
+	// Catchup is the policy used to run the times missed while the controller
+	// was not running, which defaults to skipping them. CatchupLimit is the
+	// maximum number of missed times run by the "all" policy.
+	Catchup      string `hcl:"catchup,optional" json:"catchup,omitempty"`
+	CatchupLimit int    `hcl:"catchup_limit,optional" json:"catchup_limit,omitempty"`
+
+	crons     []*cronexpr.Expression
+	location  *time.Location
+	jitter    time.Duration
+	variables map[string]any
+}
+
+type variablesConfig struct {
+	Remain hcl.Body `hcl:",remain"`
 }
 
 func decodeTriggerConfig(trigger *state.Trigger) (*triggerConfig, error) {
@@ -31,5 +89,100 @@ func decodeTriggerConfig(trigger *state.Trigger) (*triggerConfig, error) {
 		return nil, fmt.Errorf("failed to decode HCL config: %w", diags)
 	}
 
+	if len(cfg.Crons) == 0 {
+		return nil, errors.New("at least one cron expression is required")
+	}
+
+	for _, cron := range cfg.Crons {
+		cronExpr, err := cronexpr.Parse(cron)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cron expression %q: %w", cron, err)
+		}
+		cfg.crons = append(cfg.crons, cronExpr)
+	}
+
+	cfg.location = time.Local
+	if cfg.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", cfg.Timezone, err)
+		}
+		cfg.location = loc
+	}
+
+	if cfg.Jitter != "" {
+		jitter, err := time.ParseDuration(cfg.Jitter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jitter %q: %w", cfg.Jitter, err)
+		}
+		if jitter < 0 {
+			return nil, fmt.Errorf("jitter must not be negative, got %q", cfg.Jitter)
+		}
+		cfg.jitter = jitter
+	}
+
+	switch cfg.Overlap {
+	case "":
+		cfg.Overlap = overlapAllow
+	case overlapAllow, overlapSkip, overlapQueue, overlapCancelPrevious:
+	default:
+		return nil, fmt.Errorf("invalid overlap policy %q, must be one of %q, %q, %q, or %q",
+			cfg.Overlap, overlapAllow, overlapSkip, overlapQueue, overlapCancelPrevious)
+	}
+
+	switch cfg.Catchup {
+	case "":
+		cfg.Catchup = catchupNone
+	case catchupNone, catchupLatest, catchupAll:
+	default:
+		return nil, fmt.Errorf("invalid catchup policy %q, must be one of %q, %q, or %q",
+			cfg.Catchup, catchupNone, catchupLatest, catchupAll)
+	}
+
+	switch {
+	case cfg.CatchupLimit < 0:
+		return nil, fmt.Errorf("catchup_limit must not be negative, got %d", cfg.CatchupLimit)
+	case cfg.CatchupLimit > 0 && cfg.Catchup != catchupAll:
+		return nil, fmt.Errorf("catchup_limit requires the %q catchup policy", catchupAll)
+	case cfg.CatchupLimit == 0:
+		cfg.CatchupLimit = defaultCatchupLimit
+	}
+
+	if cfg.Variables != nil {
+		vars, err := decodeVariables(cfg.Variables)
+		if err != nil {
+			return nil, err
+		}
+		cfg.variables = vars
+	}
+
 	return &cfg, nil
 }
+
+// decodeVariables returns the static values of the variables block, which are
+// evaluated without any variables or functions.
+func decodeVariables(cfg *variablesConfig) (map[string]any, error) {
+
+	attrs, diags := cfg.Remain.JustAttributes()
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to decode variables: %w", diags)
+	}
+
+	vars := make(map[string]any, len(attrs))
+
+	for name, attr := range attrs {
+
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to decode variable %q: %w", name, diags)
+		}
+
+		goVal, err := hhcl.CtyToGo(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert variable %q: %w", name, err)
+		}
+		vars[name] = goVal
+	}
+
+	return vars, nil
+}
@@ -1,18 +1,118 @@
 package schedule
 
 import (
+	"math/rand/v2"
+	"sync"
 	"time"
 
-	"github.com/hashicorp/cronexpr"
-
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
 type scheduledTrigger struct {
-	trigger  *state.Trigger
-	nextRun  time.Time
-	cronExpr *cronexpr.Expression
-	index    int
+	trigger *state.Trigger
+	cfg     *triggerConfig
+
+	// scheduledTime is the next time matching the cron expressions, and
+	// nextRun is the time the trigger runs the flow, which includes any
+	// jitter.
+	scheduledTime time.Time
+	nextRun       time.Time
+
+	// queue contains the scheduled times waiting for the active runs of the
+	// flow to finish, when using the queue overlap policy. It is protected by
+	// the lock of the trigger coordinator.
+	queue []time.Time
+
+	// fireLock ensures the trigger fires one time at a time, so the active
+	// runs of the flow include the run started by the previous time.
+	fireLock sync.Mutex
+
+	// lastFireTime is the most recent scheduled time recorded as fired, which
+	// is protected by fireLock.
+	lastFireTime time.Time
+
+	index int
+}
+
+// schedule sets the next time the trigger runs, using the first time after the
+// passed time which matches any of the cron expressions. It returns false if
+// none of the expressions match a future time.
+func (st *scheduledTrigger) schedule(after time.Time) bool {
+
+	next := st.nextTime(after)
+	if next.IsZero() {
+		return false
+	}
+
+	st.scheduledTime = next
+	st.nextRun = next
+
+	if st.cfg.jitter > 0 {
+		st.nextRun = next.Add(rand.N(st.cfg.jitter))
+	}
+
+	return true
+}
+
+// nextTime returns the first time after the passed time which matches any of
+// the cron expressions, or the zero time if none match.
+func (st *scheduledTrigger) nextTime(after time.Time) time.Time {
+
+	after = after.In(st.cfg.location)
+
+	var next time.Time
+
+	for _, cronExpr := range st.cfg.crons {
+		if t := cronExpr.Next(after); !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// missedTimesWindow is the length of the first window before now searched for
+// missed times.
+const missedTimesWindow = time.Minute
+
+// missedTimes returns the times between the last time the trigger fired and
+// now, which are run according to the catch-up policy. Only the most recent
+// times are returned, up to the limit of the policy.
+func (st *scheduledTrigger) missedTimes(now time.Time) []time.Time {
+
+	if st.cfg.Catchup == catchupNone || st.trigger.LastFireTime.IsZero() {
+		return nil
+	}
+
+	limit := 1
+	if st.cfg.Catchup == catchupAll {
+		limit = st.cfg.CatchupLimit
+	}
+
+	// The cron expressions can only be walked forwards, and walking from the
+	// last fire time could mean every slot of a long outage. Instead, windows
+	// of doubling length before now are walked, until one holds enough times
+	// or reaches back to the last fire time.
+	for window := missedTimesWindow; ; window *= 2 {
+
+		from := now.Add(-window)
+		if !from.After(st.trigger.LastFireTime) {
+			from = st.trigger.LastFireTime
+		}
+
+		var missed []time.Time
+
+		for t := st.nextTime(from); !t.IsZero() && !t.After(now); t = st.nextTime(t) {
+			if len(missed) == limit {
+				missed = missed[1:]
+			}
+			missed = append(missed, t)
+		}
+
+		if len(missed) == limit || from.Equal(st.trigger.LastFireTime) {
+			return missed
+		}
+	}
 }
 
 type triggerHeap []*scheduledTrigger
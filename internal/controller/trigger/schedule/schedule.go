@@ -3,10 +3,11 @@ package schedule
 import (
 	"container/heap"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
-	"github.com/hashicorp/cronexpr"
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 
 	serverstate "github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
@@ -14,12 +15,18 @@ import (
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
+// maxQueuedTimes is the number of scheduled times each trigger can have waiting
+// for the active runs of its flow to finish, after which further times are
+// skipped.
+const maxQueuedTimes = 10
+
 // TriggerCoordinator manages scheduled trigger execution using a heap-based priority queue
 type Trigger struct {
 	logger *zap.Logger
 	state  serverstate.State
 
-	runFn func(flowID, namespace, trigger string, vars map[string]any) error
+	runFn    func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+	cancelFn func(id ulid.ULID, namespace string) error
 
 	// heap is the priority queue of scheduled triggers
 	heap *triggerHeap
@@ -38,7 +45,11 @@ type Trigger struct {
 type TriggerConfig struct {
 	Logger *zap.Logger
 	State  serverstate.State
-	RunFn  func(flowID, namespace, trigger string, vars map[string]any) error
+	RunFn  func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+
+	// CancelFn cancels the active runs of the flow when using the
+	// cancel_previous overlap policy.
+	CancelFn func(id ulid.ULID, namespace string) error
 }
 
 // NewTriggerCoordinator creates a new trigger coordinator
@@ -50,6 +61,7 @@ func NewTrigger(cfg *TriggerConfig) *Trigger {
 		logger:   cfg.Logger.Named(logger.ComponentNameTriggerSchedule),
 		state:    cfg.State,
 		runFn:    cfg.RunFn,
+		cancelFn: cfg.CancelFn,
 		heap:     h,
 		triggers: make(map[string]*scheduledTrigger),
 		stopCh:   make(chan struct{}),
@@ -80,6 +92,13 @@ func (tc *Trigger) Stop() {
 
 // AddTrigger adds a new trigger to the scheduler
 func (tc *Trigger) CreateTrigger(trigger *state.Trigger) error {
+	return tc.addTrigger(trigger, false)
+}
+
+// addTrigger adds the trigger to the scheduler. When catchup is true, the
+// times missed since the trigger last fired are run according to its catch-up
+// policy, which is only performed when loading triggers on start.
+func (tc *Trigger) addTrigger(trigger *state.Trigger, catchup bool) error {
 	tc.lock.Lock()
 	defer tc.lock.Unlock()
 
@@ -88,36 +107,71 @@ func (tc *Trigger) CreateTrigger(trigger *state.Trigger) error {
 		return err
 	}
 
-	for _, cron := range cfg.Crons {
+	st := tc.insertTrigger(trigger, cfg)
 
-		cronExpr, err := cronexpr.Parse(cron)
-		if err != nil {
-			return fmt.Errorf("failed to parse cron expression %q: %w", cron, err)
+	if catchup && !trigger.Paused {
+		if missed := st.missedTimes(time.Now()); len(missed) > 0 {
+			tc.logger.Info("catching up on missed trigger times",
+				zap.String("trigger_id", trigger.ID),
+				zap.String("namespace", trigger.Namespace),
+				zap.Int("missed", len(missed)),
+			)
+
+			// The missed times are run in order, so each one observes the
+			// runs started by the previous times.
+			go func() {
+				for _, scheduledTime := range missed {
+					tc.fire(st, scheduledTime)
+				}
+			}()
 		}
+	}
 
-		nextRun := cronExpr.Next(time.Now())
+	return nil
+}
 
-		st := &scheduledTrigger{
-			trigger:  trigger,
-			nextRun:  nextRun,
-			cronExpr: cronExpr,
-		}
+// insertTrigger adds the decoded trigger to the map of triggers, and schedules
+// its next run unless it is paused. The caller must hold the lock.
+func (tc *Trigger) insertTrigger(trigger *state.Trigger, cfg *triggerConfig) *scheduledTrigger {
 
-		tc.triggers[tc.triggerKey(trigger)] = st
-		heap.Push(tc.heap, st)
+	st := &scheduledTrigger{
+		trigger:      trigger,
+		cfg:          cfg,
+		lastFireTime: trigger.LastFireTime,
+		index:        -1,
+	}
 
-		tc.logger.Info("added trigger to scheduler",
+	tc.triggers[tc.triggerKey(trigger)] = st
+
+	if trigger.Paused {
+		tc.logger.Info("trigger is paused, not scheduling",
 			zap.String("trigger_id", trigger.ID),
 			zap.String("namespace", trigger.Namespace),
-			zap.String("flow_id", trigger.Flow),
-			zap.Time("next_run", nextRun),
 		)
+		return st
 	}
 
+	if !st.schedule(time.Now()) {
+		tc.logger.Warn("trigger cron expressions do not match any future time",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("namespace", trigger.Namespace),
+		)
+		return st
+	}
+
+	heap.Push(tc.heap, st)
+
+	tc.logger.Info("added trigger to scheduler",
+		zap.String("trigger_id", trigger.ID),
+		zap.String("namespace", trigger.Namespace),
+		zap.String("flow_id", trigger.Flow),
+		zap.Time("next_run", st.nextRun),
+	)
+
 	// Signal the scheduler to update
 	tc.signalUpdate()
 
-	return nil
+	return st
 }
 
 // RemoveTrigger removes a trigger from the scheduler
@@ -149,20 +203,40 @@ func (tc *Trigger) DeleteTrigger(trigger *state.Trigger) error {
 	return nil
 }
 
-// UpdateTrigger updates an existing trigger in the scheduler
+// UpdateTrigger updates an existing trigger in the scheduler. The config is
+// decoded before the existing trigger is removed, so the existing trigger
+// remains scheduled if the config is invalid.
 func (tc *Trigger) UpdateTrigger(trigger *state.Trigger) error {
-	// Remove and re-add to update the schedule
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	cfg, err := decodeTriggerConfig(trigger)
+	if err != nil {
+		return err
+	}
+
 	key := tc.triggerKey(trigger)
 
-	tc.lock.Lock()
-	st, exists := tc.triggers[key]
-	if exists && st.index >= 0 && st.index < tc.heap.Len() {
-		heap.Remove(tc.heap, st.index)
+	var queue []time.Time
+
+	if existing, ok := tc.triggers[key]; ok {
+		if existing.index >= 0 && existing.index < tc.heap.Len() {
+			heap.Remove(tc.heap, existing.index)
+		}
+		queue = existing.queue
+		existing.queue = nil
 		delete(tc.triggers, key)
 	}
-	tc.lock.Unlock()
 
-	return tc.CreateTrigger(trigger)
+	st := tc.insertTrigger(trigger, cfg)
+
+	// Queued times are kept across updates, but are dropped when the trigger
+	// is paused or no longer uses the queue overlap policy.
+	if !trigger.Paused && cfg.Overlap == overlapQueue {
+		st.queue = queue
+	}
+
+	return nil
 }
 
 // run is the main scheduling loop
@@ -206,8 +280,20 @@ func (tc *Trigger) checkAndExecuteTriggers() {
 		// Execute the trigger in a goroutine
 		tc.executeTrigger(st)
 
-		// Calculate next run time and re-add to heap
-		st.nextRun = st.cronExpr.Next(now)
+		// Calculate the next run time from the time just run, so jitter does
+		// not cause the following time to be skipped. If the scheduler fell
+		// behind, the missed times are skipped.
+		scheduled := st.schedule(st.scheduledTime)
+		if scheduled && st.scheduledTime.Before(now) {
+			scheduled = st.schedule(now)
+		}
+		if !scheduled {
+			tc.logger.Warn("trigger cron expressions do not match any future time",
+				zap.String("trigger_id", st.trigger.ID),
+				zap.String("namespace", st.trigger.Namespace),
+			)
+			continue
+		}
 		heap.Push(tc.heap, st)
 
 		tc.logger.Debug("rescheduled trigger",
@@ -224,26 +310,222 @@ func (tc *Trigger) executeTrigger(st *scheduledTrigger) {
 		zap.String("trigger_id", st.trigger.ID),
 		zap.String("namespace", st.trigger.Namespace),
 		zap.String("flow_id", st.trigger.Flow),
-		zap.Time("scheduled_time", st.nextRun),
+		zap.Time("scheduled_time", st.scheduledTime),
 	)
 
 	// Execute flow in a separate goroutine to avoid blocking
-	go func(trigger *state.Trigger) {
-		if err := tc.runFn(trigger.Flow, trigger.Namespace, trigger.ID, nil); err != nil {
-			tc.logger.Error("failed to execute triggered flow",
+	go tc.fire(st, st.scheduledTime)
+}
+
+// fire runs the flow for the scheduled time, applying the overlap policy of the
+// trigger when runs of the flow are active.
+func (tc *Trigger) fire(st *scheduledTrigger, scheduledTime time.Time) {
+	st.fireLock.Lock()
+	defer st.fireLock.Unlock()
+
+	trigger := st.trigger
+
+	if st.cfg.Catchup != catchupNone {
+		tc.recordFireTime(st, scheduledTime)
+	}
+
+	if st.cfg.Overlap != overlapAllow {
+
+		active, err := tc.activeRuns(trigger)
+		if err != nil {
+			tc.logger.Error("failed to list active runs of triggered flow",
 				zap.String("trigger_id", trigger.ID),
 				zap.String("namespace", trigger.Namespace),
 				zap.String("flow_id", trigger.Flow),
 				zap.Error(err),
 			)
-		} else {
-			tc.logger.Info("successfully triggered flow",
-				zap.String("trigger_id", trigger.ID),
-				zap.String("namespace", trigger.Namespace),
-				zap.String("flow_id", trigger.Flow),
-			)
+			return
+		}
+
+		if len(active) > 0 && !tc.applyOverlapPolicy(st, scheduledTime, active) {
+			return
+		}
+	}
+
+	if _, err := tc.runFn(trigger.Flow, trigger.Namespace, trigger.ID, scheduledTime, st.cfg.variables); err != nil {
+		tc.logger.Error("failed to execute triggered flow",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("namespace", trigger.Namespace),
+			zap.String("flow_id", trigger.Flow),
+			zap.Error(err),
+		)
+	} else {
+		tc.logger.Info("successfully triggered flow",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("namespace", trigger.Namespace),
+			zap.String("flow_id", trigger.Flow),
+		)
+	}
+}
+
+// applyOverlapPolicy handles the scheduled time firing while runs of the flow
+// are active, and returns whether the flow should still be run.
+func (tc *Trigger) applyOverlapPolicy(st *scheduledTrigger, scheduledTime time.Time, active []*state.RunStub) bool {
+
+	switch st.cfg.Overlap {
+	case overlapSkip:
+		tc.logger.Info("skipping trigger, flow has active runs",
+			zap.String("trigger_id", st.trigger.ID),
+			zap.String("namespace", st.trigger.Namespace),
+			zap.Time("scheduled_time", scheduledTime),
+			zap.Int("active_runs", len(active)),
+		)
+		return false
+
+	case overlapQueue:
+		tc.enqueue(st, scheduledTime)
+		tc.runQueueIfIdle(st)
+		return false
+
+	case overlapCancelPrevious:
+		for _, run := range active {
+			if err := tc.cancelFn(run.ID, run.Namespace); err != nil {
+				tc.logger.Error("failed to cancel previous run",
+					zap.String("trigger_id", st.trigger.ID),
+					zap.String("namespace", run.Namespace),
+					zap.String("run_id", run.ID.String()),
+					zap.Error(err),
+				)
+			}
+		}
+		return true
+
+	default:
+		return true
+	}
+}
+
+// enqueue adds the scheduled time to the queue of the trigger, which is run
+// once the active runs of the flow have finished.
+func (tc *Trigger) enqueue(st *scheduledTrigger, scheduledTime time.Time) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	if len(st.queue) >= maxQueuedTimes {
+		tc.logger.Warn("trigger queue is full, skipping scheduled time",
+			zap.String("trigger_id", st.trigger.ID),
+			zap.String("namespace", st.trigger.Namespace),
+			zap.Time("scheduled_time", scheduledTime),
+		)
+		return
+	}
+
+	// Times taken from the queue are returned to it if the flow still has
+	// active runs, so keep the queue ordered by the scheduled time.
+	idx, _ := slices.BinarySearchFunc(st.queue, scheduledTime, time.Time.Compare)
+	st.queue = slices.Insert(st.queue, idx, scheduledTime)
+
+	tc.logger.Info("queued trigger until active runs of flow finish",
+		zap.String("trigger_id", st.trigger.ID),
+		zap.String("namespace", st.trigger.Namespace),
+		zap.Time("scheduled_time", scheduledTime),
+		zap.Int("queued", len(st.queue)),
+	)
+}
+
+// runQueueIfIdle runs the next queued time of the trigger if the flow no longer
+// has active runs. The runs may have finished between being listed and the
+// time being queued, in which case RunFinished found nothing to run and the
+// queued time would otherwise wait for the next run of the flow to finish.
+func (tc *Trigger) runQueueIfIdle(st *scheduledTrigger) {
+
+	active, err := tc.activeRuns(st.trigger)
+	if err != nil {
+		tc.logger.Error("failed to list active runs of triggered flow",
+			zap.String("trigger_id", st.trigger.ID),
+			zap.String("namespace", st.trigger.Namespace),
+			zap.String("flow_id", st.trigger.Flow),
+			zap.Error(err),
+		)
+		return
+	}
+	if len(active) > 0 {
+		return
+	}
+
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	if len(st.queue) == 0 {
+		return
+	}
+
+	scheduledTime := st.queue[0]
+	st.queue = st.queue[1:]
+
+	// The fire lock is held by the caller, so the queued time fires once it
+	// returns, and checks the active runs again.
+	go tc.fire(st, scheduledTime)
+}
+
+// RunFinished runs the next queued time of each trigger of the flow, now the
+// run has finished. The run may have been started by any trigger.
+func (tc *Trigger) RunFinished(run *state.Run) {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+
+	for _, st := range tc.triggers {
+
+		if len(st.queue) == 0 || st.trigger.Namespace != run.Namespace || st.trigger.Flow != run.FlowID {
+			continue
 		}
-	}(st.trigger)
+
+		scheduledTime := st.queue[0]
+		st.queue = st.queue[1:]
+
+		go tc.fire(st, scheduledTime)
+	}
+}
+
+// activeRuns returns the pending and running runs of the flow of the trigger.
+func (tc *Trigger) activeRuns(trigger *state.Trigger) ([]*state.RunStub, error) {
+
+	listResp, errResp := tc.state.Runs().List(&serverstate.RunsListReq{Namespace: trigger.Namespace})
+	if errResp != nil {
+		return nil, errResp.Err()
+	}
+
+	var active []*state.RunStub
+
+	for _, run := range listResp.Runs {
+		if run.FlowID == trigger.Flow && !state.IsTerminalStatus(run.Status) {
+			active = append(active, run)
+		}
+	}
+
+	return active, nil
+}
+
+// recordFireTime persists the scheduled time the trigger fired at, so the times
+// missed while the controller is not running can be determined on start.
+func (tc *Trigger) recordFireTime(st *scheduledTrigger, scheduledTime time.Time) {
+
+	// Queued times fire after later times have been recorded.
+	if !scheduledTime.After(st.lastFireTime) {
+		return
+	}
+
+	// Only the fire time is written, so a concurrent update or pause of the
+	// trigger through the API is not overwritten.
+	if _, errResp := tc.state.Triggers().SetLastFireTime(&serverstate.TriggersSetLastFireTimeReq{
+		ID:           st.trigger.ID,
+		Namespace:    st.trigger.Namespace,
+		LastFireTime: scheduledTime,
+	}); errResp != nil {
+		tc.logger.Error("failed to record trigger fire time",
+			zap.String("trigger_id", st.trigger.ID),
+			zap.String("namespace", st.trigger.Namespace),
+			zap.Error(errResp.Err()),
+		)
+		return
+	}
+
+	st.lastFireTime = scheduledTime
 }
 
 // signalUpdate signals the scheduler to check for updates
@@ -265,7 +547,8 @@ func (tc *Trigger) makeKey(id, namespace string) string {
 	return fmt.Sprintf("%s/%s", namespace, id)
 }
 
-// GetNextRun returns the next scheduled run time for a trigger
+// GetNextRun returns the next scheduled run time for a trigger, or an error if
+// the trigger is paused or does not have a future run.
 func (tc *Trigger) GetNextRun(triggerID, namespace string) (time.Time, error) {
 	tc.lock.RLock()
 	defer tc.lock.RUnlock()
@@ -275,6 +558,9 @@ func (tc *Trigger) GetNextRun(triggerID, namespace string) (time.Time, error) {
 	if !exists {
 		return time.Time{}, fmt.Errorf("trigger %s not found", triggerID)
 	}
+	if st.index < 0 {
+		return time.Time{}, fmt.Errorf("trigger %s is not scheduled", triggerID)
+	}
 
 	return st.nextRun, nil
 }
@@ -314,8 +600,9 @@ func (tc *Trigger) loadTriggersFromState() error {
 			continue
 		}
 
-		// Add the trigger to the scheduler
-		if err := tc.CreateTrigger(getResp.Trigger); err != nil {
+		// Add the trigger to the scheduler, catching up on the times missed
+		// while the controller was not running.
+		if err := tc.addTrigger(getResp.Trigger, true); err != nil {
 			tc.logger.Warn("failed to add trigger to scheduler",
 				zap.String("trigger_id", getResp.Trigger.ID),
 				zap.String("namespace", getResp.Trigger.Namespace),
@@ -3,20 +3,40 @@ package trigger
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/oklog/ulid/v2"
 	"go.uber.org/zap"
 
 	serverstate "github.com/hashicorp-forge/nomad-pipeline/internal/controller/server/state"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/git"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/schedule"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/webhook"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/logger"
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
-type coordinatorFlowRunFunc func(flowID, namespace, trigger string, vars map[string]any) error
+type coordinatorFlowRunFunc func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+
+type coordinatorRunCancelFunc func(id ulid.ULID, namespace string) error
+
+// webhookProcessor is implemented by the providers which are triggered by
+// webhooks.
+type webhookProcessor interface {
+
+	// Authenticate verifies the delivery using the secret of the trigger, and
+	// is called before the delivery is recorded.
+	Authenticate(header http.Header, payload []byte, trigger *state.Trigger) error
+
+	// ProcessWebhook processes an authenticated delivery.
+	ProcessWebhook(r *http.Request, trigger *state.Trigger) *delivery.Result
+}
 
 const (
 	GitWebhookProviderName = "git-webhook"
+	WebhookProviderName    = "webhook"
 	CronProviderName       = "cron"
 )
 
@@ -27,26 +47,52 @@ type Handler struct {
 
 	scheduleTrigger *schedule.Trigger
 	gitTrigger      *git.Trigger
+	webhookTrigger  *webhook.Trigger
+
+	// deliveries records the webhook deliveries received by each trigger.
+	deliveries *delivery.Store
+
+	// triggers caches the triggers read from state when their runs are
+	// updated, so each run update does not read the trigger. Entries are
+	// removed when the trigger is created, updated, or deleted.
+	triggers     map[triggerKey]*state.Trigger
+	triggersLock sync.Mutex
 }
 
-func NewHandler(log *zap.Logger, state serverstate.State, runFunc coordinatorFlowRunFunc) *Handler {
+type triggerKey struct {
+	namespace string
+	id        string
+}
+
+func NewHandler(log *zap.Logger, stateStore serverstate.State, runFunc coordinatorFlowRunFunc, cancelFunc coordinatorRunCancelFunc, publicURL string) *Handler {
 
 	h := Handler{
-		logger:  log.Named(logger.ComponentNameTrigger),
-		state:   state,
-		runFunc: runFunc,
+		logger:     log.Named(logger.ComponentNameTrigger),
+		state:      stateStore,
+		runFunc:    runFunc,
+		deliveries: delivery.NewStore(),
+		triggers:   make(map[triggerKey]*state.Trigger),
 	}
 
 	h.scheduleTrigger = schedule.NewTrigger(
 		&schedule.TriggerConfig{
-			Logger: h.logger,
-			State:  h.state,
-			RunFn:  h.runFunc,
+			Logger:   h.logger,
+			State:    h.state,
+			RunFn:    h.runFunc,
+			CancelFn: cancelFunc,
 		},
 	)
 
 	h.gitTrigger = git.NewTrigger(
 		&git.TriggerConfig{
+			Logger:    h.logger,
+			RunFn:     h.runFunc,
+			PublicURL: publicURL,
+		},
+	)
+
+	h.webhookTrigger = webhook.NewTrigger(
+		&webhook.TriggerConfig{
 			Logger: h.logger,
 			RunFn:  h.runFunc,
 		},
@@ -56,9 +102,14 @@ func NewHandler(log *zap.Logger, state serverstate.State, runFunc coordinatorFlo
 }
 
 func (h *Handler) CreateTrigger(trigger *state.Trigger) error {
+
+	h.uncacheTrigger(trigger)
+
 	switch trigger.Source.Provider {
 	case GitWebhookProviderName:
-		return nil
+		return h.gitTrigger.CreateTrigger(trigger)
+	case WebhookProviderName:
+		return h.webhookTrigger.CreateTrigger(trigger)
 	case CronProviderName:
 		return h.scheduleTrigger.CreateTrigger(trigger)
 	default:
@@ -67,8 +118,16 @@ func (h *Handler) CreateTrigger(trigger *state.Trigger) error {
 }
 
 func (h *Handler) DeleteTrigger(trigger *state.Trigger) error {
+
+	h.uncacheTrigger(trigger)
+
 	switch trigger.Source.Provider {
 	case GitWebhookProviderName:
+		h.deliveries.Delete(trigger.Namespace, trigger.ID)
+		h.gitTrigger.DeleteTrigger(trigger)
+		return nil
+	case WebhookProviderName:
+		h.deliveries.Delete(trigger.Namespace, trigger.ID)
 		return nil
 	case CronProviderName:
 		return h.scheduleTrigger.DeleteTrigger(trigger)
@@ -77,24 +136,241 @@ func (h *Handler) DeleteTrigger(trigger *state.Trigger) error {
 	}
 }
 
+// UpdateTrigger replaces the existing trigger with the updated trigger. When
+// the provider has changed, the updated trigger is created before the existing
+// trigger is deleted, so the existing trigger is kept if the update is invalid.
+func (h *Handler) UpdateTrigger(existing, trigger *state.Trigger) error {
+
+	h.uncacheTrigger(trigger)
+
+	if existing.Source.Provider != trigger.Source.Provider {
+		if err := h.CreateTrigger(trigger); err != nil {
+			return err
+		}
+		return h.DeleteTrigger(existing)
+	}
+
+	switch trigger.Source.Provider {
+	case GitWebhookProviderName:
+		return h.gitTrigger.CreateTrigger(trigger)
+	case WebhookProviderName:
+		return h.webhookTrigger.CreateTrigger(trigger)
+	case CronProviderName:
+		return h.scheduleTrigger.UpdateTrigger(trigger)
+	default:
+		return fmt.Errorf("unsupported trigger provider: %s", trigger.Source.Provider)
+	}
+}
+
+// NextRun returns the next time the trigger is scheduled to run the flow. It
+// returns false for triggers which are not scheduled, such as those triggered
+// by webhooks, and paused triggers.
+func (h *Handler) NextRun(trigger *state.Trigger) (time.Time, bool) {
+
+	if trigger.Source.Provider != CronProviderName || trigger.Paused {
+		return time.Time{}, false
+	}
+
+	nextRun, err := h.scheduleTrigger.GetNextRun(trigger.ID, trigger.Namespace)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return nextRun, true
+}
+
 func (h *Handler) Start() error {
+	h.gitTrigger.Start()
 	return h.scheduleTrigger.Start()
 }
 
 func (h *Handler) Stop() error {
 	h.scheduleTrigger.Stop()
+	h.gitTrigger.Stop()
 	return nil
 }
 
+// RunUpdated is called whenever the state of a run is updated, so the trigger
+// which started the run can report its status, and cron triggers waiting for
+// the run to finish can fire. Runs which were not started by a trigger, or
+// whose trigger has since been deleted, are otherwise ignored.
+func (h *Handler) RunUpdated(run *state.Run) {
+
+	// Cron triggers may be waiting for any run of their flow to finish,
+	// regardless of which trigger started it.
+	if state.IsTerminalStatus(run.Status) {
+		h.scheduleTrigger.RunFinished(run)
+	}
+
+	if run.Trigger == "" {
+		return
+	}
+
+	trigger, ok := h.runTrigger(run)
+	if !ok {
+		return
+	}
+
+	switch trigger.Source.Provider {
+	case GitWebhookProviderName:
+		h.gitTrigger.RunUpdated(trigger, run)
+	}
+}
+
+// runTrigger returns the trigger which started the run, reading it from state
+// if it is not cached. It returns false if the trigger cannot be read.
+func (h *Handler) runTrigger(run *state.Run) (*state.Trigger, bool) {
+
+	key := triggerKey{namespace: run.Namespace, id: run.Trigger}
+
+	h.triggersLock.Lock()
+	trigger, ok := h.triggers[key]
+	h.triggersLock.Unlock()
+
+	if ok {
+		return trigger, true
+	}
+
+	stateResp, err := h.state.Triggers().Get(&serverstate.TriggersGetReq{
+		ID:        run.Trigger,
+		Namespace: run.Namespace,
+	})
+	if err != nil {
+		if err.StatusCode() != http.StatusNotFound {
+			h.logger.Error("failed to get trigger of updated run",
+				zap.String("run_id", run.ID.String()),
+				zap.String("trigger_id", run.Trigger),
+				zap.Error(err))
+		}
+		return nil, false
+	}
+
+	h.triggersLock.Lock()
+	h.triggers[key] = stateResp.Trigger
+	h.triggersLock.Unlock()
+
+	return stateResp.Trigger, true
+}
+
+// uncacheTrigger removes the trigger from the cache, so it is read from state
+// when it is next needed.
+func (h *Handler) uncacheTrigger(trigger *state.Trigger) {
+	h.triggersLock.Lock()
+	delete(h.triggers, triggerKey{namespace: trigger.Namespace, id: trigger.ID})
+	h.triggersLock.Unlock()
+}
+
+func (h *Handler) webhookProcessor(provider string) (webhookProcessor, bool) {
+	switch provider {
+	case GitWebhookProviderName:
+		return h.gitTrigger, true
+	case WebhookProviderName:
+		return h.webhookTrigger, true
+	default:
+		return nil, false
+	}
+}
+
+// HandleTriggerWebhook processes the webhook delivery to the trigger, and
+// records the result. Deliveries which fail authentication are not recorded.
+// Deliveries whose ID has already been recorded for the trigger are ignored,
+// so retried deliveries do not run the flow twice.
 func (h *Handler) HandleTriggerWebhook(
 	w http.ResponseWriter,
 	r *http.Request,
 	trigger *state.Trigger,
 ) {
-	switch trigger.Source.Provider {
-	case GitWebhookProviderName:
-		h.gitTrigger.HandleWebhook(w, r, trigger)
-	default:
+
+	processor, ok := h.webhookProcessor(trigger.Source.Provider)
+	if !ok {
 		http.Error(w, "unsupported trigger provider", http.StatusBadRequest)
+		return
 	}
+
+	payload, err := delivery.ReadPayload(r)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := processor.Authenticate(r.Header, payload, trigger); err != nil {
+		h.logger.Debug("failed to authenticate webhook",
+			zap.String("trigger_id", trigger.ID),
+			zap.Error(err))
+		http.Error(w, "failed to authenticate webhook", http.StatusUnauthorized)
+		return
+	}
+
+	d := delivery.New(trigger, r.Header, payload)
+	req := d.Request()
+
+	if !h.deliveries.Add(d) {
+		h.logger.Debug("duplicate webhook delivery, ignoring",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("delivery_id", d.ID()))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("duplicate delivery, ignored"))
+		return
+	}
+
+	result := h.processDelivery(processor, req, d.ID(), trigger)
+	h.deliveries.Complete(d, result)
+
+	if result.StatusCode == http.StatusOK {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(result.Message))
+	} else {
+		http.Error(w, result.Message, result.StatusCode)
+	}
+}
+
+// ListDeliveries returns the recorded webhook deliveries of the trigger,
+// ordered from newest to oldest.
+func (h *Handler) ListDeliveries(trigger *state.Trigger) []*state.TriggerDelivery {
+	return h.deliveries.List(trigger.Namespace, trigger.ID)
+}
+
+// Redeliver processes the recorded delivery again, as a new delivery which is
+// not subject to de-duplication. It returns delivery.ErrNotFound if the
+// delivery is not found, and delivery.ErrPayloadNotRetained if its payload
+// has been dropped.
+func (h *Handler) Redeliver(trigger *state.Trigger, deliveryID string) (*state.TriggerDelivery, error) {
+
+	original, ok := h.deliveries.Get(trigger.Namespace, trigger.ID, deliveryID)
+	if !ok {
+		return nil, delivery.ErrNotFound
+	}
+
+	processor, ok := h.webhookProcessor(trigger.Source.Provider)
+	if !ok {
+		return nil, delivery.ErrNotFound
+	}
+
+	d, err := h.deliveries.Redelivery(original)
+	if err != nil {
+		return nil, err
+	}
+
+	req := d.Request()
+	h.deliveries.Add(d)
+
+	return h.deliveries.Complete(d, h.processDelivery(processor, req, d.ID(), trigger)), nil
+}
+
+// processDelivery processes the webhook delivery, unless the trigger is paused.
+// Deliveries to paused triggers are recorded as ignored, and are accepted so
+// the sender does not retry them, but can be redelivered once resumed.
+func (h *Handler) processDelivery(processor webhookProcessor, r *http.Request, deliveryID string, trigger *state.Trigger) *delivery.Result {
+
+	if trigger.Paused {
+		h.logger.Debug("trigger is paused, ignoring webhook",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("delivery_id", deliveryID))
+		return &delivery.Result{
+			StatusCode: http.StatusOK,
+			Message:    "trigger is paused, ignored",
+			Decision:   state.TriggerDeliveryDecisionIgnored,
+		}
+	}
+
+	return processor.ProcessWebhook(r, trigger)
 }
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// exprVarPayload is the name of the decoded JSON payload within the
+	// condition and variable expressions.
+	exprVarPayload = "payload"
+
+	// exprVarHeaders is the name of the request headers within the condition
+	// and variable expressions.
+	exprVarHeaders = "headers"
+)
+
+type triggerConfig struct {
+	// Secret is used to authenticate the webhook. When SignatureHeader is set,
+	// the header must contain the HMAC-SHA256 signature of the payload,
+	// otherwise the request must send the secret as a bearer token.
+	Secret          string `hcl:"secret,optional" json:"secret,omitempty"`
+	SignatureHeader string `hcl:"signature_header,optional" json:"signature_header,omitempty"`
+
+	// Condition is a boolean expression over the payload, which must be true
+	// for the webhook to run the flow.
+	Condition hcl.Expression `hcl:"condition,optional" json:"-"`
+
+	Variables *variablesConfig `hcl:"variables,block" json:"-"`
+
+	// variables are the attributes of the variables block, which map flow
+	// variable names to expressions over the payload.
+	variables hcl.Attributes
+}
+
+type variablesConfig struct {
+	Remain hcl.Body `hcl:",remain"`
+}
+
+func decodeTriggerConfig(trigger *state.Trigger) (*triggerConfig, error) {
+
+	if len(trigger.Source.Config) == 0 {
+		return &triggerConfig{}, nil
+	}
+
+	file, diags := hclsyntax.ParseConfig(trigger.Source.Config, "config.hcl", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse HCL config: %w", diags)
+	}
+
+	var cfg triggerConfig
+	diags = gohcl.DecodeBody(file.Body, nil, &cfg)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to decode HCL config: %w", diags)
+	}
+
+	if cfg.SignatureHeader != "" && cfg.Secret == "" {
+		return nil, fmt.Errorf("signature_header requires a secret")
+	}
+
+	if err := validateExpression("condition", cfg.Condition); err != nil {
+		return nil, err
+	}
+
+	if cfg.Variables != nil {
+		attrs, diags := cfg.Variables.Remain.JustAttributes()
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to decode variables: %w", diags)
+		}
+		for name, attr := range attrs {
+			if err := validateExpression("variable "+name, attr.Expr); err != nil {
+				return nil, err
+			}
+		}
+		cfg.variables = attrs
+	}
+
+	return &cfg, nil
+}
+
+// validateExpression ensures the expression only references the payload and
+// headers, so a typo is reported when the trigger is created.
+func validateExpression(name string, expr hcl.Expression) error {
+
+	if expr == nil {
+		return nil
+	}
+
+	for _, traversal := range expr.Variables() {
+		if root := traversal.RootName(); !slices.Contains([]string{exprVarPayload, exprVarHeaders}, root) {
+			return fmt.Errorf("%s references unknown variable %q", name, root)
+		}
+	}
+
+	return nil
+}
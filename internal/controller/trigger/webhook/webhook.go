@@ -0,0 +1,283 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/oklog/ulid/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/controller/trigger/delivery"
+	hhcl "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/hcl"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/logger"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+// webhookMaxPayloadSize is the maximum size of webhook payload read. Generic
+// webhooks are sent by tools such as registries and monitoring systems, whose
+// payloads are small.
+const webhookMaxPayloadSize = 1024 * 1024
+
+type Trigger struct {
+	logger  *zap.Logger
+	runFlow func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+}
+
+type TriggerConfig struct {
+	Logger *zap.Logger
+	RunFn  func(flowID, namespace, trigger string, triggerTime time.Time, vars map[string]any) (ulid.ULID, error)
+}
+
+func NewTrigger(cfg *TriggerConfig) *Trigger {
+	return &Trigger{
+		logger:  cfg.Logger.Named(logger.ComponentNameTriggerWebhook),
+		runFlow: cfg.RunFn,
+	}
+}
+
+// CreateTrigger validates the config of the trigger, so an invalid config is
+// rejected when the trigger is created rather than when a webhook arrives.
+func (h *Trigger) CreateTrigger(trigger *state.Trigger) error {
+	_, err := decodeTriggerConfig(trigger)
+	return err
+}
+
+// Authenticate verifies the webhook delivery using the secret of the trigger.
+func (h *Trigger) Authenticate(header http.Header, payload []byte, trigger *state.Trigger) error {
+
+	cfg, err := decodeTriggerConfig(trigger)
+	if err != nil {
+		return err
+	}
+
+	return authenticate(header, cfg, payload)
+}
+
+// ProcessWebhook processes the authenticated webhook delivery, and runs the
+// flow with the mapped variables if it meets the condition of the trigger.
+func (h *Trigger) ProcessWebhook(r *http.Request, trigger *state.Trigger) *delivery.Result {
+
+	cfg, err := decodeTriggerConfig(trigger)
+	if err != nil {
+		h.logger.Error("failed to decode trigger config",
+			zap.String("trigger_id", trigger.ID),
+			zap.Error(err))
+		return &delivery.Result{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to decode trigger config",
+			Decision:   state.TriggerDeliveryDecisionFailed,
+			Err:        err,
+		}
+	}
+
+	rejected := func(code int, message string, err error) *delivery.Result {
+		h.logger.Debug("rejected webhook",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("reason", message),
+			zap.Error(err))
+		return &delivery.Result{
+			StatusCode: code,
+			Message:    message,
+			Decision:   state.TriggerDeliveryDecisionRejected,
+			Err:        err,
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxPayloadSize+1))
+	if err != nil {
+		return rejected(http.StatusBadRequest, "failed to read request body", err)
+	}
+	if len(body) > webhookMaxPayloadSize {
+		return rejected(http.StatusRequestEntityTooLarge, "request body is too large", nil)
+	}
+
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return rejected(http.StatusBadRequest, "failed to decode JSON payload", err)
+	}
+
+	evalCtx, err := newEvalContext(payload, r.Header, cfg.SignatureHeader)
+	if err != nil {
+		return rejected(http.StatusBadRequest, "failed to decode JSON payload", err)
+	}
+
+	if ok, err := evalCondition(cfg.Condition, evalCtx); err != nil {
+		return rejected(http.StatusBadRequest, fmt.Sprintf("failed to evaluate condition: %s", err), err)
+	} else if !ok {
+		h.logger.Debug("webhook condition not met, ignoring",
+			zap.String("trigger_id", trigger.ID))
+		return &delivery.Result{
+			StatusCode: http.StatusOK,
+			Message:    "condition not met, ignored",
+			Decision:   state.TriggerDeliveryDecisionIgnored,
+		}
+	}
+
+	vars, err := evalVariables(cfg.variables, evalCtx)
+	if err != nil {
+		return rejected(http.StatusBadRequest, fmt.Sprintf("failed to evaluate variables: %s", err), err)
+	}
+
+	runID, err := h.runFlow(trigger.Flow, trigger.Namespace, trigger.ID, time.Now(), vars)
+	if err != nil {
+		h.logger.Error("failed to execute flow from webhook",
+			zap.String("trigger_id", trigger.ID),
+			zap.String("flow_id", trigger.Flow),
+			zap.Error(err))
+		return &delivery.Result{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to run flow",
+			Decision:   state.TriggerDeliveryDecisionFailed,
+			Err:        err,
+		}
+	}
+
+	return &delivery.Result{
+		StatusCode: http.StatusOK,
+		Message:    "webhook processed successfully",
+		Decision:   state.TriggerDeliveryDecisionTriggered,
+		RunID:      runID.String(),
+	}
+}
+
+// authenticate verifies the delivery using the secret of the trigger, either as
+// the key of the HMAC-SHA256 payload signature, or as a bearer token. Requests
+// to triggers without a secret are not authenticated.
+func authenticate(header http.Header, cfg *triggerConfig, body []byte) error {
+
+	if cfg.Secret == "" {
+		return nil
+	}
+
+	if cfg.SignatureHeader != "" {
+
+		// Many tools prefix the signature with the name of its algorithm, in
+		// the same way as GitHub.
+		signature := strings.TrimPrefix(header.Get(cfg.SignatureHeader), "sha256=")
+
+		sig, err := hex.DecodeString(signature)
+		if err != nil {
+			return fmt.Errorf("invalid signature encoding: %w", err)
+		}
+
+		mac := hmac.New(sha256.New, []byte(cfg.Secret))
+		mac.Write(body)
+
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+
+	token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Secret)) != 1 {
+		return errors.New("invalid bearer token")
+	}
+
+	return nil
+}
+
+// newEvalContext returns the context used to evaluate the condition and
+// variable expressions. Header names are lower case, and headers sent multiple
+// times have their values joined with a comma. The headers used to
+// authenticate the webhook are removed, so they cannot be copied into the
+// variables of the run.
+func newEvalContext(payload any, header http.Header, signatureHeader string) (*hcl.EvalContext, error) {
+
+	payloadVal, err := hhcl.GoToCty(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]cty.Value, len(header))
+	for name, values := range header {
+		if strings.EqualFold(name, "Authorization") ||
+			(signatureHeader != "" && strings.EqualFold(name, signatureHeader)) {
+			continue
+		}
+		headers[strings.ToLower(name)] = cty.StringVal(strings.Join(values, ","))
+	}
+
+	headersVal := cty.MapValEmpty(cty.String)
+	if len(headers) > 0 {
+		headersVal = cty.MapVal(headers)
+	}
+
+	return &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			exprVarPayload: payloadVal,
+			exprVarHeaders: headersVal,
+		},
+		Functions: map[string]function.Function{
+			"try": tryfunc.TryFunc,
+			"can": tryfunc.CanFunc,
+		},
+	}, nil
+}
+
+// evalCondition evaluates the condition of the trigger. A trigger without a
+// condition runs the flow for every webhook.
+func evalCondition(expr hcl.Expression, evalCtx *hcl.EvalContext) (bool, error) {
+
+	if expr == nil {
+		return true, nil
+	}
+
+	val, diags := expr.Value(evalCtx)
+	if diags.HasErrors() {
+		return false, diags
+	}
+
+	// The condition decodes as a null value when it is not configured.
+	if val.IsNull() {
+		return true, nil
+	}
+
+	if val.Type() != cty.Bool {
+		return false, fmt.Errorf("condition must evaluate to boolean, got %s",
+			val.Type().FriendlyName())
+	}
+
+	return val.True(), nil
+}
+
+// evalVariables evaluates the variable expressions of the trigger, returning
+// the flow variables to run the flow with.
+func evalVariables(attrs hcl.Attributes, evalCtx *hcl.EvalContext) (map[string]any, error) {
+
+	vars := make(map[string]any, len(attrs))
+
+	for name, attr := range attrs {
+
+		val, diags := attr.Expr.Value(evalCtx)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+
+		goVal, err := hhcl.CtyToGo(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert variable %q: %w", name, err)
+		}
+
+		// Null values are not set, so the default of the flow variable is
+		// used.
+		if goVal != nil {
+			vars[name] = goVal
+		}
+	}
+
+	return vars, nil
+}
@@ -20,12 +20,17 @@ type Context struct {
 }
 
 type NomadPipelineContext struct {
-	FlowID     string
-	FlowType   string
-	Namespace  string
-	RunID      ulid.ULID
-	Status     string
-	Trigger    string
+	FlowID    string
+	FlowType  string
+	Namespace string
+	RunID     ulid.ULID
+	Status    string
+	Trigger   string
+
+	// TriggerTime is the time the trigger fired, which for cron triggers is
+	// the scheduled time rather than the time the run was created.
+	TriggerTime time.Time
+
 	CreateTime time.Time
 	StartTime  time.Time
 	EndTime    time.Time
@@ -56,19 +61,25 @@ type StepContext struct {
 	ExitCode  int
 	StartTime time.Time
 	EndTime   time.Time
+
+	Tests       *state.TestReport
+	Annotations []*state.Annotation
+	Outputs     map[string]string
+	Summary     string
 }
 
-func New(runID ulid.ULID, trigger string, flow *state.Flow, vars map[string]any) *Context {
+func New(runID ulid.ULID, trigger string, triggerTime time.Time, flow *state.Flow, vars map[string]any) *Context {
 
 	ctx := &Context{
 		NomadPipeline: &NomadPipelineContext{
-			FlowID:     flow.ID,
-			FlowType:   flow.Type(),
-			Namespace:  flow.Namespace,
-			RunID:      runID,
-			Status:     state.RunStatusPending,
-			Trigger:    trigger,
-			CreateTime: time.Now(),
+			FlowID:      flow.ID,
+			FlowType:    flow.Type(),
+			Namespace:   flow.Namespace,
+			RunID:       runID,
+			Status:      state.RunStatusPending,
+			Trigger:     trigger,
+			TriggerTime: triggerTime,
+			CreateTime:  time.Now(),
 		},
 		Variables: vars,
 	}
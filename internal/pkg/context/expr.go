@@ -9,6 +9,7 @@ import (
 	"github.com/zclconf/go-cty/cty/function"
 
 	hhcl "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/hcl"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
 func (c *Context) ParseBoolExpr(expr string) (bool, error) {
@@ -35,6 +36,16 @@ func (c *Context) ParseBoolExpr(expr string) (bool, error) {
 	return val.True(), nil
 }
 
+// EvalCondition evaluates the condition of a step or specification. An empty
+// condition defaults to success(), so that it only runs while the run is
+// successful.
+func (c *Context) EvalCondition(condition string) (bool, error) {
+	if condition == "" {
+		condition = state.DefaultCondition
+	}
+	return c.ParseBoolExpr(condition)
+}
+
 func (c *Context) ParseTemplateStringExpr(expr string) (string, error) {
 
 	evalCtx, err := c.createEvalContext()
@@ -77,22 +88,61 @@ func (c *Context) createEvalContext() (*hcl.EvalContext, error) {
 
 	return &hcl.EvalContext{
 		Variables: variables,
-		Functions: hclFuncs(),
+		Functions: c.hclFuncs(),
 	}, nil
 }
 
-func hclFuncs() map[string]function.Function {
+func (c *Context) hclFuncs() map[string]function.Function {
 	return map[string]function.Function{
-		"always": alwaysHCLFunc(),
+		"always":    alwaysHCLFunc(),
+		"success":   boolHCLFunc(c.isSuccess),
+		"failure":   boolHCLFunc(c.isFailure),
+		"cancelled": boolHCLFunc(c.isCancelled),
 	}
 }
 
 // always is the HCL function that always returns true and can be called via
 // always().
 func alwaysHCLFunc() function.Function {
+	return boolHCLFunc(func() bool { return true })
+}
+
+// boolHCLFunc returns a HCL function without parameters, which returns the
+// result of the passed function.
+func boolHCLFunc(fn func() bool) function.Function {
 	return function.New(&function.Spec{
 		Params: nil,
 		Type:   func(args []cty.Value) (cty.Type, error) { return cty.Bool, nil },
-		Impl:   func(args []cty.Value, retType cty.Type) (cty.Value, error) { return cty.BoolVal(true), nil },
+		Impl:   func(args []cty.Value, retType cty.Type) (cty.Value, error) { return cty.BoolVal(fn()), nil },
 	})
 }
+
+// isSuccess returns whether the run has not been cancelled and none of the
+// steps or specifications have failed. It backs the success() HCL function.
+func (c *Context) isSuccess() bool {
+	return !c.isCancelled() && !c.isFailure()
+}
+
+// isFailure returns whether any of the steps or specifications have failed. It
+// backs the failure() HCL function.
+func (c *Context) isFailure() bool {
+	for _, specCtx := range c.Specifications {
+		if specCtx.Status == state.RunStatusFailed {
+			return true
+		}
+	}
+	if c.Inline != nil {
+		for _, stepCtx := range c.Inline.Steps {
+			if stepCtx.Status == state.RunStatusFailed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isCancelled returns whether the run has been cancelled. It backs the
+// cancelled() HCL function.
+func (c *Context) isCancelled() bool {
+	return c.NomadPipeline.Status == state.RunStatusCancelled
+}
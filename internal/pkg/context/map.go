@@ -30,15 +30,16 @@ func (c *Context) nomadPipelineAsMap() map[string]any {
 	}
 
 	return map[string]any{
-		"flow_id":     c.NomadPipeline.FlowID,
-		"flow_type":   c.NomadPipeline.FlowType,
-		"namespace":   c.NomadPipeline.Namespace,
-		"run_id":      c.NomadPipeline.RunID,
-		"status":      c.NomadPipeline.Status,
-		"trigger":     c.NomadPipeline.Trigger,
-		"create_time": formatTime(c.NomadPipeline.CreateTime),
-		"start_time":  formatTime(c.NomadPipeline.StartTime),
-		"end_time":    formatTime(c.NomadPipeline.EndTime),
+		"flow_id":      c.NomadPipeline.FlowID,
+		"flow_type":    c.NomadPipeline.FlowType,
+		"namespace":    c.NomadPipeline.Namespace,
+		"run_id":       c.NomadPipeline.RunID,
+		"status":       c.NomadPipeline.Status,
+		"trigger":      c.NomadPipeline.Trigger,
+		"trigger_time": formatTime(c.NomadPipeline.TriggerTime),
+		"create_time":  formatTime(c.NomadPipeline.CreateTime),
+		"start_time":   formatTime(c.NomadPipeline.StartTime),
+		"end_time":     formatTime(c.NomadPipeline.EndTime),
 	}
 }
 
@@ -99,12 +100,18 @@ func (c *Context) stepsAsMap() map[string]any {
 }
 
 func (s *StepContext) asMap() map[string]any {
+	outputs := make(map[string]any, len(s.Outputs))
+	for k, v := range s.Outputs {
+		outputs[k] = v
+	}
+
 	return map[string]any{
 		"id":         s.ID,
 		"status":     s.Status,
 		"exit_code":  s.ExitCode,
 		"start_time": formatTime(s.StartTime),
 		"end_time":   formatTime(s.EndTime),
+		"outputs":    outputs,
 	}
 }
 
@@ -94,6 +94,16 @@ func (c *Context) EndInlineStep(stepID, status string, exitCode int) {
 	}
 }
 
+// SetInlineStepResults sets the results the step produced alongside its exit
+// code, such as test reports, annotations, outputs, and its summary.
+func (c *Context) SetInlineStepResults(stepID string, res *state.InlineStep) {
+	stepCtx := c.Inline.Steps[c.Inline.stepTracker[stepID]]
+	stepCtx.Tests = res.Tests
+	stepCtx.Annotations = res.Annotations
+	stepCtx.Outputs = res.Outputs
+	stepCtx.Summary = res.Summary
+}
+
 func (c *Context) GetContext() *Context { return c }
 
 func (c *Context) Run() *state.Run {
@@ -104,15 +114,16 @@ func (c *Context) Run() *state.Run {
 	np := c.NomadPipeline
 
 	run := &state.Run{
-		ID:         np.RunID,
-		FlowID:     np.FlowID,
-		Namespace:  np.Namespace,
-		Status:     np.Status,
-		Trigger:    np.Trigger,
-		CreateTime: np.CreateTime,
-		StartTime:  np.StartTime,
-		EndTime:    np.EndTime,
-		Variables:  map[string]any{},
+		ID:          np.RunID,
+		FlowID:      np.FlowID,
+		Namespace:   np.Namespace,
+		Status:      np.Status,
+		Trigger:     np.Trigger,
+		TriggerTime: np.TriggerTime,
+		CreateTime:  np.CreateTime,
+		StartTime:   np.StartTime,
+		EndTime:     np.EndTime,
+		Variables:   map[string]any{},
 	}
 
 	variables := c.Variables["var"]
@@ -154,11 +165,15 @@ func (c *Context) Run() *state.Run {
 
 		for _, stepCtx := range c.Inline.Steps {
 			step := &state.InlineStep{
-				ID:        stepCtx.ID,
-				Status:    stepCtx.Status,
-				ExitCode:  stepCtx.ExitCode,
-				StartTime: stepCtx.StartTime,
-				EndTime:   stepCtx.EndTime,
+				ID:          stepCtx.ID,
+				Status:      stepCtx.Status,
+				ExitCode:    stepCtx.ExitCode,
+				StartTime:   stepCtx.StartTime,
+				EndTime:     stepCtx.EndTime,
+				Tests:       stepCtx.Tests,
+				Annotations: stepCtx.Annotations,
+				Outputs:     stepCtx.Outputs,
+				Summary:     stepCtx.Summary,
 			}
 			run.InlineRun.Steps = append(run.InlineRun.Steps, step)
 		}
@@ -166,3 +181,47 @@ func (c *Context) Run() *state.Run {
 
 	return run
 }
+
+// InlineStep returns the state representation of the inline step context. It
+// returns nil if the step is not found.
+func (c *Context) InlineStep(stepID string) *state.InlineStep {
+
+	idx, ok := c.Inline.stepTracker[stepID]
+	if !ok {
+		return nil
+	}
+
+	stepCtx := c.Inline.Steps[idx]
+
+	return &state.InlineStep{
+		ID:          stepCtx.ID,
+		Status:      stepCtx.Status,
+		ExitCode:    stepCtx.ExitCode,
+		StartTime:   stepCtx.StartTime,
+		EndTime:     stepCtx.EndTime,
+		Tests:       stepCtx.Tests,
+		Annotations: stepCtx.Annotations,
+		Outputs:     stepCtx.Outputs,
+		Summary:     stepCtx.Summary,
+	}
+}
+
+// SetInlineStep overwrites the inline step context with the passed step
+// state. This is used to synchronise the result of steps which have been
+// executed by another runner task.
+func (c *Context) SetInlineStep(step *state.InlineStep) {
+
+	idx, ok := c.Inline.stepTracker[step.ID]
+	if !ok {
+		return
+	}
+
+	c.Inline.Steps[idx].Status = step.Status
+	c.Inline.Steps[idx].ExitCode = step.ExitCode
+	c.Inline.Steps[idx].StartTime = step.StartTime
+	c.Inline.Steps[idx].EndTime = step.EndTime
+	c.Inline.Steps[idx].Tests = step.Tests
+	c.Inline.Steps[idx].Annotations = step.Annotations
+	c.Inline.Steps[idx].Outputs = step.Outputs
+	c.Inline.Steps[idx].Summary = step.Summary
+}
@@ -2,6 +2,7 @@ package hcl
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/zclconf/go-cty/cty"
@@ -74,3 +75,57 @@ func GoToCty(v any) (cty.Value, error) {
 		return cty.NilVal, fmt.Errorf("unsupported type: %T", v)
 	}
 }
+
+// CtyToGo converts the value into its Go equivalent, which is the reverse of
+// GoToCty. Whole numbers are converted to an int, and all other numbers to a
+// float64.
+func CtyToGo(val cty.Value) (any, error) {
+
+	if val.IsNull() {
+		return nil, nil
+	}
+
+	if !val.IsWhollyKnown() {
+		return nil, fmt.Errorf("value is unknown")
+	}
+
+	ty := val.Type()
+
+	switch {
+	case ty == cty.String:
+		return val.AsString(), nil
+	case ty == cty.Bool:
+		return val.True(), nil
+	case ty == cty.Number:
+		bf := val.AsBigFloat()
+		if i, acc := bf.Int64(); acc == big.Exact {
+			return int(i), nil
+		}
+		f, _ := bf.Float64()
+		return f, nil
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		vals := make([]any, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			v, err := CtyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+		}
+		return vals, nil
+	case ty.IsMapType() || ty.IsObjectType():
+		vals := make(map[string]any)
+		for it := val.ElementIterator(); it.Next(); {
+			key, elem := it.Element()
+			v, err := CtyToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			vals[key.AsString()] = v
+		}
+		return vals, nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", ty.FriendlyName())
+	}
+}
@@ -1,6 +1,8 @@
 package host
 
 import (
+	"time"
+
 	"github.com/oklog/ulid/v2"
 
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
@@ -11,7 +13,38 @@ type RunConfig struct {
 	Namespace     string         `json:"namespace"`
 	JobID         string         `json:"job_id"`
 	Flow          *state.Flow    `json:"flow"`
+	Trigger       string         `json:"trigger"`
+	TriggerTime   time.Time      `json:"trigger_time"`
 	Variables     map[string]any `json:"variables"`
 	JobSteps      []*state.Step  `json:"job_steps"`
 	ControllerRPC string         `json:"controller_rpc"`
+
+	// WorkDir is the directory, relative to the runner working directory,
+	// where step scripts are written and executed. When the flow uses more than
+	// one step image, this is located within the shared allocation directory,
+	// so that all tasks see the same workspace.
+	WorkDir string `json:"work_dir"`
+
+	// Task is the name of the Nomad task the runner is executing within. The
+	// leader task is responsible for the run level status updates, while all
+	// tasks execute the steps assigned to them via StepTasks.
+	Task      string            `json:"task"`
+	Leader    bool              `json:"leader"`
+	StepTasks map[string]string `json:"step_tasks"`
+}
+
+// OwnsStep returns whether the runner task is responsible for executing the
+// step. When the run is not split across multiple tasks, the runner owns all
+// steps.
+func (r *RunConfig) OwnsStep(stepID string) bool {
+	if len(r.StepTasks) == 0 {
+		return true
+	}
+	return r.StepTasks[stepID] == r.Task
+}
+
+// IsLeader returns whether the runner task is responsible for run level
+// status updates.
+func (r *RunConfig) IsLeader() bool {
+	return len(r.StepTasks) == 0 || r.Leader
 }
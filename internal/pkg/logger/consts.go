@@ -8,5 +8,7 @@ const (
 	ComponentNameTrigger           = "trigger"
 	ComponentNameTriggerSchedule   = "cron"
 	ComponentNameTriggerGitWebhook = "git_webhook"
+	ComponentNameTriggerWebhook    = "webhook"
 	ComponentNameState             = "state"
+	ComponentNameLogStore          = "log_store"
 )
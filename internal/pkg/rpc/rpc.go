@@ -7,8 +7,9 @@ import (
 )
 
 const (
-	RunnerJobUpdateMethodName = "Runner.JobUpdate"
-	RunnerLogsBatchMethodName = "Runner.JobLogsBatch"
+	RunnerJobUpdateMethodName  = "Runner.JobUpdate"
+	RunnerLogsBatchMethodName  = "Runner.JobLogsBatch"
+	RunnerWaitCancelMethodName = "Runner.JobWaitCancel"
 )
 
 type RunnerJobUpdateReq struct {
@@ -34,11 +35,16 @@ type RunnerJobUpdateResp struct{}
 type RunnerLogStreamResp struct{}
 
 type RunnerLogsBatchReq struct {
-	Namespace string   `json:"namespace"`
-	RunID     string   `json:"run_id"`
-	StepID    string   `json:"step_id"`
-	Type      string   `json:"type"`
-	Logs      []string `json:"logs"`
+	Namespace string           `json:"namespace"`
+	RunID     string           `json:"run_id"`
+	StepID    string           `json:"step_id"`
+	Type      string           `json:"type"`
+	Logs      []*state.LogLine `json:"logs"`
+
+	// Sequence is the number of the batch within the step log stream, starting
+	// at one. It allows the controller to de-duplicate batches which have been
+	// retried by the runner.
+	Sequence uint64 `json:"sequence"`
 }
 
 type RunnerLogsBatchResp struct{}
@@ -56,7 +62,7 @@ func (r *RunnerLogsBatchReq) Validate() error {
 	if r.Type == "" {
 		return errors.New("empty log type")
 	}
-	if r.Type != "stdout" && r.Type != "stderr" {
+	if r.Type != state.LogStreamStdout && r.Type != state.LogStreamStderr {
 		return errors.New("log type must be 'stdout' or 'stderr'")
 	}
 	if len(r.Logs) == 0 {
@@ -64,3 +70,25 @@ func (r *RunnerLogsBatchReq) Validate() error {
 	}
 	return nil
 }
+
+// RunnerWaitCancelReq is used by runners to long-poll the controller for the
+// cancellation of their run. The controller holds the request until the run is
+// cancelled, or the controller wait time has elapsed.
+type RunnerWaitCancelReq struct {
+	Namespace string `json:"namespace"`
+	RunID     string `json:"run_id"`
+}
+
+type RunnerWaitCancelResp struct {
+	Cancelled bool `json:"cancelled"`
+}
+
+func (r *RunnerWaitCancelReq) Validate() error {
+	if r.Namespace == "" {
+		return errors.New("empty namespace")
+	}
+	if r.RunID == "" {
+		return errors.New("empty run ID")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package state
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 type Flow struct {
@@ -11,15 +12,52 @@ type Flow struct {
 
 	Variables []*HCLVariable `json:"variable"`
 
+	// Workspace is an optional shared workspace, provided by a Nomad dynamic
+	// host volume, which is mounted into every specification job of a run.
+	Workspace *FlowWorkspace `json:"workspace"`
+
 	//
 	Inline        *InlineFlow          `hcl:"inline,block" json:"inline"`
 	Specification []*SpecificationFlow `hcl:"specification,optional" json:"specification"`
+
+	// OnSuccess, OnFailure, and Finally are hooks which run after the main
+	// steps or specifications of the flow. They contain steps for inline flows
+	// and specifications for specification flows.
+	OnSuccess *FlowHook `json:"on_success"`
+	OnFailure *FlowHook `json:"on_failure"`
+	Finally   *FlowHook `json:"finally"`
+}
+
+type FlowHook struct {
+	Steps         []*Step              `json:"step"`
+	Specification []*SpecificationFlow `json:"specification"`
 }
 
 type InlineFlow struct {
 	ID     string      `hcl:"id,label" json:"id"`
 	Runner *FlowRunner `hcl:"runner,block" json:"runner"`
 	Steps  []*Step     `hcl:"step,block" json:"step"`
+
+	// Env contains environment variables which are set for every step. Step
+	// level variables of the same name take precedence.
+	Env map[string]string `json:"env"`
+
+	// CancelGracePeriod is the duration the runner waits, after sending
+	// SIGTERM to a running step on cancellation, before sending SIGKILL.
+	CancelGracePeriod string `json:"cancel_grace_period"`
+}
+
+// DefaultCancelGracePeriod is the cancel grace period used when the inline
+// flow does not specify one.
+const DefaultCancelGracePeriod = 30 * time.Second
+
+// GetCancelGracePeriod returns the parsed cancel grace period, or the default
+// if one has not been set.
+func (i *InlineFlow) GetCancelGracePeriod() (time.Duration, error) {
+	if i.CancelGracePeriod == "" {
+		return DefaultCancelGracePeriod, nil
+	}
+	return time.ParseDuration(i.CancelGracePeriod)
 }
 
 type FlowRunner struct {
@@ -44,6 +82,16 @@ type NomadOnDemandResource struct {
 	Memory int `hcl:"memory,optional" json:"memory"`
 }
 
+type FlowWorkspace struct {
+	Namespace   string            `json:"namespace"`
+	PluginID    string            `json:"plugin_id"`
+	NodePool    string            `json:"node_pool"`
+	CapacityMin int               `json:"capacity_min"`
+	CapacityMax int               `json:"capacity_max"`
+	MountPath   string            `json:"mount_path"`
+	Parameters  map[string]string `json:"parameters"`
+}
+
 type SpecificationFlow struct {
 	ID               string            `json:"id"`
 	Condition        string            `json:"condition"`
@@ -60,7 +108,22 @@ type JobSpecification struct {
 type Step struct {
 	ID        string `json:"id"`
 	Condition string `json:"condition"`
+	Image     string `json:"image"`
 	Run       string `json:"run"`
+
+	Env        map[string]string `json:"env"`
+	WorkingDir string            `json:"working_dir"`
+	Shell      string            `json:"shell"`
+
+	Reports *StepReports `json:"reports,omitempty"`
+}
+
+// StepReports configures the test reports the runner parses once the step
+// has finished. Each field is a glob pattern, which is relative to the step
+// working directory.
+type StepReports struct {
+	JUnit  string `json:"junit,omitempty"`
+	GoTest string `json:"go_test,omitempty"`
 }
 
 type FlowStub struct {
@@ -103,5 +166,155 @@ func (f *Flow) Validate(reqNamespace string) error {
 			f.Namespace, reqNamespace))
 	}
 
+	if f.Inline != nil {
+		if _, err := f.Inline.GetCancelGracePeriod(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse cancel grace period: %w", err))
+		}
+	}
+
+	if f.Workspace != nil && f.Type() != FlowTypeSpecification {
+		errs = append(errs, errors.New("workspace is only supported by specification flows"))
+	}
+
+	errs = append(errs, f.validateHooks()...)
+
 	return errors.Join(errs...)
 }
+
+// Condition functions used to gate the flow hooks. A step or specification
+// without a condition uses DefaultCondition, so that it only runs while the
+// run is successful.
+const (
+	DefaultCondition   = "success()"
+	ConditionFailure   = "failure()"
+	ConditionAlways    = "always()"
+	ConditionCancelled = "cancelled()"
+)
+
+type namedFlowHook struct {
+	name string
+	hook *FlowHook
+}
+
+func (f *Flow) hooks() []namedFlowHook {
+	return []namedFlowHook{
+		{name: "on_success", hook: f.OnSuccess},
+		{name: "on_failure", hook: f.OnFailure},
+		{name: "finally", hook: f.Finally},
+	}
+}
+
+func (f *Flow) validateHooks() []error {
+
+	var errs []error
+
+	ids := make(map[string]struct{})
+
+	if f.Inline != nil {
+		for _, step := range f.Inline.Steps {
+			ids[step.ID] = struct{}{}
+		}
+	}
+	for _, spec := range f.Specification {
+		ids[spec.ID] = struct{}{}
+	}
+
+	for _, nh := range f.hooks() {
+		name, hook := nh.name, nh.hook
+		if hook == nil {
+			continue
+		}
+
+		switch f.Type() {
+		case FlowTypeInline:
+			if len(hook.Specification) > 0 {
+				errs = append(errs, fmt.Errorf("%s hook must contain steps for inline flows", name))
+			}
+			for _, step := range hook.Steps {
+				if _, ok := ids[step.ID]; ok {
+					errs = append(errs, fmt.Errorf("%s hook step %q duplicates an existing step", name, step.ID))
+				}
+				ids[step.ID] = struct{}{}
+			}
+		case FlowTypeSpecification:
+			if len(hook.Steps) > 0 {
+				errs = append(errs, fmt.Errorf("%s hook must contain specifications for specification flows", name))
+			}
+			for _, spec := range hook.Specification {
+				if _, ok := ids[spec.ID]; ok {
+					errs = append(errs, fmt.Errorf("%s hook specification %q duplicates an existing specification", name, spec.ID))
+				}
+				ids[spec.ID] = struct{}{}
+			}
+		}
+	}
+
+	return errs
+}
+
+// ExpandHooks returns a copy of the flow where the hook steps or
+// specifications are appended to the main ones, gated by the condition that
+// matches the hook. This allows runners to execute hooks without any specific
+// handling.
+//
+// The on_failure hook is placed before the on_success hook, so that a failing
+// on_success step does not trigger the on_failure hook.
+func (f *Flow) ExpandHooks() *Flow {
+
+	if f.OnSuccess == nil && f.OnFailure == nil && f.Finally == nil {
+		return f
+	}
+
+	expanded := *f
+	expanded.OnSuccess, expanded.OnFailure, expanded.Finally = nil, nil, nil
+
+	hooks := []struct {
+		hook      *FlowHook
+		condition string
+	}{
+		{hook: f.OnFailure, condition: ConditionFailure},
+		{hook: f.OnSuccess, condition: DefaultCondition},
+		{hook: f.Finally, condition: ConditionAlways},
+	}
+
+	switch f.Type() {
+	case FlowTypeInline:
+		inline := *f.Inline
+		inline.Steps = append([]*Step{}, f.Inline.Steps...)
+
+		for _, h := range hooks {
+			if h.hook == nil {
+				continue
+			}
+			for _, step := range h.hook.Steps {
+				hookStep := *step
+				hookStep.Condition = hookCondition(h.condition, step.Condition)
+				inline.Steps = append(inline.Steps, &hookStep)
+			}
+		}
+		expanded.Inline = &inline
+
+	case FlowTypeSpecification:
+		expanded.Specification = append([]*SpecificationFlow{}, f.Specification...)
+
+		for _, h := range hooks {
+			if h.hook == nil {
+				continue
+			}
+			for _, spec := range h.hook.Specification {
+				hookSpec := *spec
+				hookSpec.Condition = hookCondition(h.condition, spec.Condition)
+				expanded.Specification = append(expanded.Specification, &hookSpec)
+			}
+		}
+	}
+
+	return &expanded
+}
+
+func hookCondition(hookCond, cond string) string {
+	if cond == "" {
+		return hookCond
+	}
+	return fmt.Sprintf("%s && (%s)", hookCond, cond)
+}
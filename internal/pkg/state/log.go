@@ -0,0 +1,51 @@
+package state
+
+import "time"
+
+const (
+	LogStreamStdout   = "stdout"
+	LogStreamStderr   = "stderr"
+	LogStreamCombined = "combined"
+)
+
+// LogLine is a single line of output captured from a step.
+type LogLine struct {
+	// Timestamp is the time the runner captured the line.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Stream is the output stream the line was captured from, either stdout
+	// or stderr.
+	Stream string `json:"stream"`
+
+	// Sequence is the position of the line within the step output, across
+	// both streams, starting at one. This allows the original interleaving of
+	// stdout and stderr to be restored.
+	Sequence uint64 `json:"sequence"`
+
+	Text string `json:"text"`
+
+	// Group is the name of the log group the line was written within, which
+	// a step opens using the group workflow command.
+	Group string `json:"group,omitempty"`
+
+	// StepID is the step which wrote the line. It is only set when streaming
+	// the logs of a whole run, as stored lines are already held per step.
+	StepID string `json:"step_id,omitempty"`
+}
+
+// LogMatch is a step log line which matched a log search.
+type LogMatch struct {
+	RunID  string `json:"run_id"`
+	StepID string `json:"step_id"`
+
+	// LineNumber is the position of the line within the combined output of
+	// the step, starting at one.
+	LineNumber int `json:"line_number"`
+
+	*LogLine
+
+	// Before and After hold the lines surrounding the match, when context
+	// lines were requested.
+	Before []*LogLine `json:"before,omitempty"`
+	After  []*LogLine `json:"after,omitempty"`
+}
@@ -16,6 +16,12 @@ const (
 	RunStatusSkipped   = "skipped"
 )
 
+// IsTerminalStatus returns whether the run, step, or specification status is
+// final and will not change.
+func IsTerminalStatus(status string) bool {
+	return status != RunStatusPending && status != RunStatusRunning
+}
+
 type RunNamespacedKey struct {
 	ID        ulid.ULID
 	Namespace string
@@ -28,9 +34,10 @@ type Run struct {
 	Status    string    `json:"status"`
 	Trigger   string    `json:"trigger"`
 
-	CreateTime time.Time `json:"create_time"`
-	StartTime  time.Time `json:"start_time"`
-	EndTime    time.Time `json:"end_time"`
+	TriggerTime time.Time `json:"trigger_time"`
+	CreateTime  time.Time `json:"create_time"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
 
 	Variables map[string]any `json:"variables"`
 
@@ -49,6 +56,110 @@ type InlineStep struct {
 	ExitCode  int       `json:"exit_code"`
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
+
+	// Tests is the summary of the test reports produced by the step, if the
+	// step configured any.
+	Tests *TestReport `json:"tests,omitempty"`
+
+	// Annotations and Outputs are set by the step using workflow commands
+	// written to its output.
+	Annotations []*Annotation     `json:"annotations,omitempty"`
+	Outputs     map[string]string `json:"outputs,omitempty"`
+
+	// Summary is the markdown summary written by the step to its summary
+	// file.
+	Summary string `json:"summary,omitempty"`
+}
+
+const (
+	AnnotationLevelError   = "error"
+	AnnotationLevelWarning = "warning"
+	AnnotationLevelNotice  = "notice"
+)
+
+// Annotation is a message about the step, optionally attached to a location
+// within a file of the workspace.
+type Annotation struct {
+	Level     string `json:"level"`
+	Title     string `json:"title,omitempty"`
+	Message   string `json:"message"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndColumn int    `json:"end_column,omitempty"`
+}
+
+// TestReport summarises the test results parsed from the reports of a step.
+// Durations are in seconds.
+type TestReport struct {
+	Total    int     `json:"total"`
+	Passed   int     `json:"passed"`
+	Failed   int     `json:"failed"`
+	Skipped  int     `json:"skipped"`
+	Duration float64 `json:"duration"`
+
+	// Failures holds the details of failed tests. It is limited in size, so
+	// it may not contain all failed tests.
+	Failures []*TestFailure `json:"failures,omitempty"`
+}
+
+type TestFailure struct {
+	Suite    string  `json:"suite"`
+	Name     string  `json:"name"`
+	Message  string  `json:"message"`
+	Duration float64 `json:"duration"`
+}
+
+func (t *TestReport) Copy() *TestReport {
+	if t == nil {
+		return nil
+	}
+
+	copy := *t
+	copy.Failures = make([]*TestFailure, len(t.Failures))
+
+	for i, failure := range t.Failures {
+		f := *failure
+		copy.Failures[i] = &f
+	}
+
+	return &copy
+}
+
+// StepTestReport is the test report of a single step within a run.
+type StepTestReport struct {
+	StepID string `json:"step_id"`
+	*TestReport
+}
+
+// TestReports returns the test reports of the inline steps which produced
+// one, along with a summary of the totals across all steps. The summary does
+// not include the failure details, which are available on each step.
+func (r *Run) TestReports() (*TestReport, []*StepTestReport) {
+
+	summary := TestReport{}
+	steps := []*StepTestReport{}
+
+	if r.InlineRun == nil {
+		return &summary, steps
+	}
+
+	for _, step := range r.InlineRun.Steps {
+		if step.Tests == nil {
+			continue
+		}
+
+		summary.Total += step.Tests.Total
+		summary.Passed += step.Tests.Passed
+		summary.Failed += step.Tests.Failed
+		summary.Skipped += step.Tests.Skipped
+		summary.Duration += step.Tests.Duration
+
+		steps = append(steps, &StepTestReport{StepID: step.ID, TestReport: step.Tests})
+	}
+
+	return &summary, steps
 }
 
 type SpecRun struct {
@@ -156,6 +267,14 @@ func (r *Run) Copy() *Run {
 					ExitCode:  step.ExitCode,
 					StartTime: step.StartTime,
 					EndTime:   step.EndTime,
+					Tests:     step.Tests.Copy(),
+					Outputs:   maps.Clone(step.Outputs),
+					Summary:   step.Summary,
+				}
+
+				for _, annotation := range step.Annotations {
+					a := *annotation
+					copy.InlineRun.Steps[i].Annotations = append(copy.InlineRun.Steps[i].Annotations, &a)
 				}
 			}
 		}
@@ -2,6 +2,7 @@ package state
 
 import (
 	"errors"
+	"time"
 )
 
 type Trigger struct {
@@ -10,6 +11,16 @@ type Trigger struct {
 	Flow      string `json:"flow"`
 
 	Source *TriggerSource `json:"source"`
+
+	// Paused stops the trigger from running the flow without deleting it.
+	// Paused cron triggers are not scheduled, and webhook deliveries to
+	// paused triggers are recorded but ignored.
+	Paused bool `json:"paused,omitempty"`
+
+	// LastFireTime is the scheduled time the cron trigger last fired at. It is
+	// recorded when the trigger catches up on missed times, so the times
+	// missed while the controller was not running can be determined.
+	LastFireTime time.Time `json:"last_fire_time,omitzero"`
 }
 
 type TriggerSource struct {
@@ -54,3 +65,51 @@ type TriggerStub struct {
 	Namespace string `json:"namespace"`
 	Flow      string `json:"flow"`
 }
+
+const (
+	// TriggerDeliveryDecisionTriggered is the decision of a delivery which ran
+	// the flow.
+	TriggerDeliveryDecisionTriggered = "triggered"
+
+	// TriggerDeliveryDecisionIgnored is the decision of a delivery which was
+	// valid, but did not match the trigger, such as its event or filters.
+	TriggerDeliveryDecisionIgnored = "ignored"
+
+	// TriggerDeliveryDecisionRejected is the decision of a delivery which was
+	// invalid, such as having an invalid signature or payload.
+	TriggerDeliveryDecisionRejected = "rejected"
+
+	// TriggerDeliveryDecisionFailed is the decision of a delivery which
+	// matched the trigger, but failed to run the flow.
+	TriggerDeliveryDecisionFailed = "failed"
+)
+
+// TriggerDelivery is the record of a webhook request received by a trigger.
+type TriggerDelivery struct {
+	ID        string `json:"id"`
+	TriggerID string `json:"trigger_id"`
+	Namespace string `json:"namespace"`
+
+	// RedeliveryOf is the ID of the delivery which this delivery redelivered.
+	RedeliveryOf string `json:"redelivery_of,omitempty"`
+
+	Time  time.Time `json:"time"`
+	Event string    `json:"event,omitempty"`
+
+	// Headers are the request headers, where headers which may contain
+	// secrets are redacted.
+	Headers map[string]string `json:"headers"`
+
+	// PayloadHash is the hex encoded SHA-256 hash of the payload.
+	PayloadHash string `json:"payload_hash"`
+	PayloadSize int    `json:"payload_size"`
+
+	// Decision is the outcome of the delivery, and Reason is the response
+	// message describing it.
+	Decision   string `json:"decision"`
+	Reason     string `json:"reason,omitempty"`
+	StatusCode int    `json:"status_code"`
+
+	RunID string `json:"run_id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,34 @@
+package job
+
+import (
+	"context"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/runner/job"
+	"github.com/urfave/cli/v3"
+)
+
+func installCommandFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:     "dest",
+			Required: true,
+			Value:    "",
+			Usage:    "Path to the directory to install the runner binary into",
+		},
+	}
+}
+
+func installCommand() *cli.Command {
+	return &cli.Command{
+		Name:     "install",
+		Category: "job",
+		Usage:    "Install the runner binary for use by step images",
+		Flags:    installCommandFlags(),
+		Action: func(_ context.Context, cmd *cli.Command) error {
+			if err := job.Install(cmd.String("dest")); err != nil {
+				return cli.Exit(err, 1)
+			}
+			return nil
+		},
+	}
+}
@@ -9,6 +9,7 @@ func Command() *cli.Command {
 		HideHelpCommand: true,
 		UsageText:       "nomad-pipeline-runner job <command> [options] [args]",
 		Commands: []*cli.Command{
+			installCommand(),
 			runCommand(),
 		},
 	}
@@ -0,0 +1,66 @@
+package job
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	sharedrpc "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/rpc"
+)
+
+// cancelRetryInterval is the time to wait before retrying a failed cancel
+// long-poll RPC call.
+const cancelRetryInterval = 5 * time.Second
+
+// watchCancel long-polls the controller for the cancellation of the run,
+// closing the cancel channel once it has been cancelled. It returns when the
+// done channel is closed.
+func (r *Runner) watchCancel(done <-chan struct{}) {
+
+	req := sharedrpc.RunnerWaitCancelReq{
+		Namespace: r.cfg.Namespace,
+		RunID:     r.cfg.ID.String(),
+	}
+
+	for {
+		var resp sharedrpc.RunnerWaitCancelResp
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- r.rpcClient.Call(sharedrpc.RunnerWaitCancelMethodName, req, &resp) }()
+
+		var err error
+
+		select {
+		case <-done:
+			return
+		case err = <-errCh:
+		}
+
+		if err != nil {
+			r.logger.Error("failed to wait for run cancellation via RPC", zap.Error(err))
+
+			select {
+			case <-done:
+				return
+			case <-time.After(cancelRetryInterval):
+			}
+			continue
+		}
+
+		if resp.Cancelled {
+			r.logger.Info("received run cancellation from controller")
+			r.cancelOnce.Do(func() { close(r.cancelCh) })
+			return
+		}
+	}
+}
+
+// isCancelled returns whether the run has been cancelled.
+func (r *Runner) isCancelled() bool {
+	select {
+	case <-r.cancelCh:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,151 @@
+package job
+
+import (
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// commandPrefix marks a line of step output as a workflow command, in the
+	// form ::name key=value,key=value::message.
+	commandPrefix = "::"
+
+	// commandMaxAnnotations is the maximum number of annotations recorded for
+	// each step.
+	commandMaxAnnotations = 100
+
+	// commandMaxOutputs is the maximum number of outputs recorded for each
+	// step.
+	commandMaxOutputs = 100
+
+	// commandMaxValueSize is the maximum size, in bytes, of each annotation
+	// message and output value.
+	commandMaxValueSize = 4 * 1024
+)
+
+// workflowCommand is a command parsed from a line of step output.
+type workflowCommand struct {
+	name       string
+	properties map[string]string
+	message    string
+}
+
+// parseWorkflowCommand parses the line as a workflow command. It returns false
+// if the line is not a command.
+func parseWorkflowCommand(line string) (*workflowCommand, bool) {
+
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), commandPrefix)
+	if !ok {
+		return nil, false
+	}
+
+	header, message, ok := strings.Cut(rest, commandPrefix)
+	if !ok {
+		return nil, false
+	}
+
+	name, props, _ := strings.Cut(header, " ")
+	if name == "" || strings.ContainsAny(name, "=,") {
+		return nil, false
+	}
+
+	cmd := workflowCommand{
+		name:       name,
+		properties: make(map[string]string),
+		message:    unescapeCommandData(message),
+	}
+
+	for _, prop := range strings.Split(props, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(prop), "=")
+		if !ok || key == "" {
+			continue
+		}
+		cmd.properties[key] = unescapeCommandProperty(val)
+	}
+
+	return &cmd, true
+}
+
+// unescapeCommandData reverses the escaping of a command message, which
+// allows messages to span multiple lines.
+func unescapeCommandData(s string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%25", "%").Replace(s)
+}
+
+// unescapeCommandProperty reverses the escaping of a command property value,
+// which additionally allows values to contain the property delimiters.
+func unescapeCommandProperty(s string) string {
+	return strings.NewReplacer("%0D", "\r", "%0A", "\n", "%3A", ":", "%2C", ",", "%25", "%").Replace(s)
+}
+
+// stepCommands collects the results of the workflow commands written by a
+// step. It is shared by the log handlers of both output streams.
+type stepCommands struct {
+	lock        sync.Mutex
+	annotations []*state.Annotation
+	outputs     map[string]string
+}
+
+func newStepCommands() *stepCommands {
+	return &stepCommands{outputs: make(map[string]string)}
+}
+
+func (s *stepCommands) addAnnotation(level string, cmd *workflowCommand) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.annotations) >= commandMaxAnnotations {
+		return
+	}
+
+	annotation := state.Annotation{
+		Level:   level,
+		Title:   cmd.properties["title"],
+		File:    cmd.properties["file"],
+		Message: truncateCommandValue(cmd.message),
+	}
+
+	annotation.Line, _ = strconv.Atoi(cmd.properties["line"])
+	annotation.EndLine, _ = strconv.Atoi(cmd.properties["endLine"])
+	annotation.Column, _ = strconv.Atoi(cmd.properties["col"])
+	annotation.EndColumn, _ = strconv.Atoi(cmd.properties["endColumn"])
+
+	s.annotations = append(s.annotations, &annotation)
+}
+
+func (s *stepCommands) setOutput(name, value string) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.outputs[name]; !ok && len(s.outputs) >= commandMaxOutputs {
+		return
+	}
+	s.outputs[name] = truncateCommandValue(value)
+}
+
+// result returns copies of the annotations and outputs collected so far.
+func (s *stepCommands) result() ([]*state.Annotation, map[string]string) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var outputs map[string]string
+	if len(s.outputs) > 0 {
+		outputs = maps.Clone(s.outputs)
+	}
+
+	return append([]*state.Annotation(nil), s.annotations...), outputs
+}
+
+func truncateCommandValue(s string) string {
+	if len(s) > commandMaxValueSize {
+		return s[:commandMaxValueSize]
+	}
+	return s
+}
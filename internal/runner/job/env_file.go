@@ -0,0 +1,132 @@
+package job
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// envFileDir is the directory, within the runner data directory, where the
+	// env file of each step is created. The data directory is visible to all
+	// runner tasks when the run uses multiple step images, so each task can
+	// load the env files of steps it does not own.
+	envFileDir = "env"
+
+	// envFileVar is the environment variable which holds the path of the env
+	// file for the running step. Lines the step appends to the file are
+	// exported into the environment of all later steps.
+	envFileVar = "NOMAD_PIPELINE_ENV"
+)
+
+func (r *Runner) envFilePath(stepID string) string {
+	return filepath.Join(r.dataDir, envFileDir, stepID)
+}
+
+// createEnvFile creates an empty env file for the step and returns its
+// absolute path, so it remains valid regardless of the step working directory.
+func (r *Runner) createEnvFile(stepID string) (string, error) {
+
+	if err := os.MkdirAll(filepath.Join(r.dataDir, envFileDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create env file directory: %w", err)
+	}
+
+	path, err := filepath.Abs(r.envFilePath(stepID))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve env file path: %w", err)
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return "", fmt.Errorf("failed to create env file: %w", err)
+	}
+
+	return path, nil
+}
+
+// loadEnvFile reads the env file written by the step and merges its entries
+// into the environment exported to later steps. A missing file is not an
+// error, as skipped steps do not create one.
+func (r *Runner) loadEnvFile(stepID string) error {
+
+	data, err := os.ReadFile(r.envFilePath(stepID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read env file: %w", err)
+	}
+
+	env, err := parseEnvFile(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse env file: %w", err)
+	}
+
+	for k, v := range env {
+		r.logger.Debug("exporting step env variable", zap.String("step_id", stepID), zap.String("name", k))
+		r.env[k] = v
+	}
+
+	return nil
+}
+
+// parseEnvFile parses the content of an env file. Each entry is either a
+// single line in the form NAME=value, or a multi-line value in the form:
+//
+//	NAME<<DELIMITER
+//	value
+//	DELIMITER
+func parseEnvFile(content string) (map[string]string, error) {
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	for scanner.Scan() {
+
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		eqIdx := strings.Index(line, "=")
+		hdIdx := strings.Index(line, "<<")
+
+		switch {
+		case hdIdx > 0 && (eqIdx < 0 || hdIdx < eqIdx):
+			name, delim := line[:hdIdx], line[hdIdx+2:]
+			if delim == "" {
+				return nil, fmt.Errorf("missing delimiter for %q", name)
+			}
+
+			var (
+				lines  []string
+				closed bool
+			)
+
+			for scanner.Scan() {
+				if scanner.Text() == delim {
+					closed = true
+					break
+				}
+				lines = append(lines, scanner.Text())
+			}
+
+			if !closed {
+				return nil, fmt.Errorf("missing closing delimiter %q for %q", delim, name)
+			}
+			env[name] = strings.Join(lines, "\n")
+
+		case eqIdx > 0:
+			env[line[:eqIdx]] = line[eqIdx+1:]
+
+		default:
+			return nil, fmt.Errorf("invalid line %q", line)
+		}
+	}
+
+	return env, scanner.Err()
+}
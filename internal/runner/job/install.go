@@ -0,0 +1,41 @@
+package job
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Install copies the running runner binary into the destination directory.
+// This is used by the install lifecycle task, so that step images which do not
+// include the runner binary can still execute steps.
+func Install(dest string) error {
+
+	srcPath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to identify runner binary: %w", err)
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmt.Errorf("failed to create install directory: %w", err)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open runner binary: %w", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(filepath.Join(dest, filepath.Base(srcPath)), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create runner binary: %w", err)
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to copy runner binary: %w", err)
+	}
+
+	return nil
+}
@@ -3,8 +3,10 @@ package job
 import (
 	"encoding/json"
 	"fmt"
-	"net/rpc"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -17,9 +19,28 @@ import (
 
 type Runner struct {
 	cfg       *host.RunConfig
+	workDir   string
 	logger    *zap.Logger
 	context   *context.Context
-	rpcClient *rpc.Client
+	rpcClient *rpcClient
+
+	// dataDir holds the files the runner uses to manage the steps, such as
+	// their env and summary files, which are kept out of the workspace.
+	dataDir string
+
+	// spool durably queues the job updates and log batches sent to the
+	// controller.
+	spool *spool
+
+	// env contains the environment variables exported by previous steps via
+	// their env file.
+	env map[string]string
+
+	// cancelCh is closed when the controller notifies the runner that the run
+	// has been cancelled.
+	cancelCh    chan struct{}
+	cancelOnce  sync.Once
+	gracePeriod time.Duration
 }
 
 func NewRunner(path string) (*Runner, error) {
@@ -35,64 +56,215 @@ func NewRunner(path string) (*Runner, error) {
 		return nil, fmt.Errorf("failed to decode file: %w", err)
 	}
 
-	client, err := rpc.Dial("tcp", cfg.ControllerRPC)
+	zapLogger, err := logger.NewZap(logger.DefaultRunnerConfig())
 	if err != nil {
-		return nil, fmt.Errorf("failed to create RPC client: %w", err)
+		return nil, fmt.Errorf("failed to create zap logger: %w", err)
 	}
 
-	zapLogger, err := logger.NewZap(logger.DefaultRunnerConfig())
+	runLogger := zapLogger.With(
+		zap.String("task", cfg.Task),
+		zap.String("job_id", cfg.JobID),
+		zap.String("flow_id", cfg.Flow.ID),
+		zap.String("run_id", cfg.ID.String()),
+		zap.String("namespace", cfg.Namespace),
+	)
+
+	client, err := newRPCClient(runLogger, cfg.ControllerRPC)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create zap logger: %w", err)
+		return nil, err
+	}
+
+	// The spool file is kept within the task local directory, so it is not
+	// shared between runner tasks.
+	spool, err := newSpool(runLogger, client, filepath.Join("local", cfg.ID.String()+".spool"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool: %w", err)
+	}
+
+	gracePeriod, err := cfg.Flow.Inline.GetCancelGracePeriod()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cancel grace period: %w", err)
 	}
 
+	// Older controllers do not set the working directory, so fallback to the
+	// original location within the task local directory.
+	workDir := cfg.WorkDir
+	if workDir == "" {
+		workDir = filepath.Join("local", cfg.ID.String())
+	}
+
+	// The data directory is created next to the workspace rather than within
+	// it, so the files do not appear within the checked out repository. It
+	// shares the parent of the workspace, so it is visible to all runner tasks
+	// whenever the workspace is.
+	dataDir := filepath.Clean(workDir) + ".runner"
+
 	return &Runner{
-		cfg: &cfg,
-		logger: zapLogger.With(
-			zap.String("job_id", cfg.JobID),
-			zap.String("flow_id", cfg.Flow.ID),
-			zap.String("run_id", cfg.ID.String()),
-			zap.String("namespace", cfg.Namespace),
-		),
+		cfg:     &cfg,
+		workDir: workDir,
+		dataDir: dataDir,
+		logger:  runLogger,
 		context: context.New(
 			cfg.ID,
-			cfg.Flow.ID,
+			cfg.Trigger,
+			cfg.TriggerTime,
 			cfg.Flow,
 			cfg.Variables,
 		),
-		rpcClient: client,
+		rpcClient:   client,
+		spool:       spool,
+		env:         make(map[string]string),
+		cancelCh:    make(chan struct{}),
+		gracePeriod: gracePeriod,
 	}, nil
 }
 
 func (r *Runner) Run() error {
 
+	runErr := r.run()
+	if runErr != nil {
+		r.abortRun()
+
+		// End the run, so it does not remain running when the runner is
+		// unable to complete it.
+		status := state.RunStatusFailed
+		if r.isCancelled() {
+			status = state.RunStatusCancelled
+		}
+		r.endJob(status)
+	}
+
+	// Ensure all updates and logs reach the controller before exiting, as the
+	// spool does not outlive the allocation.
+	if err := r.spool.Drain(spoolDrainTimeout); err != nil {
+		r.logger.Error("failed to deliver queued updates and logs", zap.Error(err))
+	}
+
+	return runErr
+}
+
+func (r *Runner) run() error {
+
+	if err := os.MkdirAll(r.workDir, 0755); err != nil {
+		return fmt.Errorf("failed to create working directory: %w", err)
+	}
+
 	r.startJob()
 
-	var failed bool
+	done := make(chan struct{})
+	defer close(done)
+
+	go r.watchCancel(done)
+	go r.heartbeat(done)
+
+	var failed, cancelled bool
 
 	for _, step := range r.cfg.JobSteps {
 
-		should := true
+		// Once the run has been cancelled, the status is updated straight away
+		// so any further updates sent while cleaning up do not regress it.
+		if !cancelled && r.isCancelled() {
+			cancelled = true
+			r.context.EndRun(state.RunStatusCancelled)
+		}
 
-		if step.Condition != "" {
-			eval, err := r.context.ParseBoolExpr(step.Condition)
+		// Steps owned by another runner task are executed there, so wait for
+		// the result and synchronise it into the local context. This ensures
+		// conditions and templates see the same state in all tasks.
+		if !r.cfg.OwnsStep(step.ID) {
+			result, err := r.waitStepResult(step.ID)
 			if err != nil {
-				return fmt.Errorf("failed to evaluate condition for step %s: %w", step.ID, err)
+				return fmt.Errorf("failed to get result for step %s: %w", step.ID, err)
+			}
+
+			r.context.SetInlineStep(result)
+
+			// The owning task fails the step when its env file is invalid, so
+			// the failure is already reflected by the result.
+			if err := r.loadEnvFile(step.ID); err != nil {
+				r.logger.Error("failed to load env file of step",
+					zap.String("step_id", step.ID), zap.Error(err))
+			}
+
+			switch result.Status {
+			case state.RunStatusFailed:
+				failed = true
+			case state.RunStatusCancelled:
+				// The owning task has observed the cancellation, which this
+				// task may not have yet, so mark it locally to ensure both
+				// evaluate later conditions the same.
+				r.cancelOnce.Do(func() { close(r.cancelCh) })
 			}
-			should = eval
+			continue
 		}
 
-		if !should || failed {
-			r.logger.Info("skipping step due to condition evaluation", zap.String("step_id", step.ID))
-			r.context.EndInlineStep(step.ID, state.RunStatusSkipped, -1)
+		// Conditions fully control whether a step runs, with steps that do not
+		// define one only running while the run is successful.
+		// A condition which cannot be evaluated fails the step, so the run
+		// still ends with a terminal status.
+		should, err := r.context.EvalCondition(step.Condition)
+		if err != nil {
+			r.logger.Error("failed to evaluate step condition",
+				zap.String("step_id", step.ID), zap.Error(err))
+			r.context.SetInlineStepResults(step.ID, &state.InlineStep{
+				Annotations: []*state.Annotation{stepErrorAnnotation("Invalid condition", err)},
+			})
+			r.context.EndInlineStep(step.ID, state.RunStatusFailed, -1)
 			r.sendUpdateRPC()
+
+			if err := r.writeStepResult(step.ID); err != nil {
+				return fmt.Errorf("failed to write result for step %s: %w", step.ID, err)
+			}
+
+			failed = true
+			continue
+		}
+
+		if !should {
+			// Steps prevented from running by a cancellation are reported as
+			// cancelled, rather than skipped.
+			status := state.RunStatusSkipped
+			if cancelled {
+				status = state.RunStatusCancelled
+			}
+
+			r.logger.Info("skipping step due to condition evaluation",
+				zap.String("step_id", step.ID), zap.String("status", status))
+			r.context.EndInlineStep(step.ID, status, -1)
+			r.sendUpdateRPC()
+
+			if err := r.writeStepResult(step.ID); err != nil {
+				return fmt.Errorf("failed to write result for step %s: %w", step.ID, err)
+			}
 			continue
 		}
 
+		envFile, err := r.createEnvFile(step.ID)
+		if err != nil {
+			return fmt.Errorf("failed to setup step %s: %w", step.ID, err)
+		}
+
+		summaryFile, err := r.createSummaryFile(step.ID)
+		if err != nil {
+			return fmt.Errorf("failed to setup step %s: %w", step.ID, err)
+		}
+
 		sr := &stepRunner{
-			cfg:       r.cfg,
-			context:   r.context,
-			logger:    r.logger,
-			rpcClient: r.rpcClient,
+			cfg:         r.cfg,
+			workDir:     r.workDir,
+			envFile:     envFile,
+			summaryFile: summaryFile,
+			env:         r.env,
+			gracePeriod: r.gracePeriod,
+			context:     r.context,
+			logger:      r.logger,
+			spool:       r.spool,
+		}
+
+		// Cleanup steps run after cancellation must not be interrupted by the
+		// same cancellation.
+		if !cancelled {
+			sr.cancelCh = r.cancelCh
 		}
 
 		stepResult, err := sr.executeStepRun(step)
@@ -100,14 +272,35 @@ func (r *Runner) Run() error {
 			return fmt.Errorf("failed to execute step: %w", err)
 		}
 
+		// An invalid env file fails the step, rather than the runner, so the
+		// run still ends with a terminal status.
+		if err := r.loadEnvFile(step.ID); err != nil {
+			r.logger.Error("failed to load env file of step",
+				zap.String("step_id", step.ID), zap.Error(err))
+			stepResult.Annotations = append(stepResult.Annotations, stepErrorAnnotation("Invalid env file", err))
+			if stepResult.Status == state.RunStatusSuccess {
+				stepResult.Status = state.RunStatusFailed
+			}
+		}
+
+		r.context.SetInlineStepResults(step.ID, stepResult)
 		r.context.EndInlineStep(step.ID, stepResult.Status, stepResult.ExitCode)
 		r.sendUpdateRPC()
 
+		if err := r.writeStepResult(step.ID); err != nil {
+			return fmt.Errorf("failed to write result for step %s: %w", step.ID, err)
+		}
+
 		if stepResult.Status == state.RunStatusFailed {
 			failed = true
 		}
 	}
 
+	if r.isCancelled() {
+		r.endJob(state.RunStatusCancelled)
+		return nil
+	}
+
 	endState := state.RunStatusSuccess
 	if failed {
 		endState = state.RunStatusFailed
@@ -118,25 +311,40 @@ func (r *Runner) Run() error {
 	return nil
 }
 
+// stepErrorAnnotation returns the annotation describing an error which failed
+// the step outside of its command.
+func stepErrorAnnotation(title string, err error) *state.Annotation {
+	return &state.Annotation{
+		Level:   state.AnnotationLevelError,
+		Title:   title,
+		Message: err.Error(),
+	}
+}
+
+// startJob marks the run as started. Only the leader task sends the update to
+// the controller, but all tasks track the run status, so step updates sent by
+// non-leader tasks do not regress it.
 func (r *Runner) startJob() {
 	r.logger.Info("starting flow job")
 	r.context.StartRun()
-	r.sendUpdateRPC()
+
+	if r.cfg.IsLeader() {
+		r.sendUpdateRPC()
+	}
 }
 
 func (r *Runner) endJob(status string) {
+	if !r.cfg.IsLeader() {
+		r.logger.Info("finished owned flow job steps", zap.String("status", status))
+		return
+	}
+
 	r.logger.Info("ending flow job", zap.String("status", status))
 	r.context.EndRun(status)
 	r.sendUpdateRPC()
 }
 
+// sendUpdateRPC queues the current run state for delivery to the controller.
 func (r *Runner) sendUpdateRPC() {
-	req := sharedrpc.RunnerJobUpdateReq{JobID: r.cfg.JobID, Run: r.context.Run()}
-	err := r.rpcClient.Call(sharedrpc.RunnerJobUpdateMethodName, req, nil)
-
-	if err != nil {
-		r.logger.Error("failed to send job update via RPC", zap.Error(err))
-	} else {
-		r.logger.Debug("successfully sent job update via RPC")
-	}
+	r.spool.JobUpdate(&sharedrpc.RunnerJobUpdateReq{JobID: r.cfg.JobID, Run: r.context.Run()})
 }
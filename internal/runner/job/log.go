@@ -3,13 +3,16 @@ package job
 import (
 	"bufio"
 	"context"
+	"fmt"
 	"io"
-	"net/rpc"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	sharedrpc "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/rpc"
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
 const (
@@ -22,25 +25,36 @@ type LogHandlerReq struct {
 	RunID     string
 	StepID    string
 	Type      string
+
+	// Sequence is shared by the log handlers of a step, so that each line is
+	// numbered in the order it was captured across both streams.
+	Sequence *atomic.Uint64
+
+	// Commands collects the results of workflow commands written by the step
+	// and is shared by the log handlers of a step.
+	Commands *stepCommands
 }
 
 type LogHandler struct {
-	req       *LogHandlerReq
-	logger    *zap.Logger
-	rpcClient *rpc.Client
+	req    *LogHandlerReq
+	logger *zap.Logger
+	spool  *spool
 
-	buffer []string
+	buffer []*state.LogLine
+
+	// group is the name of the log group currently open within the stream.
+	group string
 
 	cmdPipe io.ReadCloser
 }
 
-func NewLogHandler(logger *zap.Logger, pipe io.ReadCloser, rpcClient *rpc.Client, req *LogHandlerReq) *LogHandler {
+func NewLogHandler(logger *zap.Logger, pipe io.ReadCloser, spool *spool, req *LogHandlerReq) *LogHandler {
 	return &LogHandler{
-		req:       req,
-		logger:    logger.Named("logs").With(zap.String("type", req.Type)),
-		buffer:    []string{},
-		cmdPipe:   pipe,
-		rpcClient: rpcClient,
+		req:     req,
+		logger:  logger.Named("logs").With(zap.String("type", req.Type)),
+		buffer:  []*state.LogLine{},
+		cmdPipe: pipe,
+		spool:   spool,
 	}
 }
 
@@ -67,8 +81,16 @@ func (l *LogHandler) reader(ctx context.Context) {
 		default:
 		}
 
-		line := buf.Text()
-		l.buffer = append(l.buffer, line)
+		text, ok := l.handleCommand(buf.Text())
+		if ok {
+			l.buffer = append(l.buffer, &state.LogLine{
+				Timestamp: time.Now(),
+				Stream:    l.req.Type,
+				Sequence:  l.req.Sequence.Add(1),
+				Text:      text,
+				Group:     l.group,
+			})
+		}
 
 		select {
 		case <-ticker.C:
@@ -81,18 +103,52 @@ func (l *LogHandler) reader(ctx context.Context) {
 	}
 }
 
+// handleCommand processes the line if it is a workflow command, returning the
+// text to log in its place and whether the line should be logged at all.
+// Lines which are not recognised commands are returned unmodified.
+func (l *LogHandler) handleCommand(line string) (string, bool) {
+
+	if l.req.Commands == nil {
+		return line, true
+	}
+
+	cmd, ok := parseWorkflowCommand(line)
+	if !ok {
+		return line, true
+	}
+
+	switch cmd.name {
+	case state.AnnotationLevelError, state.AnnotationLevelWarning, state.AnnotationLevelNotice:
+		l.req.Commands.addAnnotation(cmd.name, cmd)
+		return fmt.Sprintf("%s%s: %s", strings.ToUpper(cmd.name[:1]), cmd.name[1:], cmd.message), true
+	case "group":
+		l.group = cmd.message
+		return "", false
+	case "endgroup":
+		l.group = ""
+		return "", false
+	case "set-output":
+		if name := cmd.properties["name"]; name != "" {
+			l.req.Commands.setOutput(name, cmd.message)
+		}
+		return "", false
+	default:
+		return line, true
+	}
+}
+
 func (l *LogHandler) flushLogs() {
 
 	if len(l.buffer) == 0 {
 		return
 	}
 
-	logLines := make([]string, len(l.buffer))
+	logLines := make([]*state.LogLine, len(l.buffer))
 	copy(logLines, l.buffer)
 
 	l.buffer = l.buffer[:0]
 
-	req := sharedrpc.RunnerLogsBatchReq{
+	req := &sharedrpc.RunnerLogsBatchReq{
 		Namespace: l.req.Namespace,
 		RunID:     l.req.RunID,
 		StepID:    l.req.StepID,
@@ -100,9 +156,6 @@ func (l *LogHandler) flushLogs() {
 		Logs:      logLines,
 	}
 
-	if err := l.rpcClient.Call(sharedrpc.RunnerLogsBatchMethodName, req, nil); err != nil {
-		l.logger.Error("failed to send log batch", zap.Error(err))
-	} else {
-		l.logger.Debug("successfully sent log batch", zap.Int("num_lines", len(logLines)))
-	}
+	l.spool.LogsBatch(req)
+	l.logger.Debug("queued log batch", zap.Int("num_lines", len(logLines)))
 }
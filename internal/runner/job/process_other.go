@@ -0,0 +1,22 @@
+//go:build !unix
+
+package job
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(_ *exec.Cmd) {}
+
+// signalProcessGroup signals the command process only, as process groups are
+// not supported on this platform.
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if sig == syscall.SIGKILL {
+		return cmd.Process.Kill()
+	}
+	return cmd.Process.Signal(sig)
+}
+
+func processExitCode(ps *os.ProcessState) int { return ps.ExitCode() }
@@ -0,0 +1,322 @@
+package job
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// reportMaxFailures is the maximum number of failed tests detailed within
+	// a step test report, so a badly broken suite does not produce a huge run
+	// object.
+	reportMaxFailures = 100
+
+	// reportMaxMessageSize is the maximum size, in bytes, of each failure
+	// message within a step test report.
+	reportMaxMessageSize = 4 * 1024
+)
+
+// collectTestReport parses the test reports configured on the step, which are
+// resolved relative to the step working directory, and returns a summary of
+// the results. It returns nil if no report files were found.
+func collectTestReport(workDir string, reports *state.StepReports) (*state.TestReport, error) {
+
+	if reports == nil {
+		return nil, nil
+	}
+
+	var (
+		report state.TestReport
+		found  bool
+	)
+
+	parsers := []struct {
+		pattern string
+		parse   func(string, *state.TestReport) error
+	}{
+		{pattern: reports.JUnit, parse: parseJUnitReport},
+		{pattern: reports.GoTest, parse: parseGoTestReport},
+	}
+
+	for _, parser := range parsers {
+		if parser.pattern == "" {
+			continue
+		}
+
+		pattern := parser.pattern
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(workDir, pattern)
+		}
+
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report pattern %q: %w", parser.pattern, err)
+		}
+
+		for _, path := range paths {
+			if err := parser.parse(path, &report); err != nil {
+				return nil, fmt.Errorf("failed to parse report %q: %w", path, err)
+			}
+			found = true
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return &report, nil
+}
+
+// addTestFailure records the failed test within the report, as long as the
+// report has not reached its failure limit.
+func addTestFailure(report *state.TestReport, failure *state.TestFailure) {
+	if len(report.Failures) >= reportMaxFailures {
+		return
+	}
+	if len(failure.Message) > reportMaxMessageSize {
+		failure.Message = failure.Message[:reportMaxMessageSize]
+	}
+	report.Failures = append(report.Failures, failure)
+}
+
+type junitSuites struct {
+	Suites []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name   string        `xml:"name,attr"`
+	Time   string        `xml:"time,attr"`
+	Suites []*junitSuite `xml:"testsuite"`
+	Cases  []*junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Error     *junitMessage `xml:"error"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (m *junitMessage) String() string {
+	return strings.TrimSpace(strings.Join([]string{m.Message, strings.TrimSpace(m.Text)}, "\n"))
+}
+
+// parseJUnitReport adds the results of the JUnit XML file to the report. The
+// root element can be either a testsuites or a single testsuite element.
+func parseJUnitReport(path string, report *state.TestReport) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+
+	var suites []*junitSuite
+
+	switch root.XMLName.Local {
+	case "testsuites":
+		var doc junitSuites
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		suites = doc.Suites
+	case "testsuite":
+		var suite junitSuite
+		if err := xml.Unmarshal(data, &suite); err != nil {
+			return err
+		}
+		suites = []*junitSuite{&suite}
+	default:
+		return fmt.Errorf("unexpected root element %q", root.XMLName.Local)
+	}
+
+	for _, suite := range suites {
+		addJUnitSuite(suite, report)
+	}
+	return nil
+}
+
+func addJUnitSuite(suite *junitSuite, report *state.TestReport) {
+
+	for _, nested := range suite.Suites {
+		addJUnitSuite(nested, report)
+	}
+
+	for _, tc := range suite.Cases {
+
+		duration := parseSeconds(tc.Time)
+
+		report.Total++
+		report.Duration += duration
+
+		failure := tc.Failure
+		if failure == nil {
+			failure = tc.Error
+		}
+
+		switch {
+		case failure != nil:
+			report.Failed++
+
+			suiteName := suite.Name
+			if tc.ClassName != "" {
+				suiteName = tc.ClassName
+			}
+
+			addTestFailure(report, &state.TestFailure{
+				Suite:    suiteName,
+				Name:     tc.Name,
+				Message:  failure.String(),
+				Duration: duration,
+			})
+		case tc.Skipped != nil:
+			report.Skipped++
+		default:
+			report.Passed++
+		}
+	}
+}
+
+func parseSeconds(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return f
+}
+
+// goTestEvent is a single event written by "go test -json".
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+// parseGoTestReport adds the results of the "go test -json" output file to the
+// report. Only events for individual tests are counted, and the output of each
+// failed test is used as its failure message. Tests with subtests are not
+// counted, as their result is that of the subtests, unless the test failed
+// without any subtest failing.
+func parseGoTestReport(path string, report *state.TestReport) error {
+
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fileHandle.Close()
+
+	type testKey struct{ pkg, test string }
+
+	var (
+		output = make(map[testKey]*strings.Builder)
+
+		// parents holds the tests which have subtests, and failedParents
+		// those with a failed subtest. Subtests always finish before their
+		// parent, so both are known once the parent finishes.
+		parents       = make(map[testKey]bool)
+		failedParents = make(map[testKey]bool)
+	)
+
+	// markParents sets the key of each test which is a parent of the test
+	// within the map.
+	markParents := func(m map[testKey]bool, key testKey) {
+		for i := strings.LastIndex(key.test, "/"); i > 0; i = strings.LastIndex(key.test[:i], "/") {
+			m[testKey{pkg: key.pkg, test: key.test[:i]}] = true
+		}
+	}
+
+	scanner := bufio.NewScanner(fileHandle)
+	scanner.Buffer(nil, 1024*1024)
+
+	for scanner.Scan() {
+
+		var event goTestEvent
+
+		// Build output and other non-JSON lines can be mixed into the file, so
+		// skip anything which is not a test event.
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Test == "" {
+			continue
+		}
+
+		key := testKey{pkg: event.Package, test: event.Test}
+		markParents(parents, key)
+
+		switch event.Action {
+		case "output":
+			buf, ok := output[key]
+			if !ok {
+				buf = &strings.Builder{}
+				output[key] = buf
+			}
+			if buf.Len() < reportMaxMessageSize {
+				buf.WriteString(event.Output)
+			}
+		case "pass":
+			if parents[key] {
+				delete(output, key)
+				continue
+			}
+			report.Total++
+			report.Passed++
+			report.Duration += event.Elapsed
+			delete(output, key)
+		case "skip":
+			if parents[key] {
+				delete(output, key)
+				continue
+			}
+			report.Total++
+			report.Skipped++
+			report.Duration += event.Elapsed
+			delete(output, key)
+		case "fail":
+			markParents(failedParents, key)
+
+			// The failure of a parent test is reported by its failed
+			// subtests.
+			if failedParents[key] {
+				delete(output, key)
+				continue
+			}
+
+			report.Total++
+			report.Failed++
+			report.Duration += event.Elapsed
+
+			var message string
+			if buf, ok := output[key]; ok {
+				message = strings.TrimSpace(buf.String())
+			}
+			delete(output, key)
+
+			addTestFailure(report, &state.TestFailure{
+				Suite:    event.Package,
+				Name:     event.Test,
+				Message:  message,
+				Duration: event.Elapsed,
+			})
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,97 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// rpcClient wraps the controller RPC client, transparently reconnecting once
+// the connection has been lost, such as when the controller is restarted.
+type rpcClient struct {
+	addr   string
+	logger *zap.Logger
+
+	lock   sync.Mutex
+	client *rpc.Client
+}
+
+func newRPCClient(logger *zap.Logger, addr string) (*rpcClient, error) {
+
+	c := rpcClient{
+		addr:   addr,
+		logger: logger,
+	}
+
+	if _, err := c.get(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Call performs the RPC call. Errors returned by the controller are passed
+// through, while connection errors cause the client to be reconnected on the
+// next call.
+func (c *rpcClient) Call(method string, args, reply any) error {
+
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+
+	err = client.Call(method, args, reply)
+
+	if err != nil && !isServerError(err) {
+		c.reset(client)
+	}
+
+	return err
+}
+
+func (c *rpcClient) get() (*rpc.Client, error) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	client, err := rpc.Dial("tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RPC client: %w", err)
+	}
+
+	c.logger.Debug("connected to controller RPC", zap.String("addr", c.addr))
+	c.client = client
+
+	return client, nil
+}
+
+// reset closes the passed client, if it is still the current one, so the next
+// call dials a new connection.
+func (c *rpcClient) reset(client *rpc.Client) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.client != client {
+		return
+	}
+
+	_ = c.client.Close()
+	c.client = nil
+
+	c.logger.Info("lost connection to controller RPC, will reconnect", zap.String("addr", c.addr))
+}
+
+// isServerError returns whether the error was returned by the controller RPC
+// handler, rather than being caused by the connection.
+func isServerError(err error) bool {
+	var serverErr rpc.ServerError
+	return errors.As(err, &serverErr)
+}
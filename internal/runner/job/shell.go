@@ -0,0 +1,101 @@
+package job
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// shellScriptPlaceholder is replaced by the step script path when found within
+// a custom shell definition. If the definition does not contain it, the script
+// path is appended as the final argument.
+const shellScriptPlaceholder = "{0}"
+
+// shellCommands maps the shell names supported out of the box to the command
+// used to execute the step script.
+var shellCommands = map[string][]string{
+	"bash":    {"bash", "--noprofile", "--norc", "-eo", "pipefail", shellScriptPlaceholder},
+	"sh":      {"sh", "-e", shellScriptPlaceholder},
+	"python":  {"python3", shellScriptPlaceholder},
+	"python3": {"python3", shellScriptPlaceholder},
+}
+
+// shellCommand returns the command and arguments used to execute the step
+// script. An empty shell keeps the original behaviour of running the script
+// with bash and no additional options.
+func shellCommand(shell, scriptPath string) ([]string, error) {
+
+	var args []string
+
+	switch cmd, ok := shellCommands[shell]; {
+	case shell == "":
+		args = []string{"bash", shellScriptPlaceholder}
+	case ok:
+		args = slices.Clone(cmd)
+	default:
+		args = strings.Fields(shell)
+		if len(args) == 0 {
+			return nil, errors.New("shell must not be blank")
+		}
+		if !slices.Contains(args, shellScriptPlaceholder) {
+			args = append(args, shellScriptPlaceholder)
+		}
+	}
+
+	for i, arg := range args {
+		if arg == shellScriptPlaceholder {
+			args[i] = scriptPath
+		}
+	}
+
+	return args, nil
+}
+
+// stepWorkingDir returns the directory the step is executed within. Relative
+// paths are resolved against the runner workspace.
+func stepWorkingDir(workDir, dir string) string {
+	if dir == "" {
+		return workDir
+	}
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(workDir, dir)
+}
+
+// mergeEnv merges the environment maps, with later maps taking precedence,
+// and returns the result in the KEY=value form expected by exec.Cmd. The
+// result is sorted so the environment is stable across runs.
+func mergeEnv(envs ...map[string]string) []string {
+
+	merged := make(map[string]string)
+
+	for _, env := range envs {
+		maps.Copy(merged, env)
+	}
+
+	out := make([]string, 0, len(merged))
+
+	for _, k := range slices.Sorted(maps.Keys(merged)) {
+		out = append(out, k+"="+merged[k])
+	}
+
+	return out
+}
+
+// osEnv returns the environment of the runner process as a map.
+func osEnv() map[string]string {
+
+	env := make(map[string]string)
+
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,384 @@
+package job
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	sharedrpc "github.com/hashicorp-forge/nomad-pipeline/internal/pkg/rpc"
+)
+
+const (
+	spoolBackoffMin = 1 * time.Second
+	spoolBackoffMax = 30 * time.Second
+
+	// spoolServerErrorAttempts is the number of times an entry rejected by the
+	// controller is retried before being dropped. Connection errors are always
+	// retried, as the controller may be restarting.
+	spoolServerErrorAttempts = 5
+
+	// spoolDrainTimeout is the maximum time the runner waits for unsent
+	// entries to be delivered before exiting.
+	spoolDrainTimeout = 5 * time.Minute
+
+	// spoolCompactMinEntries is the number of delivered entries the spool file
+	// must contain before it is compacted, unless the queue is empty. The file
+	// is also only compacted once at least half of its entries have been
+	// delivered, so the cost of compacting is proportional to the entries
+	// delivered since the last compaction.
+	spoolCompactMinEntries = 1000
+)
+
+// spoolEntry is a single RPC call waiting to be delivered to the controller.
+// Only one of the request fields is set, depending on the method.
+type spoolEntry struct {
+	Method    string                        `json:"method"`
+	JobUpdate *sharedrpc.RunnerJobUpdateReq `json:"job_update,omitempty"`
+	LogsBatch *sharedrpc.RunnerLogsBatchReq `json:"logs_batch,omitempty"`
+}
+
+// spool durably queues the job updates and log batches sent to the
+// controller. Entries are persisted to a local file and delivered in order by
+// a single worker, which retries with backoff, so a controller restart does
+// not leave holes in the run state or logs.
+//
+// The spool file is only appended to, and the number of entries at its start
+// which have been delivered is recorded in a separate cursor file. Delivered
+// entries are removed by periodically compacting the file, rather than after
+// each delivery, so draining a large backlog does not rewrite the file for
+// every entry.
+type spool struct {
+	path       string
+	cursorPath string
+	logger     *zap.Logger
+	rpc        *rpcClient
+
+	lock  sync.Mutex
+	queue []*spoolEntry
+
+	// acked is the number of entries at the start of the spool file which
+	// have been delivered. dirty is set when the file no longer matches the
+	// queue, such as after failing to append an entry, and forces the next
+	// compaction.
+	acked int
+	dirty bool
+
+	// logSequences tracks the last sequence number assigned to each step log
+	// stream.
+	logSequences map[string]uint64
+
+	notifyCh chan struct{}
+	drainCh  chan struct{}
+}
+
+func newSpool(logger *zap.Logger, rpc *rpcClient, path string) (*spool, error) {
+
+	s := spool{
+		path:         path,
+		cursorPath:   path + ".acked",
+		logger:       logger.Named("spool"),
+		rpc:          rpc,
+		logSequences: make(map[string]uint64),
+		notifyCh:     make(chan struct{}, 1),
+		drainCh:      make(chan struct{}),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	go s.run()
+
+	return &s, nil
+}
+
+// JobUpdate queues the run update for delivery.
+func (s *spool) JobUpdate(req *sharedrpc.RunnerJobUpdateReq) {
+	s.enqueue(&spoolEntry{Method: sharedrpc.RunnerJobUpdateMethodName, JobUpdate: req})
+}
+
+// LogsBatch assigns the batch the next sequence number of its log stream and
+// queues it for delivery.
+func (s *spool) LogsBatch(req *sharedrpc.RunnerLogsBatchReq) {
+
+	s.lock.Lock()
+	key := req.StepID + "/" + req.Type
+	s.logSequences[key]++
+	req.Sequence = s.logSequences[key]
+	s.lock.Unlock()
+
+	s.enqueue(&spoolEntry{Method: sharedrpc.RunnerLogsBatchMethodName, LogsBatch: req})
+}
+
+// Drain blocks until all queued entries have been delivered, or the timeout
+// elapses.
+func (s *spool) Drain(timeout time.Duration) error {
+
+	s.lock.Lock()
+	empty := len(s.queue) == 0
+	s.lock.Unlock()
+
+	if empty {
+		return nil
+	}
+
+	s.logger.Info("waiting for queued updates and logs to be delivered")
+
+	select {
+	case <-s.drainCh:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("timeout waiting for queued updates and logs to be delivered")
+	}
+}
+
+func (s *spool) enqueue(entry *spoolEntry) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.queue = append(s.queue, entry)
+
+	if err := s.appendFile(entry); err != nil {
+		s.logger.Error("failed to persist queued entry", zap.Error(err))
+		s.dirty = true
+	}
+
+	select {
+	case s.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *spool) run() {
+
+	backoff := spoolBackoffMin
+	var serverErrors int
+
+	for {
+		s.lock.Lock()
+		if len(s.queue) == 0 {
+			s.lock.Unlock()
+
+			select {
+			case s.drainCh <- struct{}{}:
+			case <-s.notifyCh:
+			}
+			continue
+		}
+		entry := s.queue[0]
+		s.lock.Unlock()
+
+		err := s.send(entry)
+
+		switch {
+		case err == nil:
+		case isServerError(err) && serverErrors+1 >= spoolServerErrorAttempts:
+			s.logger.Error("dropping entry rejected by controller",
+				zap.String("method", entry.Method), zap.Error(err))
+		default:
+			if isServerError(err) {
+				serverErrors++
+			}
+			s.logger.Error("failed to deliver entry, will retry",
+				zap.String("method", entry.Method), zap.Duration("backoff", backoff), zap.Error(err))
+
+			time.Sleep(backoff)
+			backoff = min(backoff*2, spoolBackoffMax)
+			continue
+		}
+
+		backoff = spoolBackoffMin
+		serverErrors = 0
+
+		s.lock.Lock()
+		s.queue = s.queue[1:]
+		s.acked++
+		if err := s.ack(); err != nil {
+			s.logger.Error("failed to persist queue", zap.Error(err))
+		}
+		s.lock.Unlock()
+	}
+}
+
+func (s *spool) send(entry *spoolEntry) error {
+	switch entry.Method {
+	case sharedrpc.RunnerJobUpdateMethodName:
+		return s.rpc.Call(entry.Method, entry.JobUpdate, nil)
+	case sharedrpc.RunnerLogsBatchMethodName:
+		return s.rpc.Call(entry.Method, entry.LogsBatch, nil)
+	default:
+		return fmt.Errorf("unknown method %q", entry.Method)
+	}
+}
+
+// load reads any entries persisted by a previous runner process, so they are
+// delivered before new entries. Entries which the previous process delivered
+// are skipped, but still restore the log sequence numbers.
+func (s *spool) load() error {
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	defer f.Close()
+
+	acked := s.readCursor()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	var lines int
+
+	for scanner.Scan() {
+		lines++
+
+		var entry spoolEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			s.logger.Error("skipping corrupt spool entry", zap.Error(err))
+			if lines > acked {
+				s.dirty = true
+			}
+			continue
+		}
+
+		if entry.LogsBatch != nil {
+			key := entry.LogsBatch.StepID + "/" + entry.LogsBatch.Type
+			s.logSequences[key] = max(s.logSequences[key], entry.LogsBatch.Sequence)
+		}
+
+		if lines > acked {
+			s.queue = append(s.queue, &entry)
+		}
+	}
+
+	s.acked = min(acked, lines)
+
+	if len(s.queue) > 0 {
+		s.logger.Info("loaded queued entries from spool file", zap.Int("num_entries", len(s.queue)))
+	}
+
+	return scanner.Err()
+}
+
+// readCursor returns the number of delivered entries recorded by the cursor
+// file. A missing or unreadable cursor returns zero, so all entries are
+// delivered again, which the controller tolerates.
+func (s *spool) readCursor() int {
+
+	data, err := os.ReadFile(s.cursorPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("failed to read spool cursor", zap.Error(err))
+		}
+		return 0
+	}
+
+	acked, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || acked < 0 {
+		s.logger.Error("ignoring invalid spool cursor", zap.String("cursor", string(data)))
+		return 0
+	}
+	return acked
+}
+
+func (s *spool) writeCursor(acked int) error {
+	return os.WriteFile(s.cursorPath, []byte(strconv.Itoa(acked)), 0644)
+}
+
+// ack persists the delivery of the first entry within the spool file,
+// compacting the file when enough of it has been delivered. The caller must
+// hold the lock.
+func (s *spool) ack() error {
+
+	if s.dirty || len(s.queue) == 0 || (s.acked >= spoolCompactMinEntries && s.acked >= len(s.queue)) {
+		err := s.compact()
+		if err == nil {
+			return nil
+		}
+
+		// The file may not match the queue, so leave the cursor as it was,
+		// which only causes entries to be delivered again.
+		if s.dirty {
+			return err
+		}
+		return errors.Join(err, s.writeCursor(s.acked))
+	}
+
+	return s.writeCursor(s.acked)
+}
+
+func (s *spool) appendFile(entry *spoolEntry) error {
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// compact replaces the spool file with the undelivered entries of the queue,
+// which is usually empty or very short.
+func (s *spool) compact() error {
+
+	tmpPath := s.path + ".tmp"
+
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+
+	for _, entry := range s.queue {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		_, _ = w.Write(append(data, '\n'))
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// Reset the cursor before replacing the file, so failing part way through
+	// causes delivered entries to be sent again, rather than undelivered
+	// entries to be skipped.
+	if err := s.writeCursor(0); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+
+	s.acked = 0
+	s.dirty = false
+
+	return nil
+}
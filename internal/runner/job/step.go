@@ -3,9 +3,13 @@ package job
 import (
 	stdcontext "context"
 	"fmt"
-	"net/rpc"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 
@@ -15,19 +19,32 @@ import (
 	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
 )
 
+// stepOutputCloseTimeout is the time to wait for the output of a step to close
+// once its process group has been sent SIGKILL.
+const stepOutputCloseTimeout = 10 * time.Second
+
 type stepRunner struct {
 	cfg         *host.RunConfig
+	workDir     string
+	envFile     string
+	summaryFile string
+	env         map[string]string
+	cancelCh    <-chan struct{}
+	gracePeriod time.Duration
 	context     *context.Context
 	logger      *zap.Logger
 	logHandlers []*LogHandler
-	rpcClient   *rpc.Client
+	commands    *stepCommands
+	spool       *spool
 }
 
 func (sr *stepRunner) executeStepRun(step *state.Step) (*state.InlineStep, error) {
 
+	scriptPath := filepath.Join(sr.workDir, step.ID)
+
 	sr.logger.Info("executing flow job step",
 		zap.String("flow_step_id", step.ID),
-		zap.String("flow_step_path", "./local/"+sr.cfg.ID.String()+"/"+step.ID),
+		zap.String("flow_step_path", scriptPath),
 	)
 
 	parsedExpr, err := sr.context.ParseTemplateStringExpr(step.Run)
@@ -35,12 +52,29 @@ func (sr *stepRunner) executeStepRun(step *state.Step) (*state.InlineStep, error
 		return nil, fmt.Errorf("could not process HCL expression for step run: %w", err)
 	}
 
-	if err := os.WriteFile("./local/"+sr.cfg.ID.String()+"/"+step.ID, []byte(parsedExpr), 0755); err != nil {
+	if err := os.WriteFile(scriptPath, []byte(parsedExpr), 0755); err != nil {
 		return nil, fmt.Errorf("could not write step script: %w", err)
 	}
 
-	cmd := exec.Command("bash", "./"+step.ID)
-	cmd.Dir = "./local/" + sr.cfg.ID.String()
+	absScriptPath, err := filepath.Abs(scriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve step script path: %w", err)
+	}
+
+	args, err := shellCommand(step.Shell, absScriptPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not process step shell: %w", err)
+	}
+
+	env, err := sr.stepEnv(step)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = stepWorkingDir(sr.workDir, step.WorkingDir)
+	cmd.Env = env
+	setProcessGroup(cmd)
 
 	ctx := stdcontext.Background()
 	defer ctx.Done()
@@ -49,66 +83,219 @@ func (sr *stepRunner) executeStepRun(step *state.Step) (*state.InlineStep, error
 		return nil, fmt.Errorf("failed to setup log handlers: %w", err)
 	}
 
+	var logHandlersWG sync.WaitGroup
+
 	for _, logHandler := range sr.logHandlers {
 		sr.logger.Debug("starting log handler", zap.String("log_type", logHandler.req.Type))
-		go logHandler.Start(ctx)
+		logHandlersWG.Add(1)
+		go func() {
+			defer logHandlersWG.Done()
+			logHandler.Start(ctx)
+		}()
 	}
 
+	outputDone := make(chan struct{})
+	go func() {
+		logHandlersWG.Wait()
+		close(outputDone)
+	}()
+
 	sr.context.StartInlineStep(step.ID)
-	req := sharedrpc.RunnerJobUpdateReq{JobID: sr.cfg.JobID, Run: sr.context.Run()}
-	if err := sr.rpcClient.Call(sharedrpc.RunnerJobUpdateMethodName, req, nil); err != nil {
-		sr.logger.Error("could not send job update RPC call", zap.Error(err))
-	} else {
-		sr.logger.Debug("sent job update RPC call for step start", zap.String("flow_step_id", step.ID))
-	}
+	sr.spool.JobUpdate(&sharedrpc.RunnerJobUpdateReq{JobID: sr.cfg.JobID, Run: sr.context.Run()})
 
-	if err := cmd.Run(); err != nil {
+	cancelled, err := sr.runCmd(cmd, step.ID, outputDone)
+	if err != nil {
 		fmt.Println("could not run command: ", err)
 	}
 
-	exitCode := cmd.ProcessState.ExitCode()
+	exitCode := processExitCode(cmd.ProcessState)
 
 	sr.logger.Info("execution of flow job step finished",
 		zap.String("flow_step_id", step.ID), zap.Int("exit_code", exitCode))
 
 	res := state.InlineStep{ID: step.ID, ExitCode: exitCode}
+	res.Annotations, res.Outputs = sr.commands.result()
 
-	if exitCode != 0 {
+	switch {
+	case cancelled:
+		res.Status = state.RunStatusCancelled
+	case exitCode != 0:
 		res.Status = state.RunStatusFailed
-	} else {
+	default:
 		res.Status = state.RunStatusSuccess
 	}
 
+	// Test reports are parsed whatever the result of the step, as failing
+	// tests are most likely the reason the step failed. A report which cannot
+	// be parsed is logged, but does not change the step result.
+	if !cancelled && step.Reports != nil {
+		tests, err := collectTestReport(cmd.Dir, step.Reports)
+		if err != nil {
+			sr.logger.Error("failed to collect step test reports",
+				zap.String("flow_step_id", step.ID), zap.Error(err))
+		}
+		res.Tests = tests
+	}
+
+	summary, err := readSummaryFile(sr.summaryFile)
+	if err != nil {
+		sr.logger.Error("failed to read step summary",
+			zap.String("flow_step_id", step.ID), zap.Error(err))
+	}
+	res.Summary = summary
+
 	return &res, nil
 }
 
+// runCmd runs the step command until it exits, and the log handlers have read
+// all of its output once outputDone is closed. If the run is cancelled while
+// the command is running, the process group is sent SIGTERM and then SIGKILL
+// if it has not exited once the grace period elapses. It returns whether the
+// command was cancelled.
+func (sr *stepRunner) runCmd(cmd *exec.Cmd, stepID string, outputDone <-chan struct{}) (bool, error) {
+
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+
+	// Wait closes the output pipes, so the log handlers must read them to EOF
+	// before it is called. Otherwise the end of the output, including any
+	// workflow commands it contains, could be lost.
+	waitCh := make(chan error, 1)
+	go func() {
+		<-outputDone
+		waitCh <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-waitCh:
+		return false, err
+	case <-sr.cancelCh:
+	}
+
+	sr.logger.Info("sending SIGTERM to cancelled flow job step",
+		zap.String("flow_step_id", stepID), zap.Duration("grace_period", sr.gracePeriod))
+
+	if err := signalProcessGroup(cmd, syscall.SIGTERM); err != nil {
+		sr.logger.Error("failed to send SIGTERM to step", zap.String("flow_step_id", stepID), zap.Error(err))
+	}
+
+	timer := time.NewTimer(sr.gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case err := <-waitCh:
+		return true, err
+	case <-timer.C:
+	}
+
+	sr.logger.Info("grace period elapsed, sending SIGKILL to cancelled flow job step",
+		zap.String("flow_step_id", stepID))
+
+	if err := signalProcessGroup(cmd, syscall.SIGKILL); err != nil {
+		sr.logger.Error("failed to send SIGKILL to step", zap.String("flow_step_id", stepID), zap.Error(err))
+	}
+
+	timer.Reset(stepOutputCloseTimeout)
+
+	select {
+	case err := <-waitCh:
+		return true, err
+	case <-timer.C:
+	}
+
+	// Processes which left the process group are not killed, and can hold the
+	// output pipes open, so stop reading them rather than waiting forever.
+	sr.logger.Warn("output of cancelled flow job step still open, closing it",
+		zap.String("flow_step_id", stepID))
+
+	for _, logHandler := range sr.logHandlers {
+		_ = logHandler.cmdPipe.Close()
+	}
+
+	return true, <-waitCh
+}
+
+// stepEnv builds the environment for the step process. The runner environment
+// is overlaid with the flow environment, variables exported by previous steps,
+// and then the step environment. Flow and step values are processed as HCL
+// template expressions.
+func (sr *stepRunner) stepEnv(step *state.Step) ([]string, error) {
+
+	var flowEnv map[string]string
+	if sr.cfg.Flow.Inline != nil {
+		flowEnv = sr.cfg.Flow.Inline.Env
+	}
+
+	parsedFlowEnv, err := sr.parseEnv(flowEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedStepEnv, err := sr.parseEnv(step.Env)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeEnv(
+		osEnv(),
+		parsedFlowEnv,
+		sr.env,
+		parsedStepEnv,
+		map[string]string{envFileVar: sr.envFile, summaryFileVar: sr.summaryFile},
+	), nil
+}
+
+func (sr *stepRunner) parseEnv(env map[string]string) (map[string]string, error) {
+
+	parsed := make(map[string]string, len(env))
+
+	for k, v := range env {
+		val, err := sr.context.ParseTemplateStringExpr(v)
+		if err != nil {
+			return nil, fmt.Errorf("could not process HCL expression for env %q: %w", k, err)
+		}
+		parsed[k] = val
+	}
+
+	return parsed, nil
+}
+
 func (sr *stepRunner) setupLogHandlers(cmd *exec.Cmd, stepID string) error {
 
+	var seq atomic.Uint64
+
+	sr.commands = newStepCommands()
+
 	stderrReq := LogHandlerReq{
 		Namespace: sr.cfg.Namespace,
 		RunID:     sr.cfg.ID.String(),
 		StepID:    stepID,
-		Type:      "stderr",
+		Type:      state.LogStreamStderr,
+		Sequence:  &seq,
+		Commands:  sr.commands,
 	}
 
 	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
 		return fmt.Errorf("could not get stderr pipe: %w", err)
 	}
-	sr.logHandlers = append(sr.logHandlers, NewLogHandler(sr.logger, stderrPipe, sr.rpcClient, &stderrReq))
+	sr.logHandlers = append(sr.logHandlers, NewLogHandler(sr.logger, stderrPipe, sr.spool, &stderrReq))
 
 	stdoutReq := LogHandlerReq{
 		RunID:     sr.cfg.ID.String(),
 		Namespace: sr.cfg.Namespace,
 		StepID:    stepID,
-		Type:      "stdout",
+		Type:      state.LogStreamStdout,
+		Sequence:  &seq,
+		Commands:  sr.commands,
 	}
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("could not get stdout pipe: %w", err)
 	}
-	sr.logHandlers = append(sr.logHandlers, NewLogHandler(sr.logger, stdoutPipe, sr.rpcClient, &stdoutReq))
+	sr.logHandlers = append(sr.logHandlers, NewLogHandler(sr.logger, stdoutPipe, sr.spool, &stdoutReq))
 
 	return nil
 }
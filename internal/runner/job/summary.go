@@ -0,0 +1,65 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// summaryFileDir is the directory, within the runner data directory, where
+	// the summary file of each step is created.
+	summaryFileDir = "summary"
+
+	// summaryFileVar is the environment variable which holds the path of the
+	// summary file for the running step. Markdown written to the file is
+	// stored with the step result once it has finished.
+	summaryFileVar = "NOMAD_PIPELINE_STEP_SUMMARY"
+
+	// summaryMaxSize is the maximum size, in bytes, of a step summary. Larger
+	// summaries are truncated.
+	summaryMaxSize = 64 * 1024
+)
+
+// createSummaryFile creates an empty summary file for the step and returns its
+// absolute path.
+func (r *Runner) createSummaryFile(stepID string) (string, error) {
+
+	if err := os.MkdirAll(filepath.Join(r.dataDir, summaryFileDir), 0755); err != nil {
+		return "", fmt.Errorf("failed to create summary file directory: %w", err)
+	}
+
+	path, err := filepath.Abs(filepath.Join(r.dataDir, summaryFileDir, stepID))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve summary file path: %w", err)
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		return "", fmt.Errorf("failed to create summary file: %w", err)
+	}
+
+	return path, nil
+}
+
+// readSummaryFile returns the markdown summary written by the step. A missing
+// file is not an error, as the step may have removed it.
+func readSummaryFile(path string) (string, error) {
+
+	fileHandle, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to open summary file: %w", err)
+	}
+	defer fileHandle.Close()
+
+	data, err := io.ReadAll(io.LimitReader(fileHandle, summaryMaxSize))
+	if err != nil {
+		return "", fmt.Errorf("failed to read summary file: %w", err)
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,194 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/hashicorp-forge/nomad-pipeline/internal/pkg/state"
+)
+
+const (
+	// stepResultDir is the directory, within the runner data directory, where
+	// runner tasks write the result of the steps they own. Other runner tasks
+	// watch this directory to learn about steps they do not execute.
+	stepResultDir = "steps"
+
+	// stepResultAbortFile is written by any runner task which fails to
+	// complete the run, so that other tasks do not wait forever for results
+	// that will never arrive.
+	stepResultAbortFile = "abort"
+
+	stepResultPollInterval = 1 * time.Second
+
+	// stepHeartbeatSuffix is the suffix of the file, within the step result
+	// directory, each runner task periodically writes while it is running. A
+	// task which crashes or is killed cannot write the abort file, so tasks
+	// waiting on its step results watch the heartbeat instead.
+	stepHeartbeatSuffix = ".heartbeat"
+
+	// stepHeartbeatInterval is how often each runner task writes its
+	// heartbeat, and stepHeartbeatTimeout the age after which the task is
+	// considered to have stopped.
+	stepHeartbeatInterval = 10 * time.Second
+	stepHeartbeatTimeout  = 1 * time.Minute
+
+	// stepTaskStartTimeout is the maximum time to wait for the runner task
+	// owning a step to write its first heartbeat. Tasks start independently,
+	// and may be delayed by pulling their image.
+	stepTaskStartTimeout = 15 * time.Minute
+)
+
+func (r *Runner) stepResultPath(stepID string) string {
+	return filepath.Join(r.dataDir, stepResultDir, stepID+".json")
+}
+
+// writeStepResult persists the current step context, so runner tasks which do
+// not own the step can synchronise their own context. It is a noop when the
+// run is not split across multiple tasks.
+func (r *Runner) writeStepResult(stepID string) error {
+
+	if len(r.cfg.StepTasks) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(r.context.InlineStep(stepID))
+	if err != nil {
+		return fmt.Errorf("failed to marshal step result: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(r.dataDir, stepResultDir), 0755); err != nil {
+		return fmt.Errorf("failed to create step result directory: %w", err)
+	}
+
+	// Write to a temporary file and rename it, so that readers never observe
+	// a partially written result.
+	tmpPath := r.stepResultPath(stepID) + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write step result: %w", err)
+	}
+
+	return os.Rename(tmpPath, r.stepResultPath(stepID))
+}
+
+func (r *Runner) stepHeartbeatPath(task string) string {
+	return filepath.Join(r.dataDir, stepResultDir, task+stepHeartbeatSuffix)
+}
+
+// waitStepResult blocks until the runner task owning the step has written its
+// result. It returns an error if the run is aborted, or the owning task stops
+// writing its heartbeat.
+func (r *Runner) waitStepResult(stepID string) (*state.InlineStep, error) {
+
+	task := r.cfg.StepTasks[stepID]
+	start := time.Now()
+
+	r.logger.Info("waiting for step result from runner task",
+		zap.String("step_id", stepID),
+		zap.String("task", task),
+	)
+
+	ticker := time.NewTicker(stepResultPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+
+		data, err := os.ReadFile(r.stepResultPath(stepID))
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("failed to read step result: %w", err)
+			}
+			if _, err := os.Stat(filepath.Join(r.dataDir, stepResultDir, stepResultAbortFile)); err == nil {
+				return nil, errors.New("run aborted by another runner task")
+			}
+			if err := r.checkStepTask(task, start); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var step state.InlineStep
+
+		if err := json.Unmarshal(data, &step); err != nil {
+			return nil, fmt.Errorf("failed to decode step result: %w", err)
+		}
+		return &step, nil
+	}
+
+	return nil, errors.New("step result ticker stopped")
+}
+
+// checkStepTask returns an error if the runner task has stopped writing its
+// heartbeat, or has not written one within the start timeout of the wait.
+func (r *Runner) checkStepTask(task string, waitStart time.Time) error {
+
+	info, err := os.Stat(r.stepHeartbeatPath(task))
+	switch {
+	case err == nil:
+		if age := time.Since(info.ModTime()); age > stepHeartbeatTimeout {
+			return fmt.Errorf("runner task %s stopped responding %s ago", task, age.Round(time.Second))
+		}
+	case errors.Is(err, os.ErrNotExist):
+		if time.Since(waitStart) > stepTaskStartTimeout {
+			return fmt.Errorf("runner task %s did not start within %s", task, stepTaskStartTimeout)
+		}
+	default:
+		return fmt.Errorf("failed to read runner task heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+// heartbeat periodically writes the heartbeat of the runner task until done is
+// closed, so other runner tasks can detect when it stops. It is a noop when
+// the run is not split across multiple tasks.
+func (r *Runner) heartbeat(done <-chan struct{}) {
+
+	if len(r.cfg.StepTasks) == 0 {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Join(r.dataDir, stepResultDir), 0755); err != nil {
+		r.logger.Error("failed to create step result directory", zap.Error(err))
+		return
+	}
+
+	ticker := time.NewTicker(stepHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := os.WriteFile(r.stepHeartbeatPath(r.cfg.Task), nil, 0644); err != nil {
+			r.logger.Warn("failed to write runner task heartbeat", zap.Error(err))
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// abortRun notifies any other runner tasks that this task is unable to
+// continue the run.
+func (r *Runner) abortRun() {
+
+	if len(r.cfg.StepTasks) == 0 {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Join(r.dataDir, stepResultDir), 0755); err != nil {
+		r.logger.Error("failed to create step result directory", zap.Error(err))
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(r.dataDir, stepResultDir, stepResultAbortFile), nil, 0644); err != nil {
+		r.logger.Error("failed to write run abort marker", zap.Error(err))
+	}
+}
@@ -23,14 +23,40 @@ type Flow struct {
 
 	Variables []*FlowVariable `hcl:"variable,block" json:"variable"`
 
+	Workspace *FlowWorkspace `hcl:"workspace,block" json:"workspace"`
+
 	Inline        *InlineFlow          `hcl:"inline,block" json:"inline"`
 	Specification []*SpecificationFlow `hcl:"specification,block" json:"specification"`
+
+	OnSuccess *FlowHook `hcl:"on_success,block" json:"on_success"`
+	OnFailure *FlowHook `hcl:"on_failure,block" json:"on_failure"`
+	Finally   *FlowHook `hcl:"finally,block" json:"finally"`
+}
+
+type FlowHook struct {
+	Steps         []*Step              `hcl:"step,block" json:"step"`
+	Specification []*SpecificationFlow `hcl:"specification,block" json:"specification"`
 }
 
 type InlineFlow struct {
 	ID     string      `hcl:"id,label" json:"id"`
 	Runner *FlowRunner `hcl:"runner,block" json:"runner"`
 	Steps  []*Step     `hcl:"step,block" json:"step"`
+
+	Env     map[string]string `json:"env"`
+	EnvExpr hcl.Expression    `hcl:"env,optional"`
+
+	CancelGracePeriod string `hcl:"cancel_grace_period,optional" json:"cancel_grace_period"`
+}
+
+type FlowWorkspace struct {
+	Namespace   string            `hcl:"namespace,optional" json:"namespace"`
+	PluginID    string            `hcl:"plugin_id,optional" json:"plugin_id"`
+	NodePool    string            `hcl:"node_pool,optional" json:"node_pool"`
+	CapacityMin int               `hcl:"capacity_min,optional" json:"capacity_min"`
+	CapacityMax int               `hcl:"capacity_max,optional" json:"capacity_max"`
+	MountPath   string            `hcl:"mount_path,optional" json:"mount_path"`
+	Parameters  map[string]string `hcl:"parameters,optional" json:"parameters"`
 }
 
 type SpecificationFlow struct {
@@ -74,8 +100,24 @@ type JobSpecification struct {
 type Step struct {
 	ID        string         `hcl:"id,label" json:"id"`
 	Condition string         `hcl:"condition,optional" json:"condition"`
+	Image     string         `hcl:"image,optional" json:"image"`
 	Run       string         `json:"run"`
 	RunExpr   hcl.Expression `hcl:"run,optional"`
+
+	Env        map[string]string `json:"env"`
+	EnvExpr    hcl.Expression    `hcl:"env,optional"`
+	WorkingDir string            `hcl:"working_dir,optional" json:"working_dir"`
+	Shell      string            `hcl:"shell,optional" json:"shell"`
+
+	Reports *StepReports `hcl:"reports,block" json:"reports,omitempty"`
+}
+
+// StepReports configures the test report files the runner parses once the
+// step has finished. Each is a glob pattern relative to the step working
+// directory.
+type StepReports struct {
+	JUnit  string `hcl:"junit,optional" json:"junit,omitempty"`
+	GoTest string `hcl:"go_test,optional" json:"go_test,omitempty"`
 }
 
 type FlowVariable struct {
@@ -100,6 +142,28 @@ func (f *Flow) Type() string {
 	return FlowTypeUnknown
 }
 
+// allStepsAndSpecifications returns the inline steps and specifications of
+// the flow, including those defined within the flow hooks.
+func (f *Flow) allStepsAndSpecifications() ([]*Step, []*SpecificationFlow) {
+
+	var steps []*Step
+
+	if f.Inline != nil {
+		steps = append(steps, f.Inline.Steps...)
+	}
+
+	specs := append([]*SpecificationFlow{}, f.Specification...)
+
+	for _, hook := range []*FlowHook{f.OnSuccess, f.OnFailure, f.Finally} {
+		if hook != nil {
+			steps = append(steps, hook.Steps...)
+			specs = append(specs, hook.Specification...)
+		}
+	}
+
+	return steps, specs
+}
+
 type FlowStub struct {
 	ID        string `json:"id"`
 	Namespace string `json:"namespace"`
@@ -163,25 +227,32 @@ func ParseFlowFile(path string) (*Flow, error) {
 			}
 		}
 
-		switch decodeObj.Flow.Type() {
-		case FlowTypeInline:
-			for _, step := range decodeObj.Flow.Inline.Steps {
-				step.postDecodeProcessing(data)
+		if decodeObj.Flow.Inline != nil {
+			if err := decodeObj.Flow.Inline.postDecodeProcessing(data); err != nil {
+				return nil, fmt.Errorf("failed to decode inline %q: %w", decodeObj.Flow.Inline.ID, err)
 			}
-		case FlowTypeSpecification:
-			for _, spec := range decodeObj.Flow.Specification {
-				if spec.Job.Raw == "" && spec.Job.Path != "" {
-					jobData, err := os.ReadFile(spec.Job.Path)
-					if err != nil {
-						return nil, fmt.Errorf("failed to read job specification file %q: %w",
-							spec.Job.Path, err)
-					}
-					spec.Job.Raw = string(jobData)
-				}
+		}
+
+		steps, specs := decodeObj.Flow.allStepsAndSpecifications()
+
+		for _, step := range steps {
+			if err := step.postDecodeProcessing(data); err != nil {
+				return nil, fmt.Errorf("failed to decode step %q: %w", step.ID, err)
+			}
+		}
 
-				if err := spec.Job.postDecodeProcessing(srcData); err != nil {
-					return nil, fmt.Errorf("failed to decode job specification for %q: %w", spec.ID, err)
+		for _, spec := range specs {
+			if spec.Job.Raw == "" && spec.Job.Path != "" {
+				jobData, err := os.ReadFile(spec.Job.Path)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read job specification file %q: %w",
+						spec.Job.Path, err)
 				}
+				spec.Job.Raw = string(jobData)
+			}
+
+			if err := spec.Job.postDecodeProcessing(srcData); err != nil {
+				return nil, fmt.Errorf("failed to decode job specification for %q: %w", spec.ID, err)
 			}
 		}
 	default:
@@ -359,13 +430,32 @@ func (j *JobSpecification) postDecodeProcessing(src []byte) error {
 	return nil
 }
 
-func (s *Step) postDecodeProcessing(src []byte) {
+func (i *InlineFlow) postDecodeProcessing(src []byte) error {
+	if i.EnvExpr != nil {
+		env, err := extractRawMap(i.EnvExpr, src)
+		if err != nil {
+			return fmt.Errorf("failed to decode env: %w", err)
+		}
+		i.Env = env
+	}
+	return nil
+}
+
+func (s *Step) postDecodeProcessing(src []byte) error {
 	if s.RunExpr != nil {
 		rng := s.RunExpr.Range()
 		if src != nil {
 			s.Run = removeEOHMarkers(string(extractBytes(src, rng)))
 		}
 	}
+	if s.EnvExpr != nil {
+		env, err := extractRawMap(s.EnvExpr, src)
+		if err != nil {
+			return fmt.Errorf("failed to decode env: %w", err)
+		}
+		s.Env = env
+	}
+	return nil
 }
 
 func (f *FlowRunnerArtifact) postDecodeProcessing(src []byte) error {
@@ -388,38 +478,55 @@ func (f *FlowRunnerArtifact) postDecodeProcessing(src []byte) error {
 		return nil
 	}
 
-	// Parse the options expression to extract map entries
-	// For a map like {ref = "${github.ref}"}, we need to parse it
-	expr := optionsAttr.Expr
+	options, err := extractRawMap(optionsAttr.Expr, src)
+	if err != nil {
+		return err
+	}
+	if options != nil {
+		f.Options = options
+	}
+
+	return nil
+}
 
-	// Try to get the variables from the expression without evaluating
-	if syntaxExpr, ok := expr.(*hclsyntax.ObjectConsExpr); ok {
-		if f.Options == nil {
-			f.Options = make(map[string]string)
-		}
+// extractRawMap extracts the entries of a map expression, such as
+// {ref = "${github.ref}"}, without evaluating the values. This allows the
+// values to include template expressions which are evaluated at run time.
+func extractRawMap(expr hcl.Expression, src []byte) (map[string]string, error) {
 
-		for _, item := range syntaxExpr.Items {
-			// Get the key
-			keyVal, diags := item.KeyExpr.Value(nil)
-			if diags.HasErrors() {
-				continue
-			}
-			key := keyVal.AsString()
+	// An omitted optional attribute is decoded as a null expression.
+	if val, diags := expr.Value(nil); !diags.HasErrors() && val.IsNull() {
+		return nil, nil
+	}
 
-			// Get the raw source for the value expression
-			valueRng := item.ValueExpr.Range()
-			value := string(extractBytes(src, valueRng))
+	syntaxExpr, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return nil, fmt.Errorf("expected a map at %s", expr.Range())
+	}
 
-			// Remove quotes if it's a quoted string
-			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
-				value = value[1 : len(value)-1]
-			}
+	out := make(map[string]string)
 
-			f.Options[key] = value
+	for _, item := range syntaxExpr.Items {
+		// Get the key
+		keyVal, diags := item.KeyExpr.Value(nil)
+		if diags.HasErrors() {
+			continue
 		}
+		key := keyVal.AsString()
+
+		// Get the raw source for the value expression
+		valueRng := item.ValueExpr.Range()
+		value := string(extractBytes(src, valueRng))
+
+		// Remove quotes if it's a quoted string
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+
+		out[key] = value
 	}
 
-	return nil
+	return out, nil
 }
 
 // extractBytes extracts bytes from source given an HCL range
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// LogMatch is a step log line which matched a log search.
+type LogMatch struct {
+	RunID      string `json:"run_id"`
+	StepID     string `json:"step_id"`
+	LineNumber int    `json:"line_number"`
+
+	*LogLine
+
+	Before []*LogLine `json:"before,omitempty"`
+	After  []*LogLine `json:"after,omitempty"`
+}
+
+// LogsSearchQuery holds the search options shared by the run and namespace
+// log searches.
+type LogsSearchQuery struct {
+	Query   string `json:"q"`
+	Regex   bool   `json:"regex"`
+	Context int    `json:"context"`
+	Limit   int    `json:"limit"`
+	Type    string `json:"type"`
+}
+
+func (q *LogsSearchQuery) setParams(v url.Values) {
+	v.Set("q", q.Query)
+	if q.Regex {
+		v.Set("regex", "true")
+	}
+	if q.Context > 0 {
+		v.Set("context", strconv.Itoa(q.Context))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Type != "" {
+		v.Set("type", q.Type)
+	}
+}
+
+type LogsSearchResp struct {
+	Matches   []*LogMatch `json:"matches"`
+	Truncated bool        `json:"truncated"`
+}
+
+type Logs struct {
+	client *Client
+}
+
+func (c *Client) Logs() *Logs {
+	return &Logs{client: c}
+}
+
+type LogsSearchReq struct {
+	LogsSearchQuery
+
+	// FlowID, Since and Until filter the runs which are searched.
+	FlowID string    `json:"flow"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+// Search searches the logs of all runs within the namespace, newest first.
+func (l *Logs) Search(ctx context.Context, req *LogsSearchReq) (*LogsSearchResp, *Response, error) {
+	var resp LogsSearchResp
+
+	httpReq, err := l.client.NewRequest(
+		http.MethodGet,
+		"/v1/logs/search",
+		nil,
+		func(r *http.Request) {
+			q := r.URL.Query()
+			req.setParams(q)
+			if req.FlowID != "" {
+				q.Set("flow", req.FlowID)
+			}
+			setLogsTimeParams(q, req.Since, req.Until)
+			r.URL.RawQuery = q.Encode()
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := l.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
+
+type RunLogsSearchReq struct {
+	LogsSearchQuery
+
+	ID ulid.ULID `json:"id"`
+
+	// StepID restricts the search to a single step, when set.
+	StepID string `json:"step_id"`
+
+	// Since and Until filter the searched lines by their capture timestamp.
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+// LogsSearch searches the logs of the run steps.
+func (r *Runs) LogsSearch(ctx context.Context, req *RunLogsSearchReq) (*LogsSearchResp, *Response, error) {
+	var resp LogsSearchResp
+
+	httpReq, err := r.client.NewRequest(
+		http.MethodGet,
+		"/v1/runs/"+req.ID.String()+"/logs/search",
+		nil,
+		func(r *http.Request) {
+			q := r.URL.Query()
+			req.setParams(q)
+			if req.StepID != "" {
+				q.Set("step_id", req.StepID)
+			}
+			setLogsTimeParams(q, req.Since, req.Until)
+			r.URL.RawQuery = q.Encode()
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := r.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
@@ -3,7 +3,9 @@ package api
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/oklog/ulid/v2"
@@ -27,9 +29,10 @@ type Run struct {
 	Status    string    `json:"status"`
 	Trigger   string    `json:"trigger"`
 
-	CreateTime time.Time `json:"create_time"`
-	StartTime  time.Time `json:"start_time"`
-	EndTime    time.Time `json:"end_time"`
+	TriggerTime time.Time `json:"trigger_time"`
+	CreateTime  time.Time `json:"create_time"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
 
 	Variables map[string]any `json:"variables"`
 
@@ -48,6 +51,48 @@ type RunJobInline struct {
 	ExitCode  int       `json:"exit_code"`
 	StartTime time.Time `json:"start_time"`
 	EndTime   time.Time `json:"end_time"`
+
+	Tests       *TestReport       `json:"tests,omitempty"`
+	Annotations []*Annotation     `json:"annotations,omitempty"`
+	Outputs     map[string]string `json:"outputs,omitempty"`
+	Summary     string            `json:"summary,omitempty"`
+}
+
+const (
+	AnnotationLevelError   = "error"
+	AnnotationLevelWarning = "warning"
+	AnnotationLevelNotice  = "notice"
+)
+
+// Annotation is a message about a step, set using a workflow command written
+// to the step output.
+type Annotation struct {
+	Level     string `json:"level"`
+	Title     string `json:"title,omitempty"`
+	Message   string `json:"message"`
+	File      string `json:"file,omitempty"`
+	Line      int    `json:"line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Column    int    `json:"column,omitempty"`
+	EndColumn int    `json:"end_column,omitempty"`
+}
+
+// TestReport summarises the test results parsed from the reports of a step.
+// Durations are in seconds.
+type TestReport struct {
+	Total    int            `json:"total"`
+	Passed   int            `json:"passed"`
+	Failed   int            `json:"failed"`
+	Skipped  int            `json:"skipped"`
+	Duration float64        `json:"duration"`
+	Failures []*TestFailure `json:"failures,omitempty"`
+}
+
+type TestFailure struct {
+	Suite    string  `json:"suite"`
+	Name     string  `json:"name"`
+	Message  string  `json:"message"`
+	Duration float64 `json:"duration"`
 }
 
 type SpecRun struct {
@@ -127,6 +172,38 @@ func (r *Runs) Get(ctx context.Context, req *RunGetReq) (*RunGetResp, *Response,
 	return &resp, httpResp, nil
 }
 
+type RunTestsReq struct {
+	ID ulid.ULID `json:"id"`
+}
+
+type RunTestsResp struct {
+	Summary *TestReport       `json:"summary"`
+	Steps   []*StepTestReport `json:"steps"`
+}
+
+// StepTestReport is the test report of a single step within a run.
+type StepTestReport struct {
+	StepID string `json:"step_id"`
+	*TestReport
+}
+
+func (r *Runs) Tests(ctx context.Context, req *RunTestsReq) (*RunTestsResp, *Response, error) {
+
+	var resp RunTestsResp
+
+	httpReq, err := r.client.NewRequest(http.MethodGet, "/v1/runs/"+req.ID.String()+"/tests", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := r.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
+
 type RunListReq struct{}
 
 type RunListResp struct {
@@ -150,15 +227,38 @@ func (r *Runs) List(ctx context.Context, req *RunListReq) (*RunListResp, *Respon
 	return &resp, httpResp, nil
 }
 
+const (
+	LogStreamStdout   = "stdout"
+	LogStreamStderr   = "stderr"
+	LogStreamCombined = "combined"
+)
+
+// LogLine is a single line of output captured from a step.
+type LogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Stream    string    `json:"stream"`
+	Sequence  uint64    `json:"sequence"`
+	Text      string    `json:"text"`
+	Group     string    `json:"group,omitempty"`
+
+	// StepID is only set on lines streamed from a whole run.
+	StepID string `json:"step_id,omitempty"`
+}
+
 type RunLogsGetReq struct {
 	ID     ulid.ULID `json:"id"`
 	JobID  string    `json:"job_id"`
 	StepID string    `json:"step_id"`
 	Type   string    `json:"type"`
+
+	// Since and Until filter the returned lines by their capture timestamp,
+	// and are ignored when zero.
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
 }
 
 type RunLogsGetResp struct {
-	Logs []string `json:"logs"`
+	Logs []*LogLine `json:"logs"`
 }
 
 func (r *Runs) LogsGet(ctx context.Context, req *RunLogsGetReq) (*RunLogsGetResp, *Response, error) {
@@ -174,6 +274,7 @@ func (r *Runs) LogsGet(ctx context.Context, req *RunLogsGetReq) (*RunLogsGetResp
 			q.Set("step_id", req.StepID)
 			q.Set("type", req.Type)
 			q.Set("tail", "false")
+			setLogsTimeParams(q, req.Since, req.Until)
 			r.URL.RawQuery = q.Encode()
 		},
 	)
@@ -193,10 +294,12 @@ type RunLogsTailReq struct {
 	ID     ulid.ULID `json:"id"`
 	StepID string    `json:"step_id"`
 	Type   string    `json:"type"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
 }
 
 type RunLogsTailResp struct {
-	LogCh chan string
+	LogCh chan *LogLine
 	ErrCh chan error
 }
 
@@ -212,6 +315,7 @@ func (r *Runs) LogsTail(ctx context.Context, req *RunLogsTailReq) (*RunLogsTailR
 			q.Set("step_id", req.StepID)
 			q.Set("type", req.Type)
 			q.Set("tail", "true")
+			setLogsTimeParams(q, req.Since, req.Until)
 			r.URL.RawQuery = q.Encode()
 		},
 	)
@@ -224,7 +328,7 @@ func (r *Runs) LogsTail(ctx context.Context, req *RunLogsTailReq) (*RunLogsTailR
 		return nil, httpResp, err
 	}
 
-	resp.LogCh = make(chan string, 10)
+	resp.LogCh = make(chan *LogLine, 10)
 	resp.ErrCh = make(chan error)
 
 	go func() {
@@ -241,10 +345,98 @@ func (r *Runs) LogsTail(ctx context.Context, req *RunLogsTailReq) (*RunLogsTailR
 			}
 
 			for scanner.Scan() {
-				resp.LogCh <- scanner.Text()
+				var line LogLine
+				if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+					resp.ErrCh <- err
+					return
+				}
+				resp.LogCh <- &line
+			}
+		}
+	}()
+
+	return &resp, httpResp, nil
+}
+
+type RunLogsFollowReq struct {
+	ID    ulid.ULID `json:"id"`
+	Type  string    `json:"type"`
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+}
+
+type RunLogsFollowResp struct {
+	// LogCh receives the lines of every step in execution order, and is closed
+	// once the run is terminal and all lines have been received.
+	LogCh chan *LogLine
+	ErrCh chan error
+}
+
+// LogsFollow streams the logs of all steps within the run, until the run
+// reaches a terminal status.
+func (r *Runs) LogsFollow(ctx context.Context, req *RunLogsFollowReq) (*RunLogsFollowResp, *Response, error) {
+	var resp RunLogsFollowResp
+
+	httpReq, err := r.client.NewRequest(
+		http.MethodGet,
+		"/v1/runs/"+req.ID.String()+"/logs",
+		nil,
+		func(r *http.Request) {
+			q := r.URL.Query()
+			if req.Type != "" {
+				q.Set("type", req.Type)
+			}
+			q.Set("follow", "true")
+			setLogsTimeParams(q, req.Since, req.Until)
+			r.URL.RawQuery = q.Encode()
+		},
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := r.client.bareDo(ctx, httpReq)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	resp.LogCh = make(chan *LogLine, 10)
+	resp.ErrCh = make(chan error, 1)
+
+	go func() {
+
+		defer helper.IgnoreError(httpResp.Body.Close)
+		defer close(resp.LogCh)
+
+		scanner := bufio.NewScanner(httpResp.Body)
+
+		for scanner.Scan() {
+			var line LogLine
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				resp.ErrCh <- err
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case resp.LogCh <- &line:
 			}
 		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			resp.ErrCh <- err
+		}
 	}()
 
 	return &resp, httpResp, nil
 }
+
+func setLogsTimeParams(q url.Values, since, until time.Time) {
+	if !since.IsZero() {
+		q.Set("since", since.Format(time.RFC3339Nano))
+	}
+	if !until.IsZero() {
+		q.Set("until", until.Format(time.RFC3339Nano))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
@@ -17,6 +18,11 @@ type Trigger struct {
 	Flow      string `hcl:"flow" json:"flow"`
 
 	Source *TriggerSource `hcl:"source,block" json:"source"`
+
+	// Paused and LastFireTime are managed by the controller, and are ignored
+	// when creating or updating the trigger.
+	Paused       bool      `json:"paused,omitempty"`
+	LastFireTime time.Time `json:"last_fire_time,omitzero"`
 }
 
 type TriggerSource struct {
@@ -58,6 +64,25 @@ type TriggerStub struct {
 	Flow      string `json:"flow"`
 }
 
+// TriggerDelivery is a webhook request received by a trigger, and the outcome
+// of processing it.
+type TriggerDelivery struct {
+	ID           string            `json:"id"`
+	TriggerID    string            `json:"trigger_id"`
+	Namespace    string            `json:"namespace"`
+	RedeliveryOf string            `json:"redelivery_of,omitempty"`
+	Time         time.Time         `json:"time"`
+	Event        string            `json:"event,omitempty"`
+	Headers      map[string]string `json:"headers"`
+	PayloadHash  string            `json:"payload_hash"`
+	PayloadSize  int               `json:"payload_size"`
+	Decision     string            `json:"decision"`
+	Reason       string            `json:"reason,omitempty"`
+	StatusCode   int               `json:"status_code"`
+	RunID        string            `json:"run_id,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
 func ParseTriggerFile(path string) (*Trigger, error) {
 
 	// Read the source file
@@ -232,6 +257,10 @@ type TriggersGetReq struct {
 
 type TriggersGetResp struct {
 	Trigger *Trigger `json:"trigger"`
+
+	// NextRun is the next time the trigger is scheduled to run the flow,
+	// which is only set for cron triggers which are not paused.
+	NextRun *time.Time `json:"next_run,omitempty"`
 }
 
 func (t *Triggers) Get(ctx context.Context, req *TriggersGetReq) (*TriggersGetResp, *Response, error) {
@@ -251,6 +280,81 @@ func (t *Triggers) Get(ctx context.Context, req *TriggersGetReq) (*TriggersGetRe
 	return &resp, httpResp, nil
 }
 
+type TriggerUpdateReq struct {
+	Trigger *Trigger `json:"trigger"`
+}
+
+type TriggerUpdateResp struct {
+	Trigger *Trigger `json:"trigger"`
+}
+
+func (t *Triggers) Update(ctx context.Context, req *TriggerUpdateReq) (*TriggerUpdateResp, *Response, error) {
+
+	var resp TriggerUpdateResp
+
+	httpReq, err := t.client.NewRequest(http.MethodPut, "/v1/triggers/"+req.Trigger.ID, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := t.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
+
+type TriggerPauseReq struct {
+	ID string `json:"id"`
+}
+
+type TriggerPauseResp struct {
+	Trigger *Trigger `json:"trigger"`
+}
+
+func (t *Triggers) Pause(ctx context.Context, req *TriggerPauseReq) (*TriggerPauseResp, *Response, error) {
+
+	var resp TriggerPauseResp
+
+	httpReq, err := t.client.NewRequest(http.MethodPost, "/v1/triggers/"+req.ID+"/pause", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := t.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
+
+type TriggerResumeReq struct {
+	ID string `json:"id"`
+}
+
+type TriggerResumeResp struct {
+	Trigger *Trigger `json:"trigger"`
+}
+
+func (t *Triggers) Resume(ctx context.Context, req *TriggerResumeReq) (*TriggerResumeResp, *Response, error) {
+
+	var resp TriggerResumeResp
+
+	httpReq, err := t.client.NewRequest(http.MethodPost, "/v1/triggers/"+req.ID+"/resume", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := t.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
+
 type TriggerListReq struct{}
 
 type TriggerListResp struct {
@@ -273,3 +377,55 @@ func (t *Triggers) List(ctx context.Context, _ *TriggerListReq) (*TriggerListRes
 
 	return &resp, httpResp, nil
 }
+
+type TriggerDeliveriesReq struct {
+	ID string `json:"id"`
+}
+
+type TriggerDeliveriesResp struct {
+	Deliveries []*TriggerDelivery `json:"deliveries"`
+}
+
+func (t *Triggers) Deliveries(ctx context.Context, req *TriggerDeliveriesReq) (*TriggerDeliveriesResp, *Response, error) {
+
+	var resp TriggerDeliveriesResp
+
+	httpReq, err := t.client.NewRequest(http.MethodGet, "/v1/triggers/"+req.ID+"/deliveries", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := t.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}
+
+type TriggerRedeliverReq struct {
+	ID         string `json:"id"`
+	DeliveryID string `json:"delivery_id"`
+}
+
+type TriggerRedeliverResp struct {
+	Delivery *TriggerDelivery `json:"delivery"`
+}
+
+func (t *Triggers) Redeliver(ctx context.Context, req *TriggerRedeliverReq) (*TriggerRedeliverResp, *Response, error) {
+
+	var resp TriggerRedeliverResp
+
+	httpReq, err := t.client.NewRequest(http.MethodPost,
+		"/v1/triggers/"+req.ID+"/deliveries/"+req.DeliveryID+"/redeliver", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	httpResp, err := t.client.Do(ctx, httpReq, &resp)
+	if err != nil {
+		return nil, httpResp, err
+	}
+
+	return &resp, httpResp, nil
+}